@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"stock-tracker/internal/api"
 	"stock-tracker/internal/api/rest"
 	"stock-tracker/internal/api/websocket"
+	"stock-tracker/internal/events"
 	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/migrate"
 	"stock-tracker/internal/repository"
 	"stock-tracker/pkg/config"
 	"stock-tracker/pkg/logger"
@@ -28,8 +32,17 @@ func main() {
 	logger.Init(cfg.Debug)
 	logger.Info().Bool("debug", cfg.Debug).Str("version", "2.0.0").Msg("Starting Stock Tracker API Server")
 
-	// Initialize database
-	repo, err := repository.NewPostgresRepository(cfg.DatabaseURL)
+	// Initialize database; with AUTO_MIGRATE the migration lock keeps the
+	// tracker and API from migrating at the same time
+	if cfg.AutoMigrate {
+		if err := repository.Migrate(context.Background(), cfg.DatabaseURL); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to migrate database")
+		}
+	}
+	repo, err := repository.Open(cfg.DatabaseURL)
+	if errors.Is(err, migrate.ErrSchemaBehind) {
+		logger.Fatal().Err(err).Msg("Database schema is out of date")
+	}
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to connect to database")
 	}
@@ -42,6 +55,20 @@ func main() {
 	wsHub := websocket.NewHub()
 	go wsHub.Run()
 
+	// Relay tracker events to WebSocket clients; alert state changes made
+	// through the API are published on the same bus
+	bus, err := events.NewBus(cfg.EventBus, cfg.DatabaseURL)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create event bus")
+	}
+	defer bus.Close()
+
+	subscription, err := bus.Subscribe(context.Background())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to subscribe to event bus")
+	}
+	go wsHub.Forward(subscription)
+
 	// Start Prometheus metrics server
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
@@ -52,8 +79,14 @@ func main() {
 		}
 	}()
 
+	// The API validates new symbols against the same providers the tracker uses
+	provider, err := api.NewProvider(cfg, repo, m)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create quote provider")
+	}
+
 	// Setup REST API routes
-	handler := rest.NewHandler(repo, wsHub, m)
+	handler := rest.NewHandler(repo, provider, wsHub, bus, m)
 	router := rest.SetupRoutes(handler)
 
 	// Create HTTP server
@@ -76,13 +109,36 @@ func main() {
 
 	logger.Info().Msgf("API server running on http://localhost%s", apiAddr)
 	logger.Info().Msg("Available endpoints:")
-	logger.Info().Msg("  GET  /api/v1/stocks")
-	logger.Info().Msg("  GET  /api/v1/stocks/{symbol}")
-	logger.Info().Msg("  GET  /api/v1/stocks/{symbol}/history")
-	logger.Info().Msg("  GET  /api/v1/stocks/{symbol}/alerts")
-	logger.Info().Msg("  GET  /api/v1/alerts")
-	logger.Info().Msg("  GET  /api/v1/health")
-	logger.Info().Msg("  WS   /ws")
+	logger.Info().Msg("  GET    /api/v1/stocks")
+	logger.Info().Msg("  POST   /api/v1/stocks")
+	logger.Info().Msg("  GET    /api/v1/stocks/{symbol}")
+	logger.Info().Msg("  PATCH  /api/v1/stocks/{symbol}")
+	logger.Info().Msg("  DELETE /api/v1/stocks/{symbol}")
+	logger.Info().Msg("  GET    /api/v1/stocks/{symbol}/history")
+	logger.Info().Msg("  GET    /api/v1/stocks/{symbol}/bars")
+	logger.Info().Msg("  GET    /api/v1/stocks/{symbol}/candles")
+	logger.Info().Msg("  GET    /api/v1/stocks/{symbol}/alerts")
+	logger.Info().Msg("  GET    /api/v1/stocks/{symbol}/rules")
+	logger.Info().Msg("  POST   /api/v1/stocks/{symbol}/rules")
+	logger.Info().Msg("  PATCH  /api/v1/rules/{id}")
+	logger.Info().Msg("  DELETE /api/v1/rules/{id}")
+	logger.Info().Msg("  GET    /api/v1/rules/{id}/channels")
+	logger.Info().Msg("  PUT    /api/v1/rules/{id}/channels")
+	logger.Info().Msg("  GET    /api/v1/channels")
+	logger.Info().Msg("  POST   /api/v1/channels")
+	logger.Info().Msg("  PATCH  /api/v1/channels/{id}")
+	logger.Info().Msg("  DELETE /api/v1/channels/{id}")
+	logger.Info().Msg("  POST   /api/v1/channels/{id}/test")
+	logger.Info().Msg("  GET    /api/v1/alerts")
+	logger.Info().Msg("  GET    /api/v1/alerts/{id}")
+	logger.Info().Msg("  GET    /api/v1/alerts/{id}/history")
+	logger.Info().Msg("  POST   /api/v1/alerts/{id}/acknowledge")
+	logger.Info().Msg("  POST   /api/v1/alerts/{id}/snooze")
+	logger.Info().Msg("  POST   /api/v1/alerts/{id}/resolve")
+	logger.Info().Msg("  POST   /api/v1/alerts/{id}/reopen")
+	logger.Info().Msg("  GET    /api/v1/alerts/{id}/deliveries")
+	logger.Info().Msg("  GET    /api/v1/health")
+	logger.Info().Msg("  WS     /ws")
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -0,0 +1,126 @@
+// Command demo runs the tracker and the API server in one process on an
+// in-memory repository, so they can be tried without a database. Nothing
+// is kept across restarts.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"stock-tracker/internal/api"
+	"stock-tracker/internal/api/rest"
+	"stock-tracker/internal/api/websocket"
+	"stock-tracker/internal/events"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/notify"
+	"stock-tracker/internal/repository"
+	"stock-tracker/internal/tracker"
+	"stock-tracker/pkg/config"
+	"stock-tracker/pkg/logger"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger.Init(cfg.Debug)
+	logger.Info().Bool("debug", cfg.Debug).Str("version", "2.0.0").Msg("Starting Stock Tracker demo")
+
+	repo := repository.NewMemoryRepository()
+	defer repo.Close()
+
+	m := metrics.New()
+
+	// Tracker and API share the process, so events don't need Postgres
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+
+	wsHub := websocket.NewHub()
+	go wsHub.Run()
+
+	subscription, err := bus.Subscribe(context.Background())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to subscribe to event bus")
+	}
+	go wsHub.Forward(subscription)
+
+	go func() {
+		http.Handle("/metrics", promhttp.Handler())
+		metricsAddr := fmt.Sprintf(":%d", cfg.MetricsPort)
+		logger.Info().Str("address", metricsAddr).Msg("Starting Prometheus metrics server")
+		if err := http.ListenAndServe(metricsAddr, nil); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to start metrics server")
+		}
+	}()
+
+	provider, err := api.NewProvider(cfg, repo, m)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create quote provider")
+	}
+
+	// The demo polls around the clock, so there is something to see
+	// outside trading hours too.
+	stockTracker := tracker.New(provider, tracker.Options{
+		Interval:          cfg.UpdateInterval,
+		AlertThreshold:    cfg.AlertThreshold,
+		Workers:           cfg.UpdateWorkers,
+		FetchTimeout:      cfg.FetchTimeout,
+		VolumeAverageDays: cfg.VolumeAverageDays,
+		BarIntervals:      cfg.BarIntervals,
+		Notify: notify.RetryPolicy{
+			MaxAttempts: cfg.NotifyMaxAttempts,
+			Backoff:     cfg.NotifyBackoff,
+		},
+	}, m, repo, bus)
+
+	if err := stockTracker.LoadWatchlist(context.Background(), cfg.DefaultSymbols); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load watchlist")
+	}
+
+	handler := rest.NewHandler(repo, provider, wsHub, bus, m)
+	apiAddr := fmt.Sprintf(":%d", cfg.APIPort)
+	server := &http.Server{
+		Addr:         apiAddr,
+		Handler:      rest.SetupRoutes(handler),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	go func() {
+		logger.Info().Str("address", apiAddr).Msg("Starting API server")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal().Err(err).Msg("Failed to start API server")
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		logger.Info().Str("signal", sig.String()).Msg("Received shutdown signal")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error().Err(err).Msg("Server shutdown failed")
+		}
+		stockTracker.Close()
+	}()
+
+	logger.Info().Msgf("Demo running on http://localhost%s. Press Ctrl+C to stop", apiAddr)
+
+	// Run returns once Close has cancelled the in-flight update cycle.
+	stockTracker.Run()
+	logger.Info().Msg("Graceful shutdown complete")
+}
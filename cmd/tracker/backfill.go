@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"stock-tracker/internal/api"
+	"stock-tracker/internal/backfill"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/config"
+	"stock-tracker/pkg/logger"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runBackfill implements "tracker backfill": it loads daily bars for the
+// given symbols, or every stock on the watchlist, and exits.
+func runBackfill(cfg *config.Config, args []string) error {
+	today := time.Now().UTC().Format(time.DateOnly)
+	// Alpha Vantage serves free keys the compact daily series, about 100
+	// trading days; reaching further back needs a premium key
+	compactFrom := time.Now().UTC().AddDate(0, 0, -100).Format(time.DateOnly)
+
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	symbolList := flags.String("symbols", "", "comma-separated symbols to backfill (default: every stock on the watchlist)")
+	fromDate := flags.String("from", compactFrom, "first day to backfill, YYYY-MM-DD")
+	toDate := flags.String("to", today, "last day to backfill, YYYY-MM-DD")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tracker backfill [-symbols AAPL,MSFT] [-from YYYY-MM-DD] [-to YYYY-MM-DD]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	from, err := time.Parse(time.DateOnly, *fromDate)
+	if err != nil {
+		return fmt.Errorf("invalid -from date %q", *fromDate)
+	}
+	to, err := time.Parse(time.DateOnly, *toDate)
+	if err != nil {
+		return fmt.Errorf("invalid -to date %q", *toDate)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("-to must not be before -from")
+	}
+
+	repo, err := repository.Open(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer repo.Close()
+
+	provider, err := api.NewProvider(cfg, repo, metrics.New())
+	if err != nil {
+		return fmt.Errorf("failed to create quote provider: %w", err)
+	}
+	history, ok := provider.(api.HistoryProvider)
+	if !ok || !provider.Capabilities().DailyHistory {
+		return fmt.Errorf("quote provider %s has no daily history", provider.Name())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var symbols []string
+	for _, symbol := range strings.Split(*symbolList, ",") {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		stocks, err := repo.GetAllStocks(ctx)
+		if err != nil {
+			return err
+		}
+		for _, stock := range stocks {
+			symbols = append(symbols, stock.Symbol)
+		}
+	}
+
+	logger.Info().
+		Strs("symbols", symbols).
+		Str("from", *fromDate).
+		Str("to", *toDate).
+		Str("provider", provider.Name()).
+		Msg("Starting backfill")
+
+	return backfill.New(history, repo).Run(ctx, symbols, from, to)
+}
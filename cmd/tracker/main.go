@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"stock-tracker/internal/api/websocket"
+	"slices"
+	"stock-tracker/internal/api"
+	"stock-tracker/internal/calendar"
+	"stock-tracker/internal/events"
 	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/migrate"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/notify"
 	"stock-tracker/internal/repository"
+	"stock-tracker/internal/retention"
 	"stock-tracker/internal/tracker"
 	"stock-tracker/pkg/config"
 	"stock-tracker/pkg/logger"
@@ -17,6 +26,16 @@ import (
 )
 
 func main() {
+	// migrate only needs the database, so it runs without the provider
+	// settings Load insists on
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		logger.Init(os.Getenv("DEBUG") == "true")
+		if err := runMigrate(config.DatabaseURL(), os.Args[2:]); err != nil {
+			logger.Fatal().Err(err).Msg("Migration failed")
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Printf("Failed to load configuration: %v\n", err)
@@ -24,10 +43,33 @@ func main() {
 	}
 
 	logger.Init(cfg.Debug)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			if err := runBackfill(cfg, os.Args[2:]); err != nil {
+				logger.Fatal().Err(err).Msg("Backfill failed")
+			}
+			logger.Info().Msg("Backfill complete")
+			return
+		default:
+			logger.Fatal().Str("command", os.Args[1]).Msg("Unknown command; expected backfill or migrate")
+		}
+	}
+
 	logger.Info().Bool("debug", cfg.Debug).Str("version", "2.0.0").Msg("Starting Stock Price Tracker")
 
-	// Initialize database
-	repo, err := repository.NewPostgresRepository(cfg.DatabaseURL)
+	// Initialize database; with AUTO_MIGRATE the migration lock keeps the
+	// tracker and API from migrating at the same time
+	if cfg.AutoMigrate {
+		if err := repository.Migrate(context.Background(), cfg.DatabaseURL); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to migrate database")
+		}
+	}
+	repo, err := repository.Open(cfg.DatabaseURL)
+	if errors.Is(err, migrate.ErrSchemaBehind) {
+		logger.Fatal().Err(err).Msg("Database schema is out of date")
+	}
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to connect to database")
 	}
@@ -36,9 +78,12 @@ func main() {
 	// Initialize metrics
 	m := metrics.New()
 
-	// Initialize WebSocket hub
-	wsHub := websocket.NewHub()
-	go wsHub.Run()
+	// Initialize event bus; the API server relays these events to WebSocket clients
+	bus, err := events.NewBus(cfg.EventBus, cfg.DatabaseURL)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create event bus")
+	}
+	defer bus.Close()
 
 	// Start Prometheus metrics server
 	metricsAddr := fmt.Sprintf(":%d", cfg.MetricsPort)
@@ -50,18 +95,74 @@ func main() {
 		}
 	}()
 
+	provider, err := api.NewProvider(cfg, repo, m)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create quote provider")
+	}
+
+	var marketCalendar *calendar.Calendar
+	if cfg.MarketCalendar != "none" {
+		marketCalendar, err = calendar.Load(cfg.MarketCalendar, cfg.MarketCalendarFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to load market calendar")
+		}
+	}
+
+	for _, interval := range cfg.BarIntervals {
+		if !slices.Contains(models.IntradayIntervals, interval) {
+			logger.Fatal().Str("interval", interval).Strs("supported", models.IntradayIntervals).Msg("Unsupported intraday bar interval")
+		}
+	}
+
+	var policy retention.Policy
+	if cfg.PriceRetention != "" {
+		policy, err = retention.ParsePolicy(cfg.PriceRetention)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Invalid PRICE_RETENTION")
+		}
+	}
+
 	logger.Info().
+		Str("provider", provider.Name()).
+		Str("event_bus", cfg.EventBus).
 		Dur("update_interval", cfg.UpdateInterval).
+		Str("market_calendar", cfg.MarketCalendar).
+		Dur("extended_hours_interval", cfg.ExtendedHoursInterval).
+		Strs("bar_intervals", cfg.BarIntervals).
+		Stringer("price_retention", policy).
+		Int("update_workers", cfg.UpdateWorkers).
+		Dur("fetch_timeout", cfg.FetchTimeout).
 		Float64("alert_threshold", cfg.AlertThreshold).
+		Int("notify_max_attempts", cfg.NotifyMaxAttempts).
 		Int("metrics_port", cfg.MetricsPort).
 		Str("database_url", maskDatabaseURL(cfg.DatabaseURL)).
 		Msg("Configuration loaded")
 
-	stockTracker := tracker.New(cfg.APIKey, cfg.UpdateInterval, cfg.AlertThreshold, m, repo, wsHub)
+	stockTracker := tracker.New(provider, tracker.Options{
+		Interval:          cfg.UpdateInterval,
+		AlertThreshold:    cfg.AlertThreshold,
+		Workers:           cfg.UpdateWorkers,
+		FetchTimeout:      cfg.FetchTimeout,
+		VolumeAverageDays: cfg.VolumeAverageDays,
+		Calendar:          marketCalendar,
+		ExtendedInterval:  cfg.ExtendedHoursInterval,
+		BarIntervals:      cfg.BarIntervals,
+		Notify: notify.RetryPolicy{
+			MaxAttempts: cfg.NotifyMaxAttempts,
+			Backoff:     cfg.NotifyBackoff,
+		},
+	}, m, repo, bus)
 	defer stockTracker.Close()
 
-	for _, symbol := range cfg.DefaultSymbols {
-		stockTracker.AddStock(symbol)
+	if err := stockTracker.LoadWatchlist(context.Background(), cfg.DefaultSymbols); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load watchlist")
+	}
+
+	// Compact price history in the background
+	compactCtx, stopCompacting := context.WithCancel(context.Background())
+	defer stopCompacting()
+	if policy != nil {
+		go retention.NewCompactor(repo, policy, m).Run(compactCtx, cfg.RetentionInterval)
 	}
 
 	// Handle graceful shutdown
@@ -71,16 +172,16 @@ func main() {
 	go func() {
 		sig := <-sigChan
 		logger.Info().Str("signal", sig.String()).Msg("Received shutdown signal")
+		stopCompacting()
 		stockTracker.Close()
-		repo.Close()
-		logger.Info().Msg("Graceful shutdown complete")
-		os.Exit(0)
 	}()
 
 	logger.Info().Msg("Stock tracker running. Press Ctrl+C to stop")
 	logger.Info().Str("metrics_url", fmt.Sprintf("http://localhost%s/metrics", metricsAddr)).Msg("Prometheus metrics available")
 
+	// Run returns once Close has cancelled the in-flight update cycle.
 	stockTracker.Run()
+	logger.Info().Msg("Graceful shutdown complete")
 }
 
 func maskDatabaseURL(url string) string {
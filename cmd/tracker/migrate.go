@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// runMigrate implements "tracker migrate up|down|status" against the
+// database at databaseURL.
+func runMigrate(databaseURL string, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations down rolls back")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tracker migrate up | down [-steps N] | status")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := args[0]
+	flags.Parse(args[1:])
+	if *steps < 1 {
+		return fmt.Errorf("-steps must be positive")
+	}
+
+	migrator, err := repository.OpenMigrator(databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer migrator.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info().Int("applied", len(applied)).Msg("Database schema is up to date")
+
+	case "down":
+		rolledBack, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		logger.Info().Int("rolled_back", len(rolledBack)).Msg("Rolled back database schema")
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"stock-tracker/internal/expr"
+	"stock-tracker/internal/indicators"
+	"stock-tracker/internal/models"
+	"sync"
+	"time"
+)
+
+// evaluateExpression checks an expression rule. Its value is 1 while the
+// expression holds and 0 otherwise. ok is false while a value the result
+// depends on is not available, e.g. an indicator still warming up.
+func (m *AlertMonitor) evaluateExpression(ctx context.Context, rule *models.AlertRule, stock *models.Stock) (evaluation, bool, error) {
+	program, err := m.programs.get(rule)
+	if err != nil {
+		// Validated on create, so only a rule edited in the database gets
+		// here.
+		return evaluation{}, false, fmt.Errorf("invalid expression: %w", err)
+	}
+
+	env := &exprEnv{ctx: ctx, monitor: m, stock: stock}
+	holds, ok := program.Eval(env)
+	if env.err != nil {
+		return evaluation{}, false, env.err
+	}
+	if !ok {
+		return evaluation{}, false, nil
+	}
+
+	result := evaluation{triggered: holds}
+	if holds {
+		result.value = 1
+		result.message = fmt.Sprintf("%s at $%.2f matched %s", stock.Symbol, stock.CurrentPrice, rule.Expression)
+	}
+	return result, true, nil
+}
+
+// programCache holds the compiled expression of each rule, so a rule is
+// compiled when it is first evaluated rather than on every tick. An entry
+// is used while the rule's UpdatedAt and expression are unchanged.
+type programCache struct {
+	mu       sync.Mutex
+	programs map[int]*compiledRule
+}
+
+// compiledRule is a rule's program, or the error compiling it, so an
+// invalid rule isn't compiled again either.
+type compiledRule struct {
+	symbol     string
+	updatedAt  time.Time
+	expression string
+	program    *expr.Program
+	err        error
+}
+
+func newProgramCache() *programCache {
+	return &programCache{programs: make(map[int]*compiledRule)}
+}
+
+func (c *programCache) get(rule *models.AlertRule) (*expr.Program, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	compiled, ok := c.programs[rule.ID]
+	if !ok || !compiled.updatedAt.Equal(rule.UpdatedAt) || compiled.expression != rule.Expression {
+		compiled = &compiledRule{symbol: rule.Symbol, updatedAt: rule.UpdatedAt, expression: rule.Expression}
+		compiled.program, compiled.err = expr.Compile(rule.Expression)
+		c.programs[rule.ID] = compiled
+	}
+	return compiled.program, compiled.err
+}
+
+// retain drops the symbol's programs whose rule IDs aren't in keep.
+func (c *programCache) retain(symbol string, keep map[int]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, compiled := range c.programs {
+		if compiled.symbol == symbol && !keep[id] {
+			delete(c.programs, id)
+		}
+	}
+}
+
+// exprEnv answers an expression's variables and indicator calls for one
+// stock update.
+type exprEnv struct {
+	ctx     context.Context
+	monitor *AlertMonitor
+	stock   *models.Stock
+	// err is the first error loading history, which makes the rule fail
+	// rather than quietly evaluate to unavailable.
+	err error
+}
+
+func (e *exprEnv) Var(name string) (float64, bool) {
+	s := e.stock
+	switch name {
+	case "price":
+		return s.CurrentPrice, s.CurrentPrice > 0
+	case "previous_price":
+		return s.PreviousPrice, s.PreviousPrice > 0
+	case "change":
+		return s.CurrentPrice - s.PreviousPrice, s.PreviousPrice > 0
+	case "change_percent":
+		return s.ChangePercent, true
+	case "volume":
+		return float64(s.Volume), s.Volume > 0
+	case "avg_volume":
+		return s.AvgVolume, s.AvgVolume > 0
+	case "volume_ratio":
+		return s.VolumeRatio()
+	}
+	return 0, false
+}
+
+func (e *exprEnv) Indicator(name string, args []float64) (float64, bool) {
+	if e.err != nil {
+		return 0, false
+	}
+
+	if name == "avg_volume" {
+		avg, err := e.monitor.volumes.Get(e.ctx, e.stock.Symbol, int(args[0]))
+		if err != nil {
+			e.err = err
+			return 0, false
+		}
+		return avg, avg > 0
+	}
+
+	key := callSeriesKey(name, args)
+	s := e.monitor.series.lookup(e.stock.Symbol, key, func() *series { return newIndicatorSeries(name, args) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.advance(e.ctx, e.monitor.repo, e.stock); err != nil {
+		e.err = err
+		return 0, false
+	}
+	value, _, ok := s.read()
+	return value, ok
+}
+
+// newIndicatorSeries returns the series of an indicator function of the
+// expression language, with the settings checked by expr.Compile.
+func newIndicatorSeries(name string, args []float64) *series {
+	switch name {
+	case "sma", "ema", "rsi":
+		var ind interface {
+			Update(float64)
+			Value() (float64, bool)
+			Warmup() int
+		}
+		switch period := int(args[0]); name {
+		case "sma":
+			ind = indicators.NewSMA(period)
+		case "ema":
+			ind = indicators.NewEMA(period)
+		default:
+			ind = indicators.NewRSI(period)
+		}
+		return &series{
+			warmup: ind.Warmup(),
+			update: ind.Update,
+			read: func() (float64, string, bool) {
+				value, ok := ind.Value()
+				return value, "", ok
+			},
+		}
+
+	case "macd", "macd_signal":
+		macd := indicators.NewMACD(int(args[0]), int(args[1]), int(args[2]))
+		return &series{
+			warmup: macd.Warmup(),
+			update: macd.Update,
+			read: func() (float64, string, bool) {
+				line, signal, _, ok := macd.Value()
+				if name == "macd_signal" {
+					return signal, "", ok
+				}
+				return line, "", ok
+			},
+		}
+
+	default: // Bollinger Bands
+		bb := indicators.NewBollinger(int(args[0]), args[1])
+		return &series{
+			warmup: bb.Warmup(),
+			update: bb.Update,
+			read: func() (float64, string, bool) {
+				bands, ok := bb.Value()
+				switch name {
+				case "bb_upper":
+					return bands.Upper, "", ok
+				case "bb_lower":
+					return bands.Lower, "", ok
+				default:
+					return bands.Middle, "", ok
+				}
+			},
+		}
+	}
+}
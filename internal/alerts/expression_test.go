@@ -0,0 +1,41 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"stock-tracker/internal/models"
+)
+
+func TestProgramCache(t *testing.T) {
+	created := time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+	rule := &models.AlertRule{ID: 1, RuleType: models.RuleExpression, Expression: "price > 100", UpdatedAt: created}
+	c := newProgramCache()
+
+	first, err := c.get(rule)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if again, _ := c.get(rule); again != first {
+		t.Error("unchanged rule was compiled again")
+	}
+
+	edited := *rule
+	edited.Expression, edited.UpdatedAt = "price < 50", created.Add(time.Minute)
+	program, err := c.get(&edited)
+	if err != nil {
+		t.Fatalf("get edited: %v", err)
+	}
+	if program == first || program.String() != edited.Expression {
+		t.Errorf("edited rule got program %q, want %q", program, edited.Expression)
+	}
+
+	invalid := *rule
+	invalid.ID, invalid.Expression = 2, "price >"
+	if _, err := c.get(&invalid); err == nil {
+		t.Error("invalid expression compiled")
+	}
+	if _, err := c.get(&invalid); err == nil {
+		t.Error("cached invalid expression compiled")
+	}
+}
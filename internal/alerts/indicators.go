@@ -0,0 +1,265 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"stock-tracker/internal/indicators"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"sync"
+	"time"
+)
+
+// series is the running indicator state behind one indicator rule for one
+// symbol. It is seeded from the price history the first time the rule is
+// evaluated and then fed each new price.
+type series struct {
+	mu     sync.Mutex
+	seeded bool
+	warmup int
+	update func(price float64)
+	// read returns the value the rule compares: the indicator itself for
+	// threshold rules, or the signed distance past the line for crossing
+	// rules, positive once the line has been crossed in the rule's
+	// direction.
+	read func() (value float64, message string, ok bool)
+	// last is the time of the newest price fed in.
+	last time.Time
+	// prev is the value before the newest price, to detect crossings.
+	prev    float64
+	hasPrev bool
+}
+
+func (s *series) feed(price float64) {
+	if value, _, ok := s.read(); ok {
+		s.prev, s.hasPrev = value, true
+	}
+	s.update(price)
+}
+
+// seriesCache holds the indicator series of every symbol and rule setting.
+// Rules with the same type and parameters share a series.
+type seriesCache struct {
+	mu sync.Mutex
+	// series is keyed by symbol, then by setting.
+	series map[string]map[string]*series
+}
+
+func newSeriesCache() *seriesCache {
+	return &seriesCache{series: make(map[string]map[string]*series)}
+}
+
+func (c *seriesCache) get(rule *models.AlertRule, symbol string) *series {
+	return c.lookup(symbol, ruleSeriesKey(rule), func() *series { return newSeries(rule, symbol) })
+}
+
+// lookup returns the symbol's series under key, creating it with create
+// on first use.
+func (c *seriesCache) lookup(symbol, key string, create func() *series) *series {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	settings, ok := c.series[symbol]
+	if !ok {
+		settings = make(map[string]*series)
+		c.series[symbol] = settings
+	}
+	s, ok := settings[key]
+	if !ok {
+		s = create()
+		settings[key] = s
+	}
+	return s
+}
+
+// retain drops the symbol's series whose keys aren't in keep.
+func (c *seriesCache) retain(symbol string, keep map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.series[symbol] {
+		if !keep[key] {
+			delete(c.series[symbol], key)
+		}
+	}
+	if len(c.series[symbol]) == 0 {
+		delete(c.series, symbol)
+	}
+}
+
+func (c *seriesCache) remove(symbol string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.series, symbol)
+}
+
+// ruleSeriesKey is the setting of an indicator rule's series.
+func ruleSeriesKey(rule *models.AlertRule) string {
+	return fmt.Sprintf("%s|%+v", rule.RuleType, rule.Params)
+}
+
+// callSeriesKey is the setting of the series behind an indicator call in
+// an expression.
+func callSeriesKey(name string, args []float64) string {
+	return fmt.Sprintf("%s%v", name, args)
+}
+
+// advance brings the series up to the stock's current price, seeding it
+// from history on first use. The tracker saves prices in batches, so the
+// history may or may not hold the current price yet.
+func (s *series) advance(ctx context.Context, repo repository.StockRepository, stock *models.Stock) error {
+	if !s.seeded {
+		history, err := repo.GetPriceHistory(ctx, stock.Symbol, time.Time{}, stock.LastUpdated, s.warmup+1)
+		if err != nil {
+			return fmt.Errorf("failed to seed indicators: %w", err)
+		}
+		// History comes newest first.
+		slices.Reverse(history)
+		for _, price := range history {
+			s.feed(price.Price)
+		}
+		// Stored timestamps have microsecond precision.
+		if n := len(history); n == 0 || history[n-1].Timestamp.Before(stock.LastUpdated.Truncate(time.Microsecond)) {
+			s.feed(stock.CurrentPrice)
+		}
+		s.seeded = true
+		s.last = stock.LastUpdated
+		return nil
+	}
+
+	if stock.LastUpdated.After(s.last) {
+		s.feed(stock.CurrentPrice)
+		s.last = stock.LastUpdated
+	}
+	return nil
+}
+
+// evaluateIndicator checks an indicator rule. ok is false until there is
+// enough history for the indicator.
+func (m *AlertMonitor) evaluateIndicator(ctx context.Context, rule *models.AlertRule, stock *models.Stock) (evaluation, bool, error) {
+	s := m.series.get(rule, stock.Symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.advance(ctx, m.repo, stock); err != nil {
+		return evaluation{}, false, err
+	}
+
+	value, message, ok := s.read()
+	if !ok {
+		return evaluation{}, false, nil
+	}
+
+	result := evaluation{value: value, message: stock.Symbol + " " + message}
+	switch rule.RuleType {
+	case models.RuleRSIAbove:
+		result.triggered = value >= rule.Threshold
+	case models.RuleRSIBelow:
+		result.triggered = value <= rule.Threshold
+	case models.RuleBollingerBreakout:
+		result.triggered = value > 0
+	default:
+		// Crossovers only count on the sample where the sign flips.
+		result.triggered = s.hasPrev && s.prev <= 0 && value > 0
+	}
+	return result, true, nil
+}
+
+func newSeries(rule *models.AlertRule, symbol string) *series {
+	p := rule.Params
+	// sign turns "a - b" into a distance past the line in the rule's
+	// direction.
+	sign := 1.0
+	if p.Direction == models.DirectionBelow {
+		sign = -1
+	}
+
+	switch rule.RuleType {
+	case models.RuleSMACross, models.RuleEMACross:
+		type average interface {
+			Update(float64)
+			Value() (float64, bool)
+			Warmup() int
+		}
+		var fast, slow average
+		name := "SMA"
+		if rule.RuleType == models.RuleEMACross {
+			fast, slow, name = indicators.NewEMA(p.Fast), indicators.NewEMA(p.Slow), "EMA"
+		} else {
+			fast, slow = indicators.NewSMA(p.Fast), indicators.NewSMA(p.Slow)
+		}
+		return &series{
+			warmup: slow.Warmup(),
+			update: func(price float64) {
+				fast.Update(price)
+				slow.Update(price)
+			},
+			read: func() (float64, string, bool) {
+				f, ok := fast.Value()
+				if !ok {
+					return 0, "", false
+				}
+				s, ok := slow.Value()
+				if !ok {
+					return 0, "", false
+				}
+				return sign * (f - s), fmt.Sprintf("%s(%d) %.2f crossed %s %s(%d) %.2f",
+					name, p.Fast, f, p.Direction, name, p.Slow, s), true
+			},
+		}
+
+	case models.RuleMACDCross:
+		macd := indicators.NewMACD(p.Fast, p.Slow, p.Signal)
+		return &series{
+			warmup: macd.Warmup(),
+			update: macd.Update,
+			read: func() (float64, string, bool) {
+				line, signal, _, ok := macd.Value()
+				if !ok {
+					return 0, "", false
+				}
+				return sign * (line - signal), fmt.Sprintf("MACD(%d,%d,%d) %.3f crossed %s its signal line %.3f",
+					p.Fast, p.Slow, p.Signal, line, p.Direction, signal), true
+			},
+		}
+
+	case models.RuleBollingerBreakout:
+		bb := indicators.NewBollinger(p.Period, p.StdDev)
+		return &series{
+			warmup: bb.Warmup(),
+			update: bb.Update,
+			read: func() (float64, string, bool) {
+				bands, ok := bb.Value()
+				if !ok {
+					return 0, "", false
+				}
+				price, band := bb.Last(), bands.Upper
+				if p.Direction == models.DirectionBelow {
+					band = bands.Lower
+				}
+				return sign * (price - band), fmt.Sprintf("at $%.2f broke %s the Bollinger Band(%d, %g) at $%.2f",
+					price, p.Direction, p.Period, p.StdDev, band), true
+			},
+		}
+
+	default: // RSI rules
+		rsi := indicators.NewRSI(p.Period)
+		return &series{
+			warmup: rsi.Warmup(),
+			update: rsi.Update,
+			read: func() (float64, string, bool) {
+				value, ok := rsi.Value()
+				if !ok {
+					return 0, "", false
+				}
+				side := "above"
+				if rule.RuleType == models.RuleRSIBelow {
+					side = "below"
+				}
+				return value, fmt.Sprintf("RSI(%d) is %.1f, at or %s %g", p.Period, value, side, rule.Threshold), true
+			},
+		}
+	}
+}
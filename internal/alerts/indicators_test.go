@@ -0,0 +1,76 @@
+package alerts
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+)
+
+// seriesKeys returns the settings of the symbol's cached series.
+func seriesKeys(m *AlertMonitor, symbol string) []string {
+	m.series.mu.Lock()
+	defer m.series.mu.Unlock()
+
+	var keys []string
+	for key := range m.series.series[symbol] {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestSeriesPruning(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	if err := repo.CreateStock(ctx, models.NewStock("AAPL")); err != nil {
+		t.Fatalf("CreateStock: %v", err)
+	}
+
+	cross := &models.AlertRule{Symbol: "AAPL", RuleType: models.RuleSMACross, Params: models.RuleParams{Fast: 2, Slow: 3}, Enabled: true}
+	expression := &models.AlertRule{Symbol: "AAPL", RuleType: models.RuleExpression, Expression: "price > sma(5)", Enabled: true}
+	for _, rule := range []*models.AlertRule{cross, expression} {
+		rule.SetDefaults()
+		if err := repo.CreateAlertRule(ctx, rule); err != nil {
+			t.Fatalf("CreateAlertRule: %v", err)
+		}
+	}
+
+	m := newTestMonitor(repo)
+	stock := models.NewStock("AAPL")
+	stock.CurrentPrice = 100
+	check := func() {
+		stock.LastUpdated = stock.LastUpdated.Add(time.Minute)
+		m.CheckStock(ctx, stock)
+	}
+
+	check()
+	want := []string{ruleSeriesKey(cross), callSeriesKey("sma", []float64{5})}
+	slices.Sort(want)
+	if got := seriesKeys(m, "AAPL"); !slices.Equal(got, want) {
+		t.Fatalf("series = %q, want %q", got, want)
+	}
+
+	if err := repo.DeleteAlertRule(ctx, cross.ID); err != nil {
+		t.Fatalf("DeleteAlertRule: %v", err)
+	}
+	expression.Expression = "price > ema(10)"
+	if err := repo.UpdateAlertRule(ctx, expression); err != nil {
+		t.Fatalf("UpdateAlertRule: %v", err)
+	}
+	check()
+	if got, want := seriesKeys(m, "AAPL"), []string{callSeriesKey("ema", []float64{10})}; !slices.Equal(got, want) {
+		t.Errorf("series after editing rules = %q, want %q", got, want)
+	}
+
+	m.Forget("AAPL")
+	if got := seriesKeys(m, "AAPL"); len(got) != 0 {
+		t.Errorf("series after Forget = %q, want none", got)
+	}
+	if len(m.programs.programs) != 0 {
+		t.Errorf("%d programs after Forget, want none", len(m.programs.programs))
+	}
+}
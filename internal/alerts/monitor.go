@@ -2,84 +2,293 @@ package alerts
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"stock-tracker/internal/api/websocket"
+	"stock-tracker/internal/events"
 	"stock-tracker/internal/metrics"
 	"stock-tracker/internal/models"
+	"stock-tracker/internal/notify"
 	"stock-tracker/internal/repository"
 	"stock-tracker/pkg/logger"
+	"sync"
 	"time"
 )
 
 type AlertMonitor struct {
-	threshold float64
-	alertChan chan string
-	metrics   *metrics.Metrics
-	repo      repository.StockRepository
-	wsHub     *websocket.Hub
+	// threshold is the tick-to-tick percent change that raises an alert for
+	// stocks without any rules of their own.
+	threshold  float64
+	alertChan  chan *models.Alert
+	metrics    *metrics.Metrics
+	repo       repository.StockRepository
+	publisher  events.Publisher
+	dispatcher *notify.Dispatcher
+	series     *seriesCache
+	programs   *programCache
+	volumes    *repository.VolumeAverages
+	drained    sync.WaitGroup
+
+	// defaults holds the state of the default threshold per symbol. It has
+	// no rule row to be stored in, so it is kept in memory and a restart
+	// rearms it.
+	defaultsMu sync.Mutex
+	defaults   map[string]*models.AlertRule
 }
 
-func NewMonitor(threshold float64, m *metrics.Metrics, repo repository.StockRepository, wsHub *websocket.Hub) *AlertMonitor {
+// NewMonitor creates a monitor. volumes is normally the tracker's, so
+// expressions reuse the averages it has loaded.
+func NewMonitor(threshold float64, m *metrics.Metrics, repo repository.StockRepository, volumes *repository.VolumeAverages, publisher events.Publisher, policy notify.RetryPolicy) *AlertMonitor {
 	return &AlertMonitor{
-		threshold: threshold,
-		alertChan: make(chan string, 100),
-		metrics:   m,
-		repo:      repo,
-		wsHub:     wsHub,
+		threshold:  threshold,
+		alertChan:  make(chan *models.Alert, 100),
+		metrics:    m,
+		repo:       repo,
+		publisher:  publisher,
+		dispatcher: notify.NewDispatcher(repo, m, policy),
+		series:     newSeriesCache(),
+		programs:   newProgramCache(),
+		volumes:    volumes,
+		defaults:   make(map[string]*models.AlertRule),
 	}
 }
 
+// Start sends fired alerts to their notification channels in the background.
 func (m *AlertMonitor) Start() {
+	m.drained.Add(1)
 	go func() {
+		defer m.drained.Done()
 		for alert := range m.alertChan {
-			logger.Warn().Str("alert", alert).Msg("Price alert triggered")
+			logger.Warn().Str("alert", alert.Message).Msg("Price alert triggered")
+			m.dispatcher.Dispatch(alert)
 		}
 	}()
 }
 
-func (m *AlertMonitor) CheckStock(stock *models.Stock) {
-	if stock.PreviousPrice == 0 {
+// CheckStock evaluates the stock's enabled alert rules, or the default
+// threshold if it has none. Rule state is loaded with the rules, so it
+// survives restarts and is shared by every tracker using the database.
+func (m *AlertMonitor) CheckStock(ctx context.Context, stock *models.Stock) {
+	rules, err := m.repo.GetAlertRules(ctx, stock.Symbol)
+	if err != nil {
+		logger.Error().Err(err).Str("symbol", stock.Symbol).Msg("Failed to load alert rules")
 		return
 	}
+	m.prune(stock.Symbol, rules)
 
-	pctChange := stock.CalculatePriceChange()
-	if pctChange > m.threshold || pctChange < -m.threshold {
-		alertType := "price_increase"
-		if pctChange < 0 {
-			alertType = "price_decrease"
+	if len(rules) == 0 {
+		m.checkDefaultThreshold(ctx, stock)
+		return
+	}
+
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
 		}
 
-		m.metrics.AlertsTriggered.WithLabelValues(stock.Symbol, alertType).Inc()
+		result, ok, err := m.evaluateRule(ctx, rule, stock)
+		if err != nil {
+			m.metrics.AlertRuleErrors.WithLabelValues(stock.Symbol, rule.RuleType).Inc()
+			logger.Error().Err(err).Int("rule_id", rule.ID).Str("symbol", stock.Symbol).Msg("Failed to evaluate alert rule")
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if minRatio := rule.Params.MinVolumeRatio; minRatio > 0 {
+			ratio, known := stock.VolumeRatio()
+			result.triggered = result.triggered && known && ratio >= minRatio
+		}
 
-		message := fmt.Sprintf("%s changed by %.2f%% (from $%.2f to $%.2f)",
-			stock.Symbol, pctChange, stock.PreviousPrice, stock.CurrentPrice)
+		m.step(ctx, rule, stock, result)
+	}
+}
 
-		// Save alert to database
-		ctx := context.Background()
-		alert := &models.Alert{
-			StockID:     stock.ID,
-			Symbol:      stock.Symbol,
-			AlertType:   alertType,
-			Threshold:   m.threshold,
-			Message:     message,
-			TriggeredAt: time.Now(),
+// prune drops the indicator series and compiled expressions the symbol's
+// enabled rules no longer use, so edited, disabled and deleted rules don't
+// hold on to their state.
+func (m *AlertMonitor) prune(symbol string, rules []*models.AlertRule) {
+	keys := make(map[string]bool)
+	ids := make(map[int]bool)
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
 		}
+		switch rule.RuleType {
+		case models.RuleSMACross, models.RuleEMACross, models.RuleMACDCross,
+			models.RuleRSIAbove, models.RuleRSIBelow, models.RuleBollingerBreakout:
+			keys[ruleSeriesKey(rule)] = true
 
-		if err := m.repo.SaveAlert(ctx, alert); err != nil {
-			logger.Error().Err(err).Str("symbol", stock.Symbol).Msg("Failed to save alert to database")
+		case models.RuleExpression:
+			ids[rule.ID] = true
+			program, err := m.programs.get(rule)
+			if err != nil {
+				// Reported when the rule is evaluated.
+				continue
+			}
+			for _, call := range program.Indicators() {
+				keys[callSeriesKey(call.Name, call.Args)] = true
+			}
 		}
+	}
+	m.series.retain(symbol, keys)
+	m.programs.retain(symbol, ids)
+}
+
+// Forget drops everything the monitor holds for symbol. The tracker calls
+// it when the symbol leaves the watchlist.
+func (m *AlertMonitor) Forget(symbol string) {
+	m.series.remove(symbol)
+	m.programs.retain(symbol, nil)
 
-		// Broadcast alert via WebSocket
-		m.wsHub.BroadcastAlert(alert)
+	m.defaultsMu.Lock()
+	delete(m.defaults, symbol)
+	m.defaultsMu.Unlock()
+}
 
-		select {
-		case m.alertChan <- message:
-		default:
-			logger.Warn().Msg("Alert channel full, dropping alert")
+// step advances a rule's state machine with one evaluation. An armed rule
+// fires when its condition holds and it is out of cooldown, then stays
+// disarmed until the value is back inside the hysteresis band.
+func (m *AlertMonitor) step(ctx context.Context, rule *models.AlertRule, stock *models.Stock, result evaluation) {
+	now := time.Now()
+	dedupKey := rule.DedupKey()
+
+	fired, changed := m.transition(rule, stock.Symbol, result, now)
+	if changed {
+		m.saveState(ctx, rule)
+	}
+	if !fired {
+		return
+	}
+
+	ruleID := rule.ID
+	m.fire(ctx, &models.Alert{
+		StockID:     stock.ID,
+		Symbol:      stock.Symbol,
+		RuleID:      &ruleID,
+		AlertType:   rule.RuleType,
+		Threshold:   rule.Threshold,
+		Message:     result.message,
+		DedupKey:    dedupKey,
+		TriggeredAt: now,
+	})
+}
+
+// transition applies one evaluation to the rule's state and reports
+// whether the rule fires and whether its state changed.
+func (m *AlertMonitor) transition(rule *models.AlertRule, symbol string, result evaluation, now time.Time) (fired, changed bool) {
+	if !rule.State.Armed {
+		if !rearms(rule, result.value) {
+			if result.triggered {
+				m.metrics.AlertsSuppressed.WithLabelValues(symbol, "disarmed").Inc()
+			}
+			return false, false
 		}
+		rule.State.Armed = true
+		return false, true
+	}
+
+	if !result.triggered {
+		return false, false
+	}
+	if rule.CoolingDown(now) {
+		m.metrics.AlertsSuppressed.WithLabelValues(symbol, "cooldown").Inc()
+		return false, false
+	}
+
+	rule.State.Armed = false
+	rule.State.Episode++
+	rule.State.LastFiredAt = &now
+	return true, true
+}
+
+func (m *AlertMonitor) saveState(ctx context.Context, rule *models.AlertRule) {
+	if err := m.repo.SaveAlertRuleState(ctx, rule.ID, &rule.State); err != nil {
+		logger.Error().Err(err).Int("rule_id", rule.ID).Msg("Failed to save alert rule state")
+	}
+}
+
+// checkDefaultThreshold fires when the tick-to-tick change exceeds the
+// default threshold. Like a rule, it then stays disarmed until a tick's
+// change is back inside the threshold, so a sustained move alerts once.
+func (m *AlertMonitor) checkDefaultThreshold(ctx context.Context, stock *models.Stock) {
+	if stock.PreviousPrice == 0 {
+		return
+	}
+
+	pctChange := stock.CalculatePriceChange()
+	result := evaluation{
+		triggered: pctChange > m.threshold || pctChange < -m.threshold,
+		value:     pctChange,
+	}
+	now := time.Now()
+
+	m.defaultsMu.Lock()
+	rule, ok := m.defaults[stock.Symbol]
+	if !ok {
+		rule = &models.AlertRule{Threshold: m.threshold, State: models.AlertRuleState{Armed: true}}
+		m.defaults[stock.Symbol] = rule
+	}
+	fired, _ := m.transition(rule, stock.Symbol, result, now)
+	m.defaultsMu.Unlock()
+
+	if !fired {
+		return
+	}
+
+	alertType := "price_increase"
+	if pctChange < 0 {
+		alertType = "price_decrease"
+	}
+
+	message := fmt.Sprintf("%s changed by %.2f%% (from $%.2f to $%.2f)",
+		stock.Symbol, pctChange, stock.PreviousPrice, stock.CurrentPrice)
+
+	m.fire(ctx, &models.Alert{
+		StockID:   stock.ID,
+		Symbol:    stock.Symbol,
+		AlertType: alertType,
+		Threshold: m.threshold,
+		Message:   message,
+		// One alert per crossing, told apart by the tick that started it
+		DedupKey:    fmt.Sprintf("%s:%s:%d", stock.Symbol, alertType, stock.LastUpdated.UnixMicro()),
+		TriggeredAt: now,
+	})
+}
+
+// fire records an alert and fans it out. Alerts whose dedup key is already
+// stored, e.g. by another tracker instance, are dropped.
+func (m *AlertMonitor) fire(ctx context.Context, alert *models.Alert) {
+	alert.State = models.AlertOpen
+
+	// Save alert to database
+	err := m.repo.SaveAlert(ctx, alert)
+	if errors.Is(err, repository.ErrDuplicateAlert) {
+		m.metrics.AlertsSuppressed.WithLabelValues(alert.Symbol, "duplicate").Inc()
+		logger.Debug().Str("dedup_key", alert.DedupKey).Msg("Alert already recorded")
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("symbol", alert.Symbol).Msg("Failed to save alert to database")
+	}
+
+	m.metrics.AlertsTriggered.WithLabelValues(alert.Symbol, alert.AlertType).Inc()
+
+	// Fan the alert out to WebSocket clients
+	if err := m.publisher.Publish(ctx, events.TypeAlert, alert); err != nil {
+		logger.Error().Err(err).Str("symbol", alert.Symbol).Msg("Failed to publish alert")
+	}
+
+	select {
+	case m.alertChan <- alert:
+	default:
+		logger.Warn().Msg("Alert channel full, dropping alert")
 	}
 }
 
+// Close notifies queued alerts, then stops the dispatcher, abandoning
+// pending retries.
 func (m *AlertMonitor) Close() {
 	close(m.alertChan)
+	m.drained.Wait()
+	m.dispatcher.Close()
 }
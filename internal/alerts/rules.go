@@ -0,0 +1,113 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"time"
+)
+
+// evaluation is the outcome of checking one rule against a stock.
+type evaluation struct {
+	// triggered is set when the rule's condition holds.
+	triggered bool
+	// value is what the rule compares with its threshold: a price or a
+	// percent change. Expression rules have 1 while they hold, 0 otherwise.
+	value float64
+	// message describes the condition for the alert record.
+	message string
+}
+
+// evaluateRule checks a rule against the stock's current state. ok is false
+// when the rule cannot be evaluated yet, e.g. there is no price old enough
+// for a window rule.
+func (m *AlertMonitor) evaluateRule(ctx context.Context, rule *models.AlertRule, stock *models.Stock) (evaluation, bool, error) {
+	price := stock.CurrentPrice
+
+	switch rule.RuleType {
+	case models.RulePriceAbove:
+		return evaluation{
+			triggered: price >= rule.Threshold,
+			value:     price,
+			message:   fmt.Sprintf("%s is at $%.2f, at or above $%.2f", stock.Symbol, price, rule.Threshold),
+		}, true, nil
+
+	case models.RulePriceBelow:
+		return evaluation{
+			triggered: price <= rule.Threshold,
+			value:     price,
+			message:   fmt.Sprintf("%s is at $%.2f, at or below $%.2f", stock.Symbol, price, rule.Threshold),
+		}, true, nil
+
+	case models.RuleChangeOverWindow:
+		ref, err := m.repo.GetPriceAt(ctx, stock.Symbol, time.Now().Add(-rule.Window()))
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && ref.Price == 0) {
+			// Not enough history yet.
+			return evaluation{}, false, nil
+		}
+		if err != nil {
+			return evaluation{}, false, fmt.Errorf("failed to load price %s ago: %w", rule.Window(), err)
+		}
+		change := (price - ref.Price) / ref.Price * 100
+		return evaluation{
+			triggered: math.Abs(change) >= rule.Threshold,
+			value:     change,
+			message: fmt.Sprintf("%s changed by %.2f%% over %s (from $%.2f to $%.2f)",
+				stock.Symbol, change, rule.Window(), ref.Price, price),
+		}, true, nil
+
+	case models.RuleChangeFromClose:
+		return evaluation{
+			triggered: math.Abs(stock.ChangePercent) >= rule.Threshold,
+			value:     stock.ChangePercent,
+			message:   fmt.Sprintf("%s changed by %.2f%% from the previous close", stock.Symbol, stock.ChangePercent),
+		}, true, nil
+
+	case models.RuleVolumeSpike:
+		ratio, ok := stock.VolumeRatio()
+		if !ok {
+			// No average volume yet.
+			return evaluation{}, false, nil
+		}
+		return evaluation{
+			triggered: ratio >= rule.Threshold,
+			value:     ratio,
+			message: fmt.Sprintf("%s volume %d is %.1fx its average daily volume of %.0f",
+				stock.Symbol, stock.Volume, ratio, stock.AvgVolume),
+		}, true, nil
+
+	case models.RuleSMACross, models.RuleEMACross, models.RuleMACDCross,
+		models.RuleRSIAbove, models.RuleRSIBelow, models.RuleBollingerBreakout:
+		return m.evaluateIndicator(ctx, rule, stock)
+
+	case models.RuleExpression:
+		return m.evaluateExpression(ctx, rule, stock)
+
+	default:
+		return evaluation{}, false, fmt.Errorf("unknown rule type %q", rule.RuleType)
+	}
+}
+
+// rearms reports whether value is far enough back inside the rule's
+// threshold, by its hysteresis, for the rule to fire again.
+func rearms(rule *models.AlertRule, value float64) bool {
+	if rule.IsCrossing() {
+		// value is the distance past the line; it must be back on the
+		// other side.
+		return value < -rule.Hysteresis
+	}
+
+	switch rule.RuleType {
+	case models.RuleExpression:
+		return value == 0
+	case models.RulePriceAbove, models.RuleRSIAbove:
+		return value < rule.Threshold-rule.Hysteresis
+	case models.RulePriceBelow, models.RuleRSIBelow:
+		return value > rule.Threshold+rule.Hysteresis
+	default:
+		return math.Abs(value) < rule.Threshold-rule.Hysteresis
+	}
+}
@@ -0,0 +1,64 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"stock-tracker/internal/events"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/notify"
+	"stock-tracker/internal/repository"
+)
+
+// testMetrics is shared because metrics register with the default registry.
+var testMetrics = metrics.New()
+
+func newTestMonitor(repo repository.StockRepository) *AlertMonitor {
+	return NewMonitor(5, testMetrics, repo, repository.NewVolumeAverages(repo), events.NewMemoryBus(), notify.RetryPolicy{})
+}
+
+// priceAtRepo fails GetPriceAt with err.
+type priceAtRepo struct {
+	*repository.MemoryRepository
+	err error
+}
+
+func (r *priceAtRepo) GetPriceAt(ctx context.Context, symbol string, at time.Time) (*models.StockPrice, error) {
+	return nil, r.err
+}
+
+func TestChangeOverWindowErrors(t *testing.T) {
+	rule := &models.AlertRule{RuleType: models.RuleChangeOverWindow, Threshold: 5, WindowSeconds: 3600}
+	stock := models.NewStock("AAPL")
+	stock.CurrentPrice = 100
+
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{"no history", fmt.Errorf("failed to get price: %w", repository.ErrNotFound), nil},
+		{"database down", fmt.Errorf("failed to get price: %w", repository.ErrUnavailable), repository.ErrUnavailable},
+		{"timeout", context.DeadlineExceeded, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMonitor(&priceAtRepo{MemoryRepository: repository.NewMemoryRepository(), err: tt.err})
+
+			_, ok, err := m.evaluateRule(context.Background(), rule, stock)
+			if ok {
+				t.Error("evaluateRule reported an evaluation")
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("evaluateRule error = %v, want none", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("evaluateRule error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
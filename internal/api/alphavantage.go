@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"stock-tracker/internal/metrics"
 	"stock-tracker/internal/models"
-	"stock-tracker/internal/repository"
 	"stock-tracker/pkg/logger"
 	"time"
 )
@@ -22,94 +22,139 @@ type AlphaVantageClient struct {
 	apiKey     string
 	httpClient *http.Client
 	metrics    *metrics.Metrics
-	repo       repository.StockRepository
 }
 
 type globalQuoteResponse struct {
+	Note        string `json:"Note"`
+	Information string `json:"Information"`
 	GlobalQuote struct {
 		Symbol           string `json:"01. symbol"`
 		Price            string `json:"05. price"`
+		Volume           string `json:"06. volume"`
 		Change           string `json:"09. change"`
 		ChangePercent    string `json:"10. change percent"`
 		LatestTradingDay string `json:"07. latest trading day"`
 	} `json:"Global Quote"`
 }
 
-func NewClient(apiKey string, m *metrics.Metrics, repo repository.StockRepository) *AlphaVantageClient {
+func NewClient(apiKey string, m *metrics.Metrics) *AlphaVantageClient {
 	return &AlphaVantageClient{
 		apiKey: apiKey,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 		metrics: m,
-		repo:    repo,
+	}
+}
+
+func (c *AlphaVantageClient) Name() string {
+	return providerName
+}
+
+// Capabilities reports the free-tier limits; premium keys can raise them
+// through configuration.
+func (c *AlphaVantageClient) Capabilities() Capabilities {
+	return Capabilities{
+		RealtimeQuotes:    true,
+		DailyHistory:      true,
+		IntradayBars:      true,
+		RequestsPerMinute: 5,
+		RequestsPerDay:    25,
 	}
 }
 
 func (c *AlphaVantageClient) GetQuote(ctx context.Context, symbol string) (*models.Stock, error) {
 	start := time.Now()
 
-	logger.Debug().Str("symbol", symbol).Str("provider", providerName).Msg("Fetching quote from API")
+	logger.Debug().Str("symbol", symbol).Str("provider", c.Name()).Msg("Fetching quote from API")
+
+	body, err := c.get(ctx, symbol, url.Values{"function": {"GLOBAL_QUOTE"}})
+	if err != nil {
+		return nil, err
+	}
+	duration := time.Since(start).Seconds()
+
+	var data globalQuoteResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "parse_error").Inc()
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse JSON response")
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	if data.Note != "" || data.Information != "" {
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "rate_limited").Inc()
+		logger.Warn().Str("symbol", symbol).Str("note", data.Note+data.Information).Msg("API limit reached")
+		return nil, fmt.Errorf("%s: %w", c.Name(), ErrRateLimited)
+	}
+
+	if data.GlobalQuote.Symbol == "" {
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "invalid_symbol").Inc()
+		logger.Warn().Str("symbol", symbol).Msg("Invalid symbol")
+		return nil, fmt.Errorf("%s: %w", c.Name(), ErrSymbolNotFound)
+	}
+
+	stock, err := c.parseResponse(&data, symbol)
+	if err != nil {
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "parse_error").Inc()
+		return nil, err
+	}
+
+	c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "success").Inc()
+	logger.Info().Str("symbol", symbol).Float64("price", stock.CurrentPrice).Float64("change_percent", stock.ChangePercent).Float64("duration_seconds", duration).Msg("Successfully fetched stock quote")
+
+	return stock, nil
+}
+
+// get calls the API with the given parameters and returns the response
+// body, recording the call in the API metrics. Calls that return a body
+// are only counted once the caller has checked it.
+func (c *AlphaVantageClient) get(ctx context.Context, symbol string, params url.Values) ([]byte, error) {
+	start := time.Now()
+
+	params.Set("symbol", symbol)
+	params.Set("apikey", c.apiKey)
 
-	url := fmt.Sprintf("%s?function=GLOBAL_QUOTE&symbol=%s&apikey=%s", baseURL, symbol, c.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
 
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.httpClient.Do(req)
 	duration := time.Since(start).Seconds()
 
-	c.metrics.APICallDuration.WithLabelValues(providerName, symbol).Observe(duration)
+	c.metrics.APICallDuration.WithLabelValues(c.Name(), symbol).Observe(duration)
 
 	if err != nil {
-		c.metrics.APICallsTotal.WithLabelValues(providerName, symbol, "error").Inc()
-		c.metrics.APICallErrors.WithLabelValues(providerName, symbol, "network_error").Inc()
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "network_error").Inc()
 		logger.Error().Err(err).Str("symbol", symbol).Float64("duration_seconds", duration).Msg("Failed to fetch data from API")
 		return nil, fmt.Errorf("failed to fetch data: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		c.metrics.APICallsTotal.WithLabelValues(providerName, symbol, "error").Inc()
-		c.metrics.APICallErrors.WithLabelValues(providerName, symbol, "http_error").Inc()
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "http_error").Inc()
 		logger.Error().Int("status_code", resp.StatusCode).Str("symbol", symbol).Msg("API returned non-200 status code")
 		return nil, fmt.Errorf("API returned status code: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.metrics.APICallsTotal.WithLabelValues(providerName, symbol, "error").Inc()
-		c.metrics.APICallErrors.WithLabelValues(providerName, symbol, "read_error").Inc()
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "read_error").Inc()
 		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to read response body")
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	var data globalQuoteResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		c.metrics.APICallsTotal.WithLabelValues(providerName, symbol, "error").Inc()
-		c.metrics.APICallErrors.WithLabelValues(providerName, symbol, "parse_error").Inc()
-		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse JSON response")
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
-	}
-
-	if data.GlobalQuote.Symbol == "" {
-		c.metrics.APICallsTotal.WithLabelValues(providerName, symbol, "error").Inc()
-		c.metrics.APICallErrors.WithLabelValues(providerName, symbol, "invalid_symbol").Inc()
-		logger.Warn().Str("symbol", symbol).Msg("Invalid symbol or API limit reached")
-		return nil, fmt.Errorf("invalid symbol or API limit reached")
-	}
-
-	stock, err := c.parseResponse(ctx, &data, symbol)
-	if err != nil {
-		c.metrics.APICallsTotal.WithLabelValues(providerName, symbol, "error").Inc()
-		c.metrics.APICallErrors.WithLabelValues(providerName, symbol, "parse_error").Inc()
-		return nil, err
-	}
-
-	c.metrics.APICallsTotal.WithLabelValues(providerName, symbol, "success").Inc()
-	logger.Info().Str("symbol", symbol).Float64("price", stock.CurrentPrice).Float64("change_percent", stock.ChangePercent).Float64("duration_seconds", duration).Msg("Successfully fetched stock quote")
-
-	return stock, nil
+	return body, nil
 }
 
-func (c *AlphaVantageClient) parseResponse(ctx context.Context, data *globalQuoteResponse, symbol string) (*models.Stock, error) {
+func (c *AlphaVantageClient) parseResponse(data *globalQuoteResponse, symbol string) (*models.Stock, error) {
 	var price float64
 	if _, err := fmt.Sscanf(data.GlobalQuote.Price, "%f", &price); err != nil {
 		logger.Error().Err(err).Str("symbol", symbol).Str("price_string", data.GlobalQuote.Price).Msg("Failed to parse price")
@@ -122,36 +167,18 @@ func (c *AlphaVantageClient) parseResponse(ctx context.Context, data *globalQuot
 		fmt.Sscanf(changePercentStr[:len(changePercentStr)-1], "%f", &changePercent)
 	}
 
-	stock := models.NewStock(symbol)
-	stock.UpdatePrice(price, changePercent)
-
-	// Get or create stock in database
-	dbStock, err := c.repo.GetStock(ctx, symbol)
-	if err != nil {
-		// Stock doesn't exist, create it
-		if err := c.repo.CreateStock(ctx, stock); err != nil {
-			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to create stock in database")
-		} else {
-			dbStock = stock
+	// Volume traded so far in the latest trading day
+	var volume int64
+	if data.GlobalQuote.Volume != "" {
+		if _, err := fmt.Sscanf(data.GlobalQuote.Volume, "%d", &volume); err != nil {
+			logger.Warn().Err(err).Str("symbol", symbol).Str("volume_string", data.GlobalQuote.Volume).Msg("Failed to parse volume")
 		}
 	}
 
-	if dbStock != nil {
-		stock.ID = dbStock.ID
-
-		// Save price history
-		priceRecord := &models.StockPrice{
-			StockID:       stock.ID,
-			Symbol:        symbol,
-			Price:         price,
-			ChangePercent: changePercent,
-			Timestamp:     time.Now(),
-		}
-
-		if err := c.repo.SavePrice(ctx, priceRecord); err != nil {
-			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to save price to database")
-		}
-	}
+	stock := models.NewStock(symbol)
+	stock.UpdatePrice(price, changePercent)
+	stock.Volume = volume
+	stock.Provider = c.Name()
 
 	return stock, nil
 }
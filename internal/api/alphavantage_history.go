@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"stock-tracker/internal/models"
+	"stock-tracker/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compactBars is how many bars a compact time series response holds.
+const compactBars = 100
+
+// compactDays is roughly how far back the bars of a compact
+// TIME_SERIES_DAILY response reach.
+const compactDays = 140
+
+// avIntervals maps bar intervals to Alpha Vantage's names.
+var avIntervals = map[string]string{
+	models.Bar1Min:  "1min",
+	models.Bar5Min:  "5min",
+	models.Bar15Min: "15min",
+	models.Bar1Hour: "60min",
+}
+
+type avBarFields struct {
+	Open   string `json:"1. open"`
+	High   string `json:"2. high"`
+	Low    string `json:"3. low"`
+	Close  string `json:"4. close"`
+	Volume string `json:"5. volume"`
+}
+
+// GetDailyBars fetches TIME_SERIES_DAILY. Ranges reaching back further
+// than the compact response request the full history.
+func (c *AlphaVantageClient) GetDailyBars(ctx context.Context, symbol string, from, to time.Time) ([]*models.PriceBar, error) {
+	outputSize := "compact"
+	if time.Since(from) > compactDays*24*time.Hour {
+		outputSize = "full"
+	}
+
+	params := url.Values{"function": {"TIME_SERIES_DAILY"}, "outputsize": {outputSize}}
+	return c.getSeries(ctx, symbol, models.BarDaily, params, time.DateOnly, func(t time.Time) bool {
+		return !t.Before(from) && !t.After(to)
+	})
+}
+
+// GetIntradayBars fetches TIME_SERIES_INTRADAY, including extended hours.
+// Requests reaching back further than the compact response ask for the
+// full output, which covers about a month.
+func (c *AlphaVantageClient) GetIntradayBars(ctx context.Context, symbol, interval string, since time.Time) ([]*models.PriceBar, error) {
+	avInterval, ok := avIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("%s: %q intraday bars: %w", c.Name(), interval, ErrUnsupported)
+	}
+	length, _ := models.BarDuration(interval)
+
+	outputSize := "compact"
+	if time.Since(since) > compactBars*length {
+		outputSize = "full"
+	}
+
+	params := url.Values{"function": {"TIME_SERIES_INTRADAY"}, "interval": {avInterval}, "outputsize": {outputSize}}
+	return c.getSeries(ctx, symbol, interval, params, time.DateTime, func(t time.Time) bool {
+		return !t.Before(since)
+	})
+}
+
+// getSeries fetches a time series and returns the bars keep accepts,
+// oldest first. layout is the format of the series' timestamps, which are
+// in the time zone named in the response's metadata.
+func (c *AlphaVantageClient) getSeries(ctx context.Context, symbol, interval string, params url.Values, layout string, keep func(time.Time) bool) ([]*models.PriceBar, error) {
+	start := time.Now()
+
+	logger.Debug().Str("symbol", symbol).Str("provider", c.Name()).Str("function", params.Get("function")).Msg("Fetching time series from API")
+
+	body, err := c.get(ctx, symbol, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "parse_error").Inc()
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse JSON response")
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var note, information, errorMessage string
+	var meta map[string]string
+	var series map[string]avBarFields
+	for key, value := range fields {
+		var err error
+		switch {
+		case key == "Note":
+			err = json.Unmarshal(value, &note)
+		case key == "Information":
+			err = json.Unmarshal(value, &information)
+		case key == "Error Message":
+			err = json.Unmarshal(value, &errorMessage)
+		case key == "Meta Data":
+			err = json.Unmarshal(value, &meta)
+		case strings.HasPrefix(key, "Time Series"):
+			err = json.Unmarshal(value, &series)
+		}
+		if err != nil {
+			c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+			c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "parse_error").Inc()
+			return nil, fmt.Errorf("failed to parse %q: %w", key, err)
+		}
+	}
+
+	if err := c.checkSeriesResponse(symbol, note, information, errorMessage, series == nil); err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	for key, value := range meta {
+		if strings.HasSuffix(key, "Time Zone") {
+			if loc, err = time.LoadLocation(value); err != nil {
+				c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+				c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "parse_error").Inc()
+				return nil, fmt.Errorf("unknown time zone %q: %w", value, err)
+			}
+		}
+	}
+	if interval == models.BarDaily {
+		// Daily bars are keyed by their trading day, whatever the zone.
+		loc = time.UTC
+	}
+
+	bars := make([]*models.PriceBar, 0, len(series))
+	for stamp, barFields := range series {
+		t, err := time.ParseInLocation(layout, stamp, loc)
+		if err != nil || !keep(t) {
+			continue
+		}
+		bar, err := barFields.bar(symbol, interval, t.UTC())
+		if err != nil {
+			c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+			c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "parse_error").Inc()
+			return nil, fmt.Errorf("failed to parse bar at %s: %w", stamp, err)
+		}
+		bar.Provider = c.Name()
+		bars = append(bars, bar)
+	}
+	slices.SortFunc(bars, func(a, b *models.PriceBar) int { return a.Timestamp.Compare(b.Timestamp) })
+
+	c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "success").Inc()
+	logger.Info().Str("symbol", symbol).Str("interval", interval).Int("bars", len(bars)).Float64("duration_seconds", time.Since(start).Seconds()).Msg("Successfully fetched price bars")
+
+	return bars, nil
+}
+
+// rateLimitNotices are phrases of Alpha Vantage's rate limit messages.
+// Those also advertise the premium plans, so they must be told apart from
+// the messages about premium-only requests before looking for "premium".
+var rateLimitNotices = []string{"rate limit", "call frequency", "requests per day", "requests per minute"}
+
+func isRateLimitNotice(message string) bool {
+	message = strings.ToLower(message)
+	return slices.ContainsFunc(rateLimitNotices, func(phrase string) bool {
+		return strings.Contains(message, phrase)
+	})
+}
+
+// checkSeriesResponse turns the messages Alpha Vantage sends instead of a
+// time series into errors.
+func (c *AlphaVantageClient) checkSeriesResponse(symbol, note, information, errorMessage string, missing bool) error {
+	switch {
+	case !isRateLimitNotice(information) && strings.Contains(strings.ToLower(information), "premium"):
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "premium_only").Inc()
+		logger.Warn().Str("symbol", symbol).Str("note", information).Msg("API request needs a premium plan")
+		return fmt.Errorf("%s: %w: %s", c.Name(), ErrUnsupported, information)
+
+	case note != "" || information != "":
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "rate_limited").Inc()
+		logger.Warn().Str("symbol", symbol).Str("note", note+information).Msg("API limit reached")
+		return fmt.Errorf("%s: %w", c.Name(), ErrRateLimited)
+
+	case errorMessage != "" || missing:
+		c.metrics.APICallsTotal.WithLabelValues(c.Name(), symbol, "error").Inc()
+		c.metrics.APICallErrors.WithLabelValues(c.Name(), symbol, "invalid_symbol").Inc()
+		logger.Warn().Str("symbol", symbol).Str("error", errorMessage).Msg("Invalid symbol")
+		return fmt.Errorf("%s: %w", c.Name(), ErrSymbolNotFound)
+	}
+	return nil
+}
+
+func (f avBarFields) bar(symbol, interval string, timestamp time.Time) (*models.PriceBar, error) {
+	bar := &models.PriceBar{Symbol: symbol, Interval: interval, Timestamp: timestamp}
+
+	for _, field := range []struct {
+		value string
+		dst   *float64
+	}{
+		{f.Open, &bar.Open}, {f.High, &bar.High}, {f.Low, &bar.Low}, {f.Close, &bar.Close},
+	} {
+		v, err := strconv.ParseFloat(field.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price %q", field.value)
+		}
+		*field.dst = v
+	}
+
+	volume, err := strconv.ParseInt(f.Volume, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume %q", f.Volume)
+	}
+	bar.Volume = volume
+
+	return bar, nil
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSymbolNotFound means the provider answered but does not know the symbol.
+	ErrSymbolNotFound = errors.New("invalid symbol")
+	// ErrRateLimited means the provider rejected the call because a request
+	// quota was exceeded.
+	ErrRateLimited = errors.New("API limit reached")
+	// ErrUnsupported means the provider doesn't offer the requested data,
+	// or not on the current plan.
+	ErrUnsupported = errors.New("not supported by provider")
+	// ErrThrottled means the call was never sent because the local rate
+	// limiter did not admit it in time. It also matches ErrRateLimited.
+	ErrThrottled = fmt.Errorf("request throttled: %w", ErrRateLimited)
+)
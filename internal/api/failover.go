@@ -0,0 +1,264 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	failoverName = "failover"
+
+	// healthAlpha is the weight of the newest call in the moving averages.
+	healthAlpha = 0.2
+	// demoteAfterFailures consecutive failures demote a provider even if its
+	// long-run error rate still looks fine.
+	demoteAfterFailures = 3
+	// demoteErrorRate is the moving-average error rate above which a
+	// provider is demoted.
+	demoteErrorRate = 0.5
+	// latencyReference is the call latency that halves a provider's score.
+	latencyReference = 5 * time.Second
+
+	minDemotion = time.Minute
+	maxDemotion = 30 * time.Minute
+)
+
+// FailoverProvider tries an ordered list of providers until one returns a
+// quote. Providers that keep failing are demoted behind the healthy ones
+// for a cooldown that doubles on every consecutive demotion, so a vendor
+// whose quota ran out is not hit first on every request.
+type FailoverProvider struct {
+	providers []QuoteProvider
+	health    map[string]*providerHealth
+	metrics   *metrics.Metrics
+	mu        sync.Mutex
+}
+
+type providerHealth struct {
+	errorRate    float64
+	latency      float64
+	failures     int
+	demotions    int
+	demotedUntil time.Time
+}
+
+// score is in [0, 1]; 1 means no recent errors and negligible latency.
+func (h *providerHealth) score() float64 {
+	return (1 - h.errorRate) / (1 + h.latency/latencyReference.Seconds())
+}
+
+func NewFailoverProvider(providers []QuoteProvider, m *metrics.Metrics) *FailoverProvider {
+	health := make(map[string]*providerHealth, len(providers))
+	for _, p := range providers {
+		health[p.Name()] = &providerHealth{}
+		m.ProviderHealthScore.WithLabelValues(p.Name()).Set(1)
+		m.ProviderDemoted.WithLabelValues(p.Name()).Set(0)
+	}
+
+	return &FailoverProvider{
+		providers: providers,
+		health:    health,
+		metrics:   m,
+	}
+}
+
+func (f *FailoverProvider) Name() string {
+	return failoverName
+}
+
+// Capabilities is the union of the member capabilities; request limits add
+// up because each vendor has its own quota.
+func (f *FailoverProvider) Capabilities() Capabilities {
+	var caps Capabilities
+	for _, p := range f.providers {
+		c := p.Capabilities()
+		caps.RealtimeQuotes = caps.RealtimeQuotes || c.RealtimeQuotes
+		caps.DailyHistory = caps.DailyHistory || c.DailyHistory
+		caps.IntradayBars = caps.IntradayBars || c.IntradayBars
+		caps.RequestsPerMinute += c.RequestsPerMinute
+		caps.RequestsPerDay += c.RequestsPerDay
+	}
+	return caps
+}
+
+func (f *FailoverProvider) GetQuote(ctx context.Context, symbol string) (*models.Stock, error) {
+	var errs []error
+	for _, p := range f.ordered() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		stock, err := p.GetQuote(ctx, symbol)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider.
+			return nil, err
+		}
+		f.record(p.Name(), time.Since(start), err)
+
+		if err == nil {
+			stock.Provider = p.Name()
+			return stock, nil
+		}
+
+		errs = append(errs, err)
+		f.metrics.ProviderFailovers.WithLabelValues(p.Name()).Inc()
+		logger.Warn().Err(err).Str("symbol", symbol).Str("provider", p.Name()).Msg("Provider failed, trying next")
+	}
+
+	return nil, fmt.Errorf("all providers failed for %s: %w", symbol, errors.Join(errs...))
+}
+
+// GetDailyBars asks the members with daily history in the same order as
+// GetQuote.
+func (f *FailoverProvider) GetDailyBars(ctx context.Context, symbol string, from, to time.Time) ([]*models.PriceBar, error) {
+	return f.bars(ctx, symbol, "daily history", func(p QuoteProvider) ([]*models.PriceBar, bool, error) {
+		history, ok := p.(HistoryProvider)
+		if !ok || !p.Capabilities().DailyHistory {
+			return nil, false, nil
+		}
+		bars, err := history.GetDailyBars(ctx, symbol, from, to)
+		return bars, true, err
+	})
+}
+
+// GetIntradayBars asks the members with intraday bars in the same order as
+// GetQuote.
+func (f *FailoverProvider) GetIntradayBars(ctx context.Context, symbol, interval string, since time.Time) ([]*models.PriceBar, error) {
+	return f.bars(ctx, symbol, "intraday bars", func(p QuoteProvider) ([]*models.PriceBar, bool, error) {
+		intraday, ok := p.(IntradayProvider)
+		if !ok || !p.Capabilities().IntradayBars {
+			return nil, false, nil
+		}
+		bars, err := intraday.GetIntradayBars(ctx, symbol, interval, since)
+		return bars, true, err
+	})
+}
+
+// bars tries fetch on each provider until one succeeds. fetch returns
+// false for providers without the data.
+func (f *FailoverProvider) bars(ctx context.Context, symbol, what string, fetch func(QuoteProvider) ([]*models.PriceBar, bool, error)) ([]*models.PriceBar, error) {
+	var errs []error
+	for _, p := range f.ordered() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		bars, supported, err := fetch(p)
+		if !supported {
+			continue
+		}
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		f.record(p.Name(), time.Since(start), err)
+
+		if err == nil {
+			return bars, nil
+		}
+
+		errs = append(errs, err)
+		f.metrics.ProviderFailovers.WithLabelValues(p.Name()).Inc()
+		logger.Warn().Err(err).Str("symbol", symbol).Str("provider", p.Name()).Msg("Provider failed, trying next")
+	}
+
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%s: %s: %w", f.Name(), what, ErrUnsupported)
+	}
+	return nil, fmt.Errorf("all providers failed for %s: %w", symbol, errors.Join(errs...))
+}
+
+// QuotaExhaustedUntil reports exhaustion only when every member is out of
+// quota, returning the earliest reset.
+func (f *FailoverProvider) QuotaExhaustedUntil() (time.Time, bool) {
+	var earliest time.Time
+	for _, p := range f.providers {
+		reporter, ok := p.(QuotaReporter)
+		if !ok {
+			return time.Time{}, false
+		}
+		until, exhausted := reporter.QuotaExhaustedUntil()
+		if !exhausted {
+			return time.Time{}, false
+		}
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+	return earliest, true
+}
+
+// ordered returns the providers in configured order with currently demoted
+// ones moved to the back, soonest-to-recover first.
+func (f *FailoverProvider) ordered() []QuoteProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	ordered := make([]QuoteProvider, len(f.providers))
+	copy(ordered, f.providers)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		hi, hj := f.health[ordered[i].Name()], f.health[ordered[j].Name()]
+		di, dj := now.Before(hi.demotedUntil), now.Before(hj.demotedUntil)
+		if di != dj {
+			return !di
+		}
+		return di && hi.demotedUntil.Before(hj.demotedUntil)
+	})
+
+	return ordered
+}
+
+func (f *FailoverProvider) record(name string, latency time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h := f.health[name]
+
+	// An unknown symbol is a valid answer, not a sign of an unhealthy vendor.
+	failed := err != nil && !errors.Is(err, ErrSymbolNotFound)
+
+	outcome := 0.0
+	if failed {
+		outcome = 1
+		h.failures++
+	} else {
+		h.failures = 0
+	}
+	h.errorRate = healthAlpha*outcome + (1-healthAlpha)*h.errorRate
+	h.latency = healthAlpha*latency.Seconds() + (1-healthAlpha)*h.latency
+
+	now := time.Now()
+	demoted := now.Before(h.demotedUntil)
+
+	switch {
+	case failed && !demoted && (h.failures >= demoteAfterFailures || h.errorRate > demoteErrorRate || errors.Is(err, ErrRateLimited)):
+		cooldown := minDemotion << h.demotions
+		if cooldown > maxDemotion || cooldown <= 0 {
+			cooldown = maxDemotion
+		}
+		h.demotions++
+		h.demotedUntil = now.Add(cooldown)
+		f.metrics.ProviderDemoted.WithLabelValues(name).Set(1)
+		logger.Warn().
+			Str("provider", name).
+			Float64("error_rate", h.errorRate).
+			Dur("cooldown", cooldown).
+			Msg("Demoting unhealthy quote provider")
+	case !failed:
+		h.demotions = 0
+		h.demotedUntil = time.Time{}
+		f.metrics.ProviderDemoted.WithLabelValues(name).Set(0)
+	}
+
+	f.metrics.ProviderHealthScore.WithLabelValues(name).Set(h.score())
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/ratelimit"
+	"stock-tracker/pkg/config"
+	"time"
+)
+
+// Capabilities describes what a quote provider supports, so callers can
+// enable features per vendor without type-switching on the implementation.
+type Capabilities struct {
+	RealtimeQuotes    bool `json:"realtime_quotes"`
+	DailyHistory      bool `json:"daily_history"`
+	IntradayBars      bool `json:"intraday_bars"`
+	RequestsPerMinute int  `json:"requests_per_minute"`
+	RequestsPerDay    int  `json:"requests_per_day"`
+}
+
+// QuoteProvider is a source of stock quotes.
+type QuoteProvider interface {
+	// Name identifies the provider in logs and metric labels.
+	Name() string
+	GetQuote(ctx context.Context, symbol string) (*models.Stock, error)
+	Capabilities() Capabilities
+}
+
+// HistoryProvider is implemented by providers that can return past prices.
+type HistoryProvider interface {
+	// GetDailyBars returns the daily bars of the trading days from from to
+	// to, inclusive, oldest first.
+	GetDailyBars(ctx context.Context, symbol string, from, to time.Time) ([]*models.PriceBar, error)
+}
+
+// IntradayProvider is implemented by providers with intraday bars.
+type IntradayProvider interface {
+	// GetIntradayBars returns the bars of one of models.IntradayIntervals
+	// starting at or after since, oldest first. The newest bar may still be
+	// in progress.
+	GetIntradayBars(ctx context.Context, symbol, interval string, since time.Time) ([]*models.PriceBar, error)
+}
+
+// QuotaReporter is implemented by providers that know when their daily
+// request quota is used up.
+type QuotaReporter interface {
+	QuotaExhaustedUntil() (until time.Time, exhausted bool)
+}
+
+// NewProvider builds the quote providers selected in the configuration.
+// More than one provider is wrapped in a FailoverProvider that tries them in
+// the configured order. Their rate limits are kept in quotas, normally the
+// repository, so every process using it shares them.
+func NewProvider(cfg *config.Config, quotas ratelimit.Store, m *metrics.Metrics) (QuoteProvider, error) {
+	if len(cfg.Providers) == 0 {
+		return nil, fmt.Errorf("no quote provider configured")
+	}
+
+	providers := make([]QuoteProvider, 0, len(cfg.Providers))
+	seen := make(map[string]bool, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		if seen[name] {
+			return nil, fmt.Errorf("quote provider %s listed more than once", name)
+		}
+		seen[name] = true
+
+		provider, err := newProvider(name, cfg, m)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, withRateLimit(provider, cfg, quotas, m))
+	}
+
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return NewFailoverProvider(providers, m), nil
+}
+
+func newProvider(name string, cfg *config.Config, m *metrics.Metrics) (QuoteProvider, error) {
+	switch name {
+	case providerName:
+		return NewClient(cfg.APIKey, m), nil
+	default:
+		return nil, fmt.Errorf("unknown quote provider: %s", name)
+	}
+}
+
+// withRateLimit wraps a provider in a limiter sized from the configuration,
+// falling back to the limits the provider advertises. FetchTimeout bounds
+// each call once the limiter has let it through.
+func withRateLimit(provider QuoteProvider, cfg *config.Config, quotas ratelimit.Store, m *metrics.Metrics) QuoteProvider {
+	caps := provider.Capabilities()
+	limit := cfg.RateLimits[provider.Name()]
+	if limit.RequestsPerMinute == 0 {
+		limit.RequestsPerMinute = caps.RequestsPerMinute
+	}
+	if limit.RequestsPerDay == 0 {
+		limit.RequestsPerDay = caps.RequestsPerDay
+	}
+
+	limiter := ratelimit.New(provider.Name(), limit.RequestsPerMinute, limit.RequestsPerDay, quotas, m)
+	return NewRateLimitedProvider(provider, limiter, cfg.FetchTimeout)
+}
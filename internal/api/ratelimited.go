@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/ratelimit"
+	"time"
+)
+
+// RateLimitedProvider makes every call to the wrapped provider wait for
+// its rate limiter, so all callers share one budget. Waiting is bounded by
+// the caller's context only; the call itself, once allowed, by timeout.
+type RateLimitedProvider struct {
+	QuoteProvider
+	limiter *ratelimit.Limiter
+	timeout time.Duration
+}
+
+// NewRateLimitedProvider wraps provider in limiter. A zero timeout leaves
+// calls bounded by the caller's context only.
+func NewRateLimitedProvider(provider QuoteProvider, limiter *ratelimit.Limiter, timeout time.Duration) *RateLimitedProvider {
+	return &RateLimitedProvider{QuoteProvider: provider, limiter: limiter, timeout: timeout}
+}
+
+func (p *RateLimitedProvider) GetQuote(ctx context.Context, symbol string) (*models.Stock, error) {
+	callCtx, cancel, err := p.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	stock, err := p.QuoteProvider.GetQuote(callCtx, symbol)
+	if errors.Is(err, ErrRateLimited) {
+		p.limiter.Drain(ctx)
+	}
+	return stock, err
+}
+
+// GetDailyBars waits for the limiter like GetQuote. Providers without
+// history return ErrUnsupported without using up a call.
+func (p *RateLimitedProvider) GetDailyBars(ctx context.Context, symbol string, from, to time.Time) ([]*models.PriceBar, error) {
+	history, ok := p.QuoteProvider.(HistoryProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s: daily history: %w", p.Name(), ErrUnsupported)
+	}
+	callCtx, cancel, err := p.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	bars, err := history.GetDailyBars(callCtx, symbol, from, to)
+	if errors.Is(err, ErrRateLimited) {
+		p.limiter.Drain(ctx)
+	}
+	return bars, err
+}
+
+// GetIntradayBars waits for the limiter like GetQuote.
+func (p *RateLimitedProvider) GetIntradayBars(ctx context.Context, symbol, interval string, since time.Time) ([]*models.PriceBar, error) {
+	intraday, ok := p.QuoteProvider.(IntradayProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s: intraday bars: %w", p.Name(), ErrUnsupported)
+	}
+	callCtx, cancel, err := p.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	bars, err := intraday.GetIntradayBars(callCtx, symbol, interval, since)
+	if errors.Is(err, ErrRateLimited) {
+		p.limiter.Drain(ctx)
+	}
+	return bars, err
+}
+
+// QuotaExhaustedUntil reports whether the daily quota is used up.
+func (p *RateLimitedProvider) QuotaExhaustedUntil() (time.Time, bool) {
+	return p.limiter.ExhaustedUntil()
+}
+
+// wait blocks until the limiter allows a call and returns the context to
+// make it with, whose deadline starts now.
+func (p *RateLimitedProvider) wait(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w: %w", p.Name(), ErrThrottled, err)
+	}
+	if p.timeout <= 0 {
+		return ctx, func() {}, nil
+	}
+	callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	return callCtx, cancel, nil
+}
@@ -0,0 +1,209 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"stock-tracker/internal/events"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultAlertLimit = 50
+
+// AlertStateRequest is the body of alert state changes. By names who made
+// the change and falls back to the X-User header.
+type AlertStateRequest struct {
+	By   string `json:"by,omitempty"`
+	Note string `json:"note,omitempty"`
+	// Until or Duration (e.g. "2h") set the end of a snooze.
+	Until    *time.Time `json:"until,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+}
+
+// parseAlertFilter reads the state, symbol, type, from, to and limit query
+// parameters; from and to are RFC 3339 times.
+func parseAlertFilter(r *http.Request) (repository.AlertFilter, error) {
+	q := r.URL.Query()
+	filter := repository.AlertFilter{
+		Symbol:    normalizeSymbol(q.Get("symbol")),
+		State:     q.Get("state"),
+		AlertType: q.Get("type"),
+		Limit:     defaultAlertLimit,
+	}
+
+	switch filter.State {
+	case "", models.AlertOpen, models.AlertAcknowledged, models.AlertSnoozed, models.AlertResolved:
+	default:
+		return filter, fmt.Errorf("unknown state %q", filter.State)
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := q.Get(bound.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", bound.name)
+			}
+			*bound.dst = t
+		}
+	}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return filter, fmt.Errorf("limit must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// GetAlert returns a single alert
+func (h *Handler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	alert, err := h.repo.GetAlert(r.Context(), id)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Alert not found", "Failed to retrieve alert")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, alert)
+}
+
+// GetAlertHistory returns the audit trail of an alert's state changes
+func (h *Handler) GetAlertHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	changes, err := h.repo.GetAlertStateChanges(r.Context(), id)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Alert not found", "Failed to retrieve alert history")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, changes)
+}
+
+// AcknowledgeAlert marks an alert as being handled
+func (h *Handler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	h.changeAlertState(w, r, models.AlertAcknowledged)
+}
+
+// SnoozeAlert hides an alert until a given time, after which it is open again
+func (h *Handler) SnoozeAlert(w http.ResponseWriter, r *http.Request) {
+	h.changeAlertState(w, r, models.AlertSnoozed)
+}
+
+// ResolveAlert closes an alert
+func (h *Handler) ResolveAlert(w http.ResponseWriter, r *http.Request) {
+	h.changeAlertState(w, r, models.AlertResolved)
+}
+
+// ReopenAlert returns an alert to the open state
+func (h *Handler) ReopenAlert(w http.ResponseWriter, r *http.Request) {
+	h.changeAlertState(w, r, models.AlertOpen)
+}
+
+func (h *Handler) changeAlertState(w http.ResponseWriter, r *http.Request, to string) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	var req AlertStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	by := strings.TrimSpace(req.By)
+	if by == "" {
+		by = strings.TrimSpace(r.Header.Get("X-User"))
+	}
+	if by == "" {
+		h.respondError(w, http.StatusUnprocessableEntity, "by (or the X-User header) is required")
+		return
+	}
+
+	change := &models.AlertStateChange{AlertID: id, ToState: to, ChangedBy: by, Note: req.Note}
+	if to == models.AlertSnoozed {
+		until, err := snoozeDeadline(&req)
+		if err != nil {
+			h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		change.SnoozedUntil = &until
+	}
+
+	alert, err := h.repo.GetAlert(r.Context(), id)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Alert not found", "Failed to retrieve alert")
+		return
+	}
+
+	change.FromState = alert.State
+	if err := models.ValidateTransition(change.FromState, to); err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	alert, err = h.repo.UpdateAlertState(r.Context(), change)
+	if errors.Is(err, repository.ErrAlertStateChanged) {
+		h.respondError(w, http.StatusConflict, "Alert was changed concurrently, retry")
+		return
+	}
+	if err != nil {
+		h.respondRepoError(w, r, err, "Alert not found", "Failed to change alert state")
+		return
+	}
+
+	logger.Info().Int("alert_id", id).Str("from", change.FromState).Str("to", to).Str("by", by).Msg("Alert state changed")
+
+	if err := h.publisher.Publish(r.Context(), events.TypeAlertStateChanged, alert); err != nil {
+		logger.Error().Err(err).Int("alert_id", id).Msg("Failed to publish alert state change")
+	}
+
+	h.respondJSON(w, http.StatusOK, alert)
+}
+
+func snoozeDeadline(req *AlertStateRequest) (time.Time, error) {
+	var until time.Time
+	switch {
+	case req.Until != nil:
+		until = *req.Until
+	case req.Duration != "":
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return until, fmt.Errorf("duration must be a duration such as \"30m\" or \"2h\"")
+		}
+		until = time.Now().Add(d)
+	default:
+		return until, fmt.Errorf("until or duration is required to snooze")
+	}
+
+	if !until.After(time.Now()) {
+		return until, fmt.Errorf("snooze must end in the future")
+	}
+	return until, nil
+}
@@ -1,12 +1,20 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"stock-tracker/internal/api"
+	"stock-tracker/internal/events"
 	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
 	"stock-tracker/internal/repository"
 	"stock-tracker/pkg/logger"
 	"strconv"
+	"strings"
 	"time"
 
 	ws "stock-tracker/internal/api/websocket"
@@ -15,18 +23,37 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// validateTimeout bounds the provider lookup made when a stock is added.
+const validateTimeout = 10 * time.Second
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9.\-]{1,10}$`)
+
+// normalizeSymbol turns a symbol as clients write it into the stored form.
+func normalizeSymbol(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
+
+// symbolVar returns the request's {symbol} path variable, normalized.
+func symbolVar(r *http.Request) string {
+	return normalizeSymbol(mux.Vars(r)["symbol"])
+}
+
 type Handler struct {
-	repo     repository.StockRepository
-	wsHub    *ws.Hub
-	metrics  *metrics.Metrics
-	upgrader websocket.Upgrader
+	repo      repository.StockRepository
+	provider  api.QuoteProvider
+	wsHub     *ws.Hub
+	publisher events.Publisher
+	metrics   *metrics.Metrics
+	upgrader  websocket.Upgrader
 }
 
-func NewHandler(repo repository.StockRepository, wsHub *ws.Hub, m *metrics.Metrics) *Handler {
+func NewHandler(repo repository.StockRepository, provider api.QuoteProvider, wsHub *ws.Hub, publisher events.Publisher, m *metrics.Metrics) *Handler {
 	return &Handler{
-		repo:    repo,
-		wsHub:   wsHub,
-		metrics: m,
+		repo:      repo,
+		provider:  provider,
+		wsHub:     wsHub,
+		publisher: publisher,
+		metrics:   m,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -42,6 +69,40 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+type CreateStockRequest struct {
+	Symbol string `json:"symbol"`
+	Name   string `json:"name,omitempty"`
+}
+
+// UpdateStockRequest holds the fields a PATCH may change; omitted fields
+// are left as they are.
+type UpdateStockRequest struct {
+	Name   *string `json:"name,omitempty"`
+	Active *bool   `json:"active,omitempty"`
+}
+
+// maxConfigBody bounds the bodies of rule and channel requests, which are
+// parsed and validated as they come.
+const maxConfigBody = 64 << 10
+
+// decodeConfigBody decodes a rule or channel request body into v, which
+// must fit in maxConfigBody, and reports whether it could.
+func (h *Handler) decodeConfigBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxConfigBody)
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		h.respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit))
+		return false
+	case err != nil:
+		h.respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return false
+	}
+	return true
+}
+
 func (h *Handler) respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
@@ -53,37 +114,166 @@ func (h *Handler) respondError(w http.ResponseWriter, status int, message string
 	h.respondJSON(w, status, ErrorResponse{Error: http.StatusText(status), Message: message})
 }
 
+// respondRepoError answers a failed repository call with the status its
+// error maps to. notFound is the message of a 404 and failed that of a
+// 500, which is logged; the other statuses explain themselves.
+func (h *Handler) respondRepoError(w http.ResponseWriter, r *http.Request, err error, notFound, failed string) {
+	var validation *repository.ValidationError
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		h.respondError(w, http.StatusNotFound, notFound)
+	case errors.Is(err, repository.ErrConflict):
+		h.respondError(w, http.StatusConflict, "Conflicts with stored data or a concurrent change, retry")
+	case errors.As(err, &validation):
+		h.respondError(w, http.StatusUnprocessableEntity, validation.Message)
+	case errors.Is(err, repository.ErrUnavailable):
+		logger.Warn().Err(err).Str("path", r.URL.Path).Msg("Database unavailable")
+		h.respondError(w, http.StatusServiceUnavailable, "Database unavailable, try again later")
+	default:
+		logger.Error().Err(err).Str("path", r.URL.Path).Msg(failed)
+		h.respondError(w, http.StatusInternalServerError, failed)
+	}
+}
+
 // GetAllStocks returns all tracked stocks
 func (h *Handler) GetAllStocks(w http.ResponseWriter, r *http.Request) {
 	stocks, err := h.repo.GetAllStocks(r.Context())
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to get all stocks")
-		h.respondError(w, http.StatusInternalServerError, "Failed to retrieve stocks")
+		h.respondRepoError(w, r, err, "", "Failed to retrieve stocks")
 		return
 	}
 
 	h.respondJSON(w, http.StatusOK, stocks)
 }
 
-// GetStock returns a specific stock by symbol
+// GetStock returns a specific stock by symbol with its latest price and volume
 func (h *Handler) GetStock(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	symbol := vars["symbol"]
+	symbol := symbolVar(r)
+
+	stock, err := h.repo.GetStock(r.Context(), symbol)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve stock")
+		return
+	}
+
+	// A stock without prices yet is returned as is
+	latest, err := h.repo.GetLatestPrice(r.Context(), symbol)
+	switch {
+	case err == nil:
+		stock.CurrentPrice = latest.Price
+		stock.ChangePercent = latest.ChangePercent
+		stock.Volume = latest.Volume
+		stock.Provider = latest.Provider
+		stock.LastUpdated = latest.Timestamp
+	case !errors.Is(err, repository.ErrNotFound):
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve stock")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, stock)
+}
+
+// CreateStock adds a symbol to the watchlist after checking that the quote
+// provider knows it. A running tracker picks it up on its next cycle.
+func (h *Handler) CreateStock(w http.ResponseWriter, r *http.Request) {
+	var req CreateStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	symbol := normalizeSymbol(req.Symbol)
+	if !symbolPattern.MatchString(symbol) {
+		h.respondError(w, http.StatusUnprocessableEntity, "Symbol must be 1-10 letters, digits, '.' or '-'")
+		return
+	}
+
+	_, err := h.repo.GetStock(r.Context(), symbol)
+	if err == nil {
+		h.respondError(w, http.StatusConflict, "Stock is already in the watchlist")
+		return
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		h.respondRepoError(w, r, err, "", "Failed to add stock")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), validateTimeout)
+	defer cancel()
+
+	if _, err := h.provider.GetQuote(ctx, symbol); err != nil {
+		if errors.Is(err, api.ErrSymbolNotFound) {
+			h.respondError(w, http.StatusUnprocessableEntity, "Unknown symbol "+symbol)
+			return
+		}
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to validate symbol with provider")
+		h.respondError(w, http.StatusServiceUnavailable, "Unable to validate symbol with the quote provider")
+		return
+	}
+
+	stock := models.NewStock(symbol)
+	stock.Name = strings.TrimSpace(req.Name)
+	if err := h.repo.CreateStock(r.Context(), stock); err != nil {
+		h.respondRepoError(w, r, err, "", "Failed to add stock")
+		return
+	}
+
+	logger.Info().Str("symbol", symbol).Msg("Stock added to watchlist")
+	h.respondJSON(w, http.StatusCreated, stock)
+}
+
+// UpdateStock renames a stock or pauses and resumes tracking it
+func (h *Handler) UpdateStock(w http.ResponseWriter, r *http.Request) {
+	symbol := symbolVar(r)
+
+	var req UpdateStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
 
 	stock, err := h.repo.GetStock(r.Context(), symbol)
 	if err != nil {
-		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to get stock")
-		h.respondError(w, http.StatusNotFound, "Stock not found")
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to update stock")
+		return
+	}
+
+	if req.Name != nil {
+		stock.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Active != nil {
+		stock.Active = *req.Active
+	}
+
+	if err := h.repo.UpdateStock(r.Context(), stock); err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to update stock")
 		return
 	}
 
+	logger.Info().Str("symbol", symbol).Bool("active", stock.Active).Msg("Stock updated")
 	h.respondJSON(w, http.StatusOK, stock)
 }
 
+// DeleteStock removes a stock and its history from the watchlist
+func (h *Handler) DeleteStock(w http.ResponseWriter, r *http.Request) {
+	symbol := symbolVar(r)
+
+	if _, err := h.repo.GetStock(r.Context(), symbol); err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to delete stock")
+		return
+	}
+
+	if err := h.repo.DeleteStock(r.Context(), symbol); err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to delete stock")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // GetPriceHistory returns historical prices for a stock
 func (h *Handler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	symbol := vars["symbol"]
+	symbol := symbolVar(r)
 
 	// Parse query parameters
 	limit := 100
@@ -109,49 +299,166 @@ func (h *Handler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
 
 	prices, err := h.repo.GetPriceHistory(r.Context(), symbol, from, to, limit)
 	if err != nil {
-		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to get price history")
-		h.respondError(w, http.StatusInternalServerError, "Failed to retrieve price history")
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve price history")
 		return
 	}
+	if prices == nil {
+		prices = []*models.StockPrice{}
+	}
 
 	h.respondJSON(w, http.StatusOK, prices)
 }
 
-// GetAlerts returns alerts for a stock
-func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	symbol := vars["symbol"]
+// GetBars returns stored OHLCV bars of one interval, newest first
+func (h *Handler) GetBars(w http.ResponseWriter, r *http.Request) {
+	symbol := symbolVar(r)
+	query := r.URL.Query()
 
-	limit := 50
-	if l := r.URL.Query().Get("limit"); l != "" {
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = models.BarDaily
+	}
+	length, ok := models.BarDuration(interval)
+	if !ok {
+		h.respondError(w, http.StatusBadRequest, "interval must be one of 1m, 5m, 15m, 1h or 1d")
+		return
+	}
+
+	limit := 100
+	if l := query.Get("limit"); l != "" {
 		if parsed, err := strconv.Atoi(l); err == nil {
 			limit = parsed
 		}
 	}
 
-	alerts, err := h.repo.GetAlerts(r.Context(), symbol, limit)
+	// By default, the last 100 bars' worth of time
+	to := time.Now()
+	if t := query.Get("to"); t != "" {
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			to = parsed
+		}
+	}
+
+	from := to.Add(-100 * length)
+	if f := query.Get("from"); f != "" {
+		if parsed, err := time.Parse(time.RFC3339, f); err == nil {
+			from = parsed
+		}
+	}
+
+	bars, err := h.repo.GetBars(r.Context(), symbol, interval, from, to, limit)
 	if err != nil {
-		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to get alerts")
-		h.respondError(w, http.StatusInternalServerError, "Failed to retrieve alerts")
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve bars")
 		return
 	}
+	if bars == nil {
+		bars = []*models.PriceBar{}
+	}
 
-	h.respondJSON(w, http.StatusOK, alerts)
+	h.respondJSON(w, http.StatusOK, bars)
 }
 
-// GetRecentAlerts returns recent alerts across all stocks
-func (h *Handler) GetRecentAlerts(w http.ResponseWriter, r *http.Request) {
-	limit := 50
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil {
-			limit = parsed
+// maxCandles caps the buckets one candles request may span.
+const maxCandles = 5000
+
+// GetCandles aggregates stored prices into OHLCV candles, oldest first.
+// Buckets without prices are left out unless fill=true.
+func (h *Handler) GetCandles(w http.ResponseWriter, r *http.Request) {
+	symbol := symbolVar(r)
+	query := r.URL.Query()
+
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = models.Bar1Hour
+	}
+	length, ok := models.BarDuration(interval)
+	if !ok {
+		h.respondError(w, http.StatusBadRequest, "interval must be one of 1m, 5m, 15m, 1h or 1d")
+		return
+	}
+
+	// By default, the last 100 candles' worth of time
+	to := time.Now()
+	if t := query.Get("to"); t != "" {
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "to must be an RFC 3339 time")
+			return
 		}
+		to = parsed
+	}
+
+	from := to.Add(-100 * length)
+	if f := query.Get("from"); f != "" {
+		parsed, err := time.Parse(time.RFC3339, f)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "from must be an RFC 3339 time")
+			return
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		h.respondError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if to.Sub(from)/length > maxCandles {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("range spans more than %d candles; use a larger interval", maxCandles))
+		return
+	}
+
+	candles, err := h.repo.GetCandles(r.Context(), symbol, length, from, to)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve candles")
+		return
+	}
+
+	if query.Get("fill") == "true" {
+		candles = models.FillCandleGaps(candles, length)
+	}
+	if candles == nil {
+		candles = []*models.Candle{}
+	}
+
+	h.respondJSON(w, http.StatusOK, candles)
+}
+
+// GetAlerts returns alerts for a stock, filtered like ListAlerts
+func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	symbol := symbolVar(r)
+
+	filter, err := parseAlertFilter(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Symbol = symbol
+
+	alerts, err := h.repo.ListAlerts(r.Context(), filter)
+	if err == nil && len(alerts) == 0 {
+		// Tell an unknown symbol from a stock without alerts
+		_, err = h.repo.GetStock(r.Context(), symbol)
+	}
+	if err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve alerts")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, alerts)
+}
+
+// ListAlerts returns recent alerts across all stocks, optionally filtered
+// by state, symbol, type and time range
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAlertFilter(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	alerts, err := h.repo.GetRecentAlerts(r.Context(), limit)
+	alerts, err := h.repo.ListAlerts(r.Context(), filter)
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to get recent alerts")
-		h.respondError(w, http.StatusInternalServerError, "Failed to retrieve alerts")
+		h.respondRepoError(w, r, err, "", "Failed to retrieve alerts")
 		return
 	}
 
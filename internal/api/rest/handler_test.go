@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"stock-tracker/internal/api"
+	ws "stock-tracker/internal/api/websocket"
+	"stock-tracker/internal/events"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+)
+
+// stubProvider knows every symbol.
+type stubProvider struct{}
+
+func (stubProvider) Name() string { return "stub" }
+
+func (stubProvider) GetQuote(ctx context.Context, symbol string) (*models.Stock, error) {
+	stock := models.NewStock(symbol)
+	stock.CurrentPrice = 100
+	return stock, nil
+}
+
+func (stubProvider) Capabilities() api.Capabilities {
+	return api.Capabilities{RealtimeQuotes: true}
+}
+
+func TestSymbolPathIsNormalized(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	router := SetupRoutes(NewHandler(repo, stubProvider{}, ws.NewHub(), events.NewMemoryBus(), metrics.New()))
+
+	serve := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("POST", "/api/v1/stocks", `{"symbol":" aapl "}`); code != http.StatusCreated {
+		t.Fatalf("POST /stocks = %d, want %d", code, http.StatusCreated)
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/api/v1/stocks/aapl", "", http.StatusOK},
+		{"PATCH", "/api/v1/stocks/aapl", `{"active":false}`, http.StatusOK},
+		{"GET", "/api/v1/stocks/Aapl/history", "", http.StatusOK},
+		{"GET", "/api/v1/stocks/aapl/rules", "", http.StatusOK},
+		{"DELETE", "/api/v1/stocks/aapl", "", http.StatusNoContent},
+		{"GET", "/api/v1/stocks/AAPL", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code := serve(tt.method, tt.path, tt.body); code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/notify"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// secretConfigKeys are channel config fields never echoed back to clients.
+var secretConfigKeys = []string{"secret", "password"}
+
+// redactedValue replaces secrets in responses. Sent back in an update, it
+// keeps the stored value.
+const redactedValue = "********"
+
+// secretKeys returns the secret config fields of a channel type. A chat
+// webhook URL embeds its token, so it is a secret too.
+func secretKeys(channelType string) []string {
+	switch channelType {
+	case models.ChannelSlack, models.ChannelTeams:
+		return append([]string{"url"}, secretConfigKeys...)
+	}
+	return secretConfigKeys
+}
+
+// NotificationChannelRequest is the body of channel create and update
+// calls. On update, omitted fields keep their current value and a config
+// replaces the whole previous one.
+type NotificationChannelRequest struct {
+	Name    *string         `json:"name,omitempty"`
+	Type    *string         `json:"type,omitempty"`
+	Config  json.RawMessage `json:"config,omitempty"`
+	Default *bool           `json:"default,omitempty"`
+	Enabled *bool           `json:"enabled,omitempty"`
+}
+
+type RuleChannelsRequest struct {
+	ChannelIDs []int `json:"channel_ids"`
+}
+
+func (req *NotificationChannelRequest) apply(channel *models.NotificationChannel) {
+	if req.Name != nil {
+		channel.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Type != nil {
+		channel.Type = *req.Type
+	}
+	if len(req.Config) > 0 {
+		channel.Config = req.Config
+	}
+	if req.Default != nil {
+		channel.Default = *req.Default
+	}
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+}
+
+// redact masks secrets in a channel's config.
+func redact(channel *models.NotificationChannel) *models.NotificationChannel {
+	var config map[string]interface{}
+	if err := json.Unmarshal(channel.Config, &config); err != nil {
+		return channel
+	}
+	for _, key := range secretKeys(channel.Type) {
+		if value, ok := config[key].(string); ok && value != "" {
+			config[key] = redactedValue
+		}
+	}
+
+	masked := *channel
+	masked.Config, _ = json.Marshal(config)
+	return &masked
+}
+
+// keepSecrets replaces the redacted secrets of an updated config with the
+// values stored in channel, so a config read back from the API can be
+// sent again unchanged.
+func keepSecrets(updated json.RawMessage, channel *models.NotificationChannel) json.RawMessage {
+	var config, stored map[string]interface{}
+	if err := json.Unmarshal(updated, &config); err != nil {
+		return updated
+	}
+	if err := json.Unmarshal(channel.Config, &stored); err != nil {
+		return updated
+	}
+
+	for _, key := range secretKeys(channel.Type) {
+		if config[key] == redactedValue {
+			config[key] = stored[key]
+		}
+	}
+
+	kept, err := json.Marshal(config)
+	if err != nil {
+		return updated
+	}
+	return kept
+}
+
+// GetNotificationChannels lists all notification channels
+func (h *Handler) GetNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := h.repo.GetNotificationChannels(r.Context())
+	if err != nil {
+		h.respondRepoError(w, r, err, "", "Failed to retrieve notification channels")
+		return
+	}
+
+	for i, channel := range channels {
+		channels[i] = redact(channel)
+	}
+
+	h.respondJSON(w, http.StatusOK, channels)
+}
+
+// CreateNotificationChannel adds a notification channel
+func (h *Handler) CreateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	var req NotificationChannelRequest
+	if !h.decodeConfigBody(w, r, &req) {
+		return
+	}
+
+	channel := &models.NotificationChannel{Enabled: true}
+	req.apply(channel)
+	if channel.Name == "" {
+		h.respondError(w, http.StatusUnprocessableEntity, "name is required")
+		return
+	}
+	if _, err := notify.New(channel); err != nil {
+		h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	err := h.repo.CreateNotificationChannel(r.Context(), channel)
+	if errors.Is(err, repository.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "A notification channel named "+strconv.Quote(channel.Name)+" already exists")
+		return
+	}
+	if err != nil {
+		h.respondRepoError(w, r, err, "", "Failed to create notification channel")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, redact(channel))
+}
+
+// UpdateNotificationChannel changes a notification channel; its type is fixed
+func (h *Handler) UpdateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.channelFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req NotificationChannelRequest
+	if !h.decodeConfigBody(w, r, &req) {
+		return
+	}
+	if req.Type != nil && *req.Type != channel.Type {
+		h.respondError(w, http.StatusUnprocessableEntity, "type cannot be changed")
+		return
+	}
+	if len(req.Config) > 0 {
+		req.Config = keepSecrets(req.Config, channel)
+	}
+
+	req.apply(channel)
+	if channel.Name == "" {
+		h.respondError(w, http.StatusUnprocessableEntity, "name is required")
+		return
+	}
+	if _, err := notify.New(channel); err != nil {
+		h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	err := h.repo.UpdateNotificationChannel(r.Context(), channel)
+	if errors.Is(err, repository.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "A notification channel named "+strconv.Quote(channel.Name)+" already exists")
+		return
+	}
+	if err != nil {
+		h.respondRepoError(w, r, err, "Notification channel not found", "Failed to update notification channel")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, redact(channel))
+}
+
+// DeleteNotificationChannel removes a notification channel and its routes
+func (h *Handler) DeleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.channelFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteNotificationChannel(r.Context(), channel.ID); err != nil {
+		h.respondRepoError(w, r, err, "Notification channel not found", "Failed to delete notification channel")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestNotificationChannel sends a sample alert through a channel once,
+// without retries
+func (h *Handler) TestNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.channelFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	notifier, err := notify.New(channel)
+	if err != nil {
+		h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), validateTimeout)
+	defer cancel()
+
+	alert := &models.Alert{
+		Symbol:      "TEST",
+		AlertType:   "test",
+		Message:     "Test notification from stock-tracker",
+		TriggeredAt: time.Now(),
+	}
+	if err := notifier.Notify(ctx, alert); err != nil {
+		logger.Warn().Err(err).Int("channel_id", channel.ID).Msg("Test notification failed")
+		h.respondError(w, http.StatusBadGateway, "Test notification failed: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRuleChannels lists the channels a rule's alerts are sent to
+func (h *Handler) GetRuleChannels(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	channels, err := h.repo.GetRuleChannels(r.Context(), id)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Alert rule not found", "Failed to retrieve rule channels")
+		return
+	}
+
+	for i, channel := range channels {
+		channels[i] = redact(channel)
+	}
+
+	h.respondJSON(w, http.StatusOK, channels)
+}
+
+// SetRuleChannels replaces the channels a rule's alerts are sent to
+func (h *Handler) SetRuleChannels(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var req RuleChannelsRequest
+	if !h.decodeConfigBody(w, r, &req) {
+		return
+	}
+
+	if _, err := h.repo.GetAlertRule(r.Context(), id); err != nil {
+		h.respondRepoError(w, r, err, "Alert rule not found", "Failed to retrieve alert rule")
+		return
+	}
+	for _, channelID := range req.ChannelIDs {
+		_, err := h.repo.GetNotificationChannel(r.Context(), channelID)
+		if errors.Is(err, repository.ErrNotFound) {
+			h.respondError(w, http.StatusUnprocessableEntity, "Unknown notification channel "+strconv.Itoa(channelID))
+			return
+		}
+		if err != nil {
+			h.respondRepoError(w, r, err, "", "Failed to set rule channels")
+			return
+		}
+	}
+
+	if err := h.repo.SetRuleChannels(r.Context(), id, req.ChannelIDs); err != nil {
+		h.respondRepoError(w, r, err, "Alert rule not found", "Failed to set rule channels")
+		return
+	}
+
+	h.GetRuleChannels(w, r)
+}
+
+// GetAlertDeliveries returns the delivery log of an alert
+func (h *Handler) GetAlertDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	deliveries, err := h.repo.GetDeliveries(r.Context(), id)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Alert not found", "Failed to retrieve alert deliveries")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *Handler) channelFromPath(w http.ResponseWriter, r *http.Request) (*models.NotificationChannel, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid channel ID")
+		return nil, false
+	}
+
+	channel, err := h.repo.GetNotificationChannel(r.Context(), id)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Notification channel not found", "Failed to retrieve notification channel")
+		return nil, false
+	}
+
+	return channel, true
+}
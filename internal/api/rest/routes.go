@@ -17,12 +17,39 @@ func SetupRoutes(handler *Handler) http.Handler {
 
 	// Stock endpoints
 	api.HandleFunc("/stocks", handler.GetAllStocks).Methods("GET")
+	api.HandleFunc("/stocks", handler.CreateStock).Methods("POST")
 	api.HandleFunc("/stocks/{symbol}", handler.GetStock).Methods("GET")
+	api.HandleFunc("/stocks/{symbol}", handler.UpdateStock).Methods("PATCH")
+	api.HandleFunc("/stocks/{symbol}", handler.DeleteStock).Methods("DELETE")
 	api.HandleFunc("/stocks/{symbol}/history", handler.GetPriceHistory).Methods("GET")
+	api.HandleFunc("/stocks/{symbol}/bars", handler.GetBars).Methods("GET")
+	api.HandleFunc("/stocks/{symbol}/candles", handler.GetCandles).Methods("GET")
 	api.HandleFunc("/stocks/{symbol}/alerts", handler.GetAlerts).Methods("GET")
 
+	// Alert rule endpoints
+	api.HandleFunc("/stocks/{symbol}/rules", handler.GetAlertRules).Methods("GET")
+	api.HandleFunc("/stocks/{symbol}/rules", handler.CreateAlertRule).Methods("POST")
+	api.HandleFunc("/rules/{id:[0-9]+}", handler.UpdateAlertRule).Methods("PATCH")
+	api.HandleFunc("/rules/{id:[0-9]+}", handler.DeleteAlertRule).Methods("DELETE")
+	api.HandleFunc("/rules/{id:[0-9]+}/channels", handler.GetRuleChannels).Methods("GET")
+	api.HandleFunc("/rules/{id:[0-9]+}/channels", handler.SetRuleChannels).Methods("PUT")
+
+	// Notification channel endpoints
+	api.HandleFunc("/channels", handler.GetNotificationChannels).Methods("GET")
+	api.HandleFunc("/channels", handler.CreateNotificationChannel).Methods("POST")
+	api.HandleFunc("/channels/{id:[0-9]+}", handler.UpdateNotificationChannel).Methods("PATCH")
+	api.HandleFunc("/channels/{id:[0-9]+}", handler.DeleteNotificationChannel).Methods("DELETE")
+	api.HandleFunc("/channels/{id:[0-9]+}/test", handler.TestNotificationChannel).Methods("POST")
+
 	// Alert endpoints
-	api.HandleFunc("/alerts", handler.GetRecentAlerts).Methods("GET")
+	api.HandleFunc("/alerts", handler.ListAlerts).Methods("GET")
+	api.HandleFunc("/alerts/{id:[0-9]+}", handler.GetAlert).Methods("GET")
+	api.HandleFunc("/alerts/{id:[0-9]+}/history", handler.GetAlertHistory).Methods("GET")
+	api.HandleFunc("/alerts/{id:[0-9]+}/acknowledge", handler.AcknowledgeAlert).Methods("POST")
+	api.HandleFunc("/alerts/{id:[0-9]+}/snooze", handler.SnoozeAlert).Methods("POST")
+	api.HandleFunc("/alerts/{id:[0-9]+}/resolve", handler.ResolveAlert).Methods("POST")
+	api.HandleFunc("/alerts/{id:[0-9]+}/reopen", handler.ReopenAlert).Methods("POST")
+	api.HandleFunc("/alerts/{id:[0-9]+}/deliveries", handler.GetAlertDeliveries).Methods("GET")
 
 	// Health check
 	api.HandleFunc("/health", handler.HealthCheck).Methods("GET")
@@ -33,7 +60,7 @@ func SetupRoutes(handler *Handler) http.Handler {
 	// CORS configuration
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
 		AllowCredentials: true,
 	})
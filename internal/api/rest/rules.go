@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"net/http"
+	"stock-tracker/internal/models"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AlertRuleRequest is the body of rule create and update calls. On update,
+// omitted fields keep their current value.
+type AlertRuleRequest struct {
+	RuleType        *string  `json:"rule_type,omitempty"`
+	Threshold       *float64 `json:"threshold,omitempty"`
+	WindowSeconds   *int     `json:"window_seconds,omitempty"`
+	Expression      *string  `json:"expression,omitempty"`
+	CooldownSeconds *int     `json:"cooldown_seconds,omitempty"`
+	Hysteresis      *float64 `json:"hysteresis,omitempty"`
+	// Params replaces all indicator parameters; unset ones get defaults.
+	Params  *models.RuleParams `json:"params,omitempty"`
+	Enabled *bool              `json:"enabled,omitempty"`
+}
+
+func (req *AlertRuleRequest) apply(rule *models.AlertRule) {
+	if req.RuleType != nil {
+		rule.RuleType = *req.RuleType
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.WindowSeconds != nil {
+		rule.WindowSeconds = *req.WindowSeconds
+	}
+	if req.Expression != nil {
+		rule.Expression = *req.Expression
+	}
+	if req.CooldownSeconds != nil {
+		rule.CooldownSeconds = *req.CooldownSeconds
+	}
+	if req.Hysteresis != nil {
+		rule.Hysteresis = *req.Hysteresis
+	}
+	if req.Params != nil {
+		rule.Params = *req.Params
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	rule.SetDefaults()
+}
+
+// GetAlertRules returns the alert rules of a stock
+func (h *Handler) GetAlertRules(w http.ResponseWriter, r *http.Request) {
+	symbol := symbolVar(r)
+
+	rules, err := h.repo.GetAlertRules(r.Context(), symbol)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve alert rules")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, rules)
+}
+
+// CreateAlertRule adds an alert rule to a stock
+func (h *Handler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	symbol := symbolVar(r)
+
+	var req AlertRuleRequest
+	if !h.decodeConfigBody(w, r, &req) {
+		return
+	}
+
+	stock, err := h.repo.GetStock(r.Context(), symbol)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to retrieve stock")
+		return
+	}
+
+	rule := &models.AlertRule{StockID: stock.ID, Symbol: stock.Symbol, Enabled: true}
+	req.apply(rule)
+	if err := rule.Validate(); err != nil {
+		h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.repo.CreateAlertRule(r.Context(), rule); err != nil {
+		h.respondRepoError(w, r, err, "Stock not found", "Failed to create alert rule")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, rule)
+}
+
+// UpdateAlertRule changes an alert rule
+func (h *Handler) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var req AlertRuleRequest
+	if !h.decodeConfigBody(w, r, &req) {
+		return
+	}
+
+	rule, err := h.repo.GetAlertRule(r.Context(), id)
+	if err != nil {
+		h.respondRepoError(w, r, err, "Alert rule not found", "Failed to retrieve alert rule")
+		return
+	}
+
+	req.apply(rule)
+	if err := rule.Validate(); err != nil {
+		h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.repo.UpdateAlertRule(r.Context(), rule); err != nil {
+		h.respondRepoError(w, r, err, "Alert rule not found", "Failed to update alert rule")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, rule)
+}
+
+// DeleteAlertRule removes an alert rule; alerts it fired are kept
+func (h *Handler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	if _, err := h.repo.GetAlertRule(r.Context(), id); err != nil {
+		h.respondRepoError(w, r, err, "Alert rule not found", "Failed to retrieve alert rule")
+		return
+	}
+
+	if err := h.repo.DeleteAlertRule(r.Context(), id); err != nil {
+		h.respondRepoError(w, r, err, "Alert rule not found", "Failed to delete alert rule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
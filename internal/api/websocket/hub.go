@@ -1,7 +1,8 @@
 package websocket
 
 import (
-	"stock-tracker/internal/models"
+	"encoding/json"
+	"stock-tracker/internal/events"
 	"stock-tracker/pkg/logger"
 	"sync"
 
@@ -11,26 +12,38 @@ import (
 type Message struct {
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
+
+	// symbol routes the message to clients subscribed to it.
+	symbol string
+}
+
+// reply is a message for a single client.
+type reply struct {
+	client  *Client
+	message *Message
 }
 
 type Hub struct {
 	clients    map[*Client]bool
 	broadcast  chan *Message
+	replies    chan reply
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
 }
 
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan *Message
+	hub          *Hub
+	conn         *websocket.Conn
+	send         chan *Message
+	subscription *subscription
 }
 
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
 		broadcast:  make(chan *Message, 256),
+		replies:    make(chan reply, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 	}
@@ -38,9 +51,10 @@ func NewHub() *Hub {
 
 func NewClient(hub *Hub, conn *websocket.Conn) *Client {
 	return &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan *Message, 256),
+		hub:          hub,
+		conn:         conn,
+		send:         make(chan *Message, 256),
+		subscription: newSubscription(),
 	}
 }
 func (h *Hub) RegisterClient(client *Client) {
@@ -71,43 +85,64 @@ func (h *Hub) Run() {
 				Msg("Client disconnected from WebSocket")
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
+			h.mu.Lock()
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+				if client.subscription.wants(message) {
+					h.deliver(client, message)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
+
+		case r := <-h.replies:
+			h.mu.Lock()
+			if _, ok := h.clients[r.client]; ok {
+				h.deliver(r.client, r.message)
+			}
+			h.mu.Unlock()
 		}
 	}
 }
 
-func (h *Hub) BroadcastStockUpdate(stock *models.Stock) {
-	msg := &Message{
-		Type:    "stock_update",
-		Payload: stock,
-	}
-
+// deliver queues a message for a client, dropping clients that cannot keep
+// up. It must be called with h.mu held.
+func (h *Hub) deliver(client *Client, message *Message) {
 	select {
-	case h.broadcast <- msg:
+	case client.send <- message:
 	default:
-		logger.Warn().Msg("Broadcast channel full, dropping message")
+		close(client.send)
+		delete(h.clients, client)
 	}
 }
 
-func (h *Hub) BroadcastAlert(alert *models.Alert) {
-	msg := &Message{
-		Type:    "alert",
-		Payload: alert,
+// Forward broadcasts every event received from a bus subscription until the
+// channel is closed.
+func (h *Hub) Forward(ch <-chan events.Event) {
+	for event := range ch {
+		h.Broadcast(&Message{
+			Type:    event.Type,
+			Payload: event.Payload,
+			symbol:  payloadSymbol(event.Payload),
+		})
 	}
+}
 
+// payloadSymbol extracts the "symbol" field that stock and alert payloads
+// carry, or "" if there is none.
+func payloadSymbol(payload json.RawMessage) string {
+	var routed struct {
+		Symbol string `json:"symbol"`
+	}
+	if err := json.Unmarshal(payload, &routed); err != nil {
+		return ""
+	}
+	return routed.Symbol
+}
+
+func (h *Hub) Broadcast(msg *Message) {
 	select {
 	case h.broadcast <- msg:
 	default:
-		logger.Warn().Msg("Broadcast channel full, dropping alert message")
+		logger.Warn().Str("type", msg.Type).Msg("Broadcast channel full, dropping message")
 	}
 }
 
@@ -132,8 +167,7 @@ func (c *Client) ReadPump() {
 	}()
 
 	for {
-		var msg Message
-		err := c.conn.ReadJSON(&msg)
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				logger.Error().Err(err).Msg("WebSocket error")
@@ -141,8 +175,11 @@ func (c *Client) ReadPump() {
 			break
 		}
 
+		response := c.handleCommand(data)
 		logger.Debug().
-			Str("type", msg.Type).
-			Msg("Received message from client")
+			Str("reply", response.Type).
+			Msg("Handled command from client")
+
+		c.hub.replies <- reply{client: c, message: response}
 	}
 }
@@ -0,0 +1,261 @@
+package websocket
+
+// Client protocol
+//
+// Every frame in either direction is a JSON Message {"type": ..., "payload": ...}.
+// A new connection receives every event for every symbol. Clients narrow
+// that down with commands:
+//
+//	{"type": "subscribe",   "payload": {"symbols": ["AAPL"], "events": ["alert"]}}
+//	{"type": "unsubscribe", "payload": {"symbols": ["AAPL"]}}
+//	{"type": "list"}
+//	{"type": "ping"}
+//
+// "symbols" and "events" are both optional and "*" stands for all of them.
+// Subscribing to specific symbols (or events) while subscribed to all of
+// them narrows the subscription to those; otherwise subscribe adds to it.
+// Unsubscribing removes entries, and unsubscribing from "*" clears the set
+// so nothing of that kind is delivered until the next subscribe.
+//
+// subscribe, unsubscribe and list are answered with the resulting
+// subscription:
+//
+//	{"type": "subscriptions", "payload": {"symbols": ["AAPL"], "events": ["alert"]}}
+//
+// ping is answered with {"type": "pong", "payload": {"time": "<RFC3339>"}}.
+// Malformed or unknown commands get an error reply and leave the
+// subscription unchanged:
+//
+//	{"type": "error", "payload": {"command": "subscribe", "message": "..."}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"stock-tracker/internal/events"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client commands and server replies.
+const (
+	CommandSubscribe   = "subscribe"
+	CommandUnsubscribe = "unsubscribe"
+	CommandList        = "list"
+	CommandPing        = "ping"
+
+	ReplySubscriptions = "subscriptions"
+	ReplyPong          = "pong"
+	ReplyError         = "error"
+
+	wildcard = "*"
+)
+
+// subscribableEvents are the event types clients can filter on.
+var subscribableEvents = map[string]bool{
+	events.TypeStockUpdate:       true,
+	events.TypeAlert:             true,
+	events.TypeAlertStateChanged: true,
+}
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9.\-]{1,10}$`)
+
+type command struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type SubscriptionRequest struct {
+	Symbols []string `json:"symbols,omitempty"`
+	Events  []string `json:"events,omitempty"`
+}
+
+type SubscriptionReply struct {
+	Symbols []string `json:"symbols"`
+	Events  []string `json:"events"`
+}
+
+type ErrorReply struct {
+	Command string `json:"command,omitempty"`
+	Message string `json:"message"`
+}
+
+// filter is a set of names, initially matching everything.
+type filter struct {
+	all   bool
+	names map[string]bool
+}
+
+func newFilter() filter {
+	return filter{all: true, names: make(map[string]bool)}
+}
+
+func (f *filter) matches(name string) bool {
+	return f.all || f.names[name]
+}
+
+func (f *filter) add(names []string) {
+	for _, name := range names {
+		if name == wildcard {
+			f.all = true
+			return
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	if f.all {
+		f.all = false
+		f.names = make(map[string]bool)
+	}
+	for _, name := range names {
+		f.names[name] = true
+	}
+}
+
+func (f *filter) remove(names []string) error {
+	for _, name := range names {
+		if name == wildcard {
+			f.all = false
+			f.names = make(map[string]bool)
+			return nil
+		}
+	}
+	if f.all && len(names) > 0 {
+		return fmt.Errorf("subscribed to all; unsubscribe from %q or subscribe to specific entries first", wildcard)
+	}
+	for _, name := range names {
+		delete(f.names, name)
+	}
+	return nil
+}
+
+func (f *filter) list() []string {
+	if f.all {
+		return []string{wildcard}
+	}
+	names := make([]string, 0, len(f.names))
+	for name := range f.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// subscription records what a client wants to receive.
+type subscription struct {
+	mu      sync.RWMutex
+	symbols filter
+	events  filter
+}
+
+func newSubscription() *subscription {
+	return &subscription{symbols: newFilter(), events: newFilter()}
+}
+
+// wants reports whether a message should be delivered. Messages that are
+// not about a particular symbol only go through the event filter.
+func (s *subscription) wants(msg *Message) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.events.matches(msg.Type) {
+		return false
+	}
+	return msg.symbol == "" || s.symbols.matches(msg.symbol)
+}
+
+func (s *subscription) reply() SubscriptionReply {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return SubscriptionReply{Symbols: s.symbols.list(), Events: s.events.list()}
+}
+
+// handleCommand applies one client frame and returns the reply to send.
+func (c *Client) handleCommand(data []byte) *Message {
+	var cmd command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return errorMessage("", "Malformed JSON: "+err.Error())
+	}
+
+	switch cmd.Type {
+	case CommandPing:
+		return &Message{Type: ReplyPong, Payload: map[string]string{"time": time.Now().Format(time.RFC3339)}}
+
+	case CommandList:
+		return &Message{Type: ReplySubscriptions, Payload: c.subscription.reply()}
+
+	case CommandSubscribe, CommandUnsubscribe:
+		req, err := parseSubscriptionRequest(cmd.Payload)
+		if err != nil {
+			return errorMessage(cmd.Type, err.Error())
+		}
+
+		s := c.subscription
+		s.mu.Lock()
+		if cmd.Type == CommandSubscribe {
+			s.symbols.add(req.Symbols)
+			s.events.add(req.Events)
+		} else {
+			// Validate both before changing either so a bad request
+			// leaves the subscription untouched.
+			symbols, evts := s.symbols, s.events
+			symbols.names, evts.names = maps.Clone(symbols.names), maps.Clone(evts.names)
+			if err = symbols.remove(req.Symbols); err == nil {
+				err = evts.remove(req.Events)
+			}
+			if err == nil {
+				s.symbols, s.events = symbols, evts
+			}
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			return errorMessage(cmd.Type, err.Error())
+		}
+		return &Message{Type: ReplySubscriptions, Payload: s.reply()}
+
+	case "":
+		return errorMessage("", "Missing command type")
+
+	default:
+		return errorMessage(cmd.Type, "Unknown command; expected subscribe, unsubscribe, list or ping")
+	}
+}
+
+func parseSubscriptionRequest(payload json.RawMessage) (*SubscriptionRequest, error) {
+	var req SubscriptionRequest
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("payload with symbols or events is required")
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	if len(req.Symbols) == 0 && len(req.Events) == 0 {
+		return nil, fmt.Errorf("payload with symbols or events is required")
+	}
+
+	for i, symbol := range req.Symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol != wildcard && !symbolPattern.MatchString(symbol) {
+			return nil, fmt.Errorf("invalid symbol %q", req.Symbols[i])
+		}
+		req.Symbols[i] = symbol
+	}
+
+	for _, event := range req.Events {
+		if event != wildcard && !subscribableEvents[event] {
+			return nil, fmt.Errorf("unknown event type %q", event)
+		}
+	}
+
+	return &req, nil
+}
+
+func errorMessage(command, message string) *Message {
+	return &Message{Type: ReplyError, Payload: ErrorReply{Command: command, Message: message}}
+}
@@ -0,0 +1,121 @@
+// Package backfill loads historical daily bars from a quote provider into
+// the repository. Each symbol's backfilled range is recorded, so a backfill
+// that was interrupted, or is run again, only fetches what is missing.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stock-tracker/internal/api"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/ratelimit"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+type Backfiller struct {
+	provider api.HistoryProvider
+	repo     repository.StockRepository
+}
+
+func New(provider api.HistoryProvider, repo repository.StockRepository) *Backfiller {
+	return &Backfiller{provider: provider, repo: repo}
+}
+
+// Run backfills the daily bars of each symbol for the days from from to to.
+// A symbol that fails is logged and skipped; the run stops early when ctx
+// is cancelled or the provider can't serve any more requests. It returns
+// the errors of every symbol that wasn't backfilled.
+func (b *Backfiller) Run(ctx context.Context, symbols []string, from, to time.Time) error {
+	var errs []error
+	for i, symbol := range symbols {
+		saved, err := b.Symbol(ctx, symbol, from, to)
+		if err == nil {
+			logger.Info().Str("symbol", symbol).Int("bars", saved).Msg("Backfilled daily bars")
+			continue
+		}
+
+		errs = append(errs, err)
+		if stopsRun(ctx, err) {
+			logger.Warn().Err(err).Strs("remaining", symbols[i+1:]).Msg("Stopping backfill; run it again to resume")
+			break
+		}
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to backfill symbol")
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopsRun reports whether err would also fail the remaining symbols.
+func stopsRun(ctx context.Context, err error) bool {
+	return ctx.Err() != nil ||
+		errors.Is(err, ratelimit.ErrQuotaExhausted) ||
+		errors.Is(err, api.ErrRateLimited) ||
+		errors.Is(err, api.ErrUnsupported)
+}
+
+// Symbol backfills one symbol and returns how many bars it saved. Days
+// already backfilled are not fetched again.
+func (b *Backfiller) Symbol(ctx context.Context, symbol string, from, to time.Time) (int, error) {
+	if _, err := b.repo.GetStock(ctx, symbol); err != nil {
+		return 0, fmt.Errorf("%s is not on the watchlist: %w", symbol, err)
+	}
+
+	progress, err := b.repo.GetBackfillProgress(ctx, symbol, models.BarDaily)
+	if err != nil {
+		return 0, err
+	}
+
+	start := from
+	if progress != nil {
+		if progress.Covers(from, to) {
+			logger.Debug().Str("symbol", symbol).Msg("Already backfilled")
+			return 0, nil
+		}
+		if !progress.From.After(from) && !progress.To.Before(from) {
+			// Resume after the stored range.
+			start = progress.To.Add(day)
+		}
+	}
+
+	bars, err := b.provider.GetDailyBars(ctx, symbol, start, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch daily bars of %s: %w", symbol, err)
+	}
+	if err := b.repo.SaveBars(ctx, bars); err != nil {
+		return 0, err
+	}
+
+	if err := b.repo.SaveBackfillProgress(ctx, covered(progress, symbol, start, to)); err != nil {
+		return len(bars), err
+	}
+	return len(bars), nil
+}
+
+// covered returns the backfilled range after fetching start through to.
+// Today's bar is still changing, so the range ends yesterday at the latest
+// and today is fetched again next time.
+func covered(progress *models.BackfillProgress, symbol string, start, to time.Time) *models.BackfillProgress {
+	today := time.Now().UTC().Truncate(day)
+	if !to.Before(today) {
+		to = today.Add(-day)
+	}
+
+	next := &models.BackfillProgress{Symbol: symbol, Interval: models.BarDaily, From: start, To: to}
+	if progress == nil || start.After(progress.To.Add(day)) || to.Before(progress.From.Add(-day)) {
+		// Nothing to merge with; the new range replaces the old one.
+		return next
+	}
+
+	if progress.From.Before(next.From) {
+		next.From = progress.From
+	}
+	if progress.To.After(next.To) {
+		next.To = progress.To
+	}
+	return next
+}
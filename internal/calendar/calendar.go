@@ -0,0 +1,243 @@
+// Package calendar knows when exchanges trade: their regular and extended
+// hours, early closes and holidays, in the exchange's timezone.
+package calendar
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	// Embedded zone data, so calendars work on hosts without tzdata.
+	_ "time/tzdata"
+)
+
+// Session is the part of the trading day a time falls in.
+type Session string
+
+const (
+	SessionClosed     Session = "closed"
+	SessionPreMarket  Session = "pre_market"
+	SessionRegular    Session = "regular"
+	SessionAfterHours Session = "after_hours"
+)
+
+// Extended reports whether s is pre-market or after-hours trading.
+func (s Session) Extended() bool {
+	return s == SessionPreMarket || s == SessionAfterHours
+}
+
+//go:embed exchanges.json
+var builtin []byte
+
+// dataFile is the format of exchanges.json and of calendar files passed to
+// Load.
+type dataFile struct {
+	Exchanges []Spec `json:"exchanges"`
+}
+
+// Spec describes an exchange's calendar. Times are "HH:MM" and dates
+// "YYYY-MM-DD", both in the exchange's timezone.
+type Spec struct {
+	// Names are the exchanges sharing this calendar.
+	Names           []string `json:"names"`
+	Timezone        string   `json:"timezone"`
+	PreMarketOpen   string   `json:"pre_market_open"`
+	Open            string   `json:"open"`
+	Close           string   `json:"close"`
+	AfterHoursClose string   `json:"after_hours_close"`
+	Holidays        []string `json:"holidays"`
+	// EarlyCloses maps dates to their regular session close. After-hours
+	// trading then lasts as long as on other days.
+	EarlyCloses map[string]string `json:"early_closes"`
+}
+
+// clock is a time of day in minutes after midnight.
+type clock int
+
+// Calendar answers which session an exchange is in.
+type Calendar struct {
+	name     string
+	loc      *time.Location
+	preOpen  clock
+	open     clock
+	close    clock
+	afterEnd clock
+	holidays map[string]bool
+	early    map[string]clock
+	// through is the end of the last year with listed holidays.
+	through time.Time
+}
+
+// Load returns the calendar of the named exchange from a calendar file,
+// or from the built-in calendars if path is empty.
+func Load(name, path string) (*Calendar, error) {
+	data := builtin
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read calendar file: %w", err)
+		}
+	}
+
+	var file dataFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse calendar file: %w", err)
+	}
+
+	for _, spec := range file.Exchanges {
+		for _, specName := range spec.Names {
+			if strings.EqualFold(specName, name) {
+				return New(strings.ToUpper(name), spec)
+			}
+		}
+	}
+	return nil, fmt.Errorf("no calendar for exchange %q", name)
+}
+
+// New builds a calendar from its spec.
+func New(name string, spec Spec) (*Calendar, error) {
+	loc, err := time.LoadLocation(spec.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q for %s: %w", spec.Timezone, name, err)
+	}
+
+	c := &Calendar{
+		name:     name,
+		loc:      loc,
+		holidays: make(map[string]bool, len(spec.Holidays)),
+		early:    make(map[string]clock, len(spec.EarlyCloses)),
+	}
+
+	for _, field := range []struct {
+		value string
+		dst   *clock
+		what  string
+	}{
+		{spec.PreMarketOpen, &c.preOpen, "pre_market_open"},
+		{spec.Open, &c.open, "open"},
+		{spec.Close, &c.close, "close"},
+		{spec.AfterHoursClose, &c.afterEnd, "after_hours_close"},
+	} {
+		if *field.dst, err = parseClock(field.value); err != nil {
+			return nil, fmt.Errorf("invalid %s for %s: %w", field.what, name, err)
+		}
+	}
+	if !(c.preOpen <= c.open && c.open < c.close && c.close <= c.afterEnd) {
+		return nil, fmt.Errorf("hours of %s are out of order", name)
+	}
+
+	for _, date := range spec.Holidays {
+		day, err := time.ParseInLocation(time.DateOnly, date, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q for %s: %w", date, name, err)
+		}
+		c.holidays[date] = true
+		if end := time.Date(day.Year()+1, 1, 1, 0, 0, 0, 0, loc); end.After(c.through) {
+			c.through = end
+		}
+	}
+
+	for date, value := range spec.EarlyCloses {
+		if _, err := time.ParseInLocation(time.DateOnly, date, loc); err != nil {
+			return nil, fmt.Errorf("invalid early close date %q for %s: %w", date, name, err)
+		}
+		closeAt, err := parseClock(value)
+		if err != nil || closeAt <= c.open || closeAt > c.close {
+			return nil, fmt.Errorf("invalid early close %q on %s for %s", value, date, name)
+		}
+		c.early[date] = closeAt
+	}
+
+	return c, nil
+}
+
+func parseClock(value string) (clock, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time of day must be HH:MM, got %q", value)
+	}
+	return clock(t.Hour()*60 + t.Minute()), nil
+}
+
+func (c *Calendar) Name() string {
+	return c.name
+}
+
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// Covers reports whether t falls in a year the holiday list covers. Past
+// that, every weekday is treated as a trading day.
+func (c *Calendar) Covers(t time.Time) bool {
+	return t.Before(c.through)
+}
+
+// Session returns the session t falls in.
+func (c *Calendar) Session(t time.Time) Session {
+	bounds := c.bounds(t.In(c.loc))
+	switch {
+	case bounds == nil, t.Before(bounds[0]), !t.Before(bounds[3]):
+		return SessionClosed
+	case t.Before(bounds[1]):
+		return SessionPreMarket
+	case t.Before(bounds[2]):
+		return SessionRegular
+	default:
+		return SessionAfterHours
+	}
+}
+
+// NextTransition returns the first time after t at which the session
+// changes.
+func (c *Calendar) NextTransition(t time.Time) time.Time {
+	local := t.In(c.loc)
+	// Holidays and weekends never run more than a few days in a row.
+	for i := 0; i < 14; i++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, 12, 0, 0, 0, c.loc)
+		for _, bound := range c.bounds(day) {
+			if bound.After(t) {
+				return bound
+			}
+		}
+	}
+	return t.Add(24 * time.Hour)
+}
+
+// Next returns the first time at or after t whose session is in sessions.
+func (c *Calendar) Next(t time.Time, sessions ...Session) time.Time {
+	for i := 0; i < 64; i++ {
+		if slices.Contains(sessions, c.Session(t)) {
+			return t
+		}
+		t = c.NextTransition(t)
+	}
+	return t
+}
+
+// bounds returns the pre-market open, regular open, regular close and
+// after-hours close of the trading day of local, or nil if the exchange
+// doesn't trade that day.
+func (c *Calendar) bounds(local time.Time) []time.Time {
+	if wd := local.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return nil
+	}
+	date := local.Format(time.DateOnly)
+	if c.holidays[date] {
+		return nil
+	}
+
+	closeAt, afterEnd := c.close, c.afterEnd
+	if early, ok := c.early[date]; ok {
+		closeAt, afterEnd = early, early+(c.afterEnd-c.close)
+	}
+
+	at := func(m clock) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day(), int(m)/60, int(m)%60, 0, 0, c.loc)
+	}
+	return []time.Time{at(c.preOpen), at(c.open), at(closeAt), at(afterEnd)}
+}
@@ -0,0 +1,229 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadNYSE(t *testing.T) *Calendar {
+	t.Helper()
+	c, err := Load("nyse", "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return c
+}
+
+// at returns the time in New York.
+func at(t *testing.T, c *Calendar, value string) time.Time {
+	t.Helper()
+	tm, err := time.ParseInLocation("2006-01-02 15:04", value, c.Location())
+	if err != nil {
+		t.Fatalf("bad time %q: %v", value, err)
+	}
+	return tm
+}
+
+func TestSession(t *testing.T) {
+	c := loadNYSE(t)
+
+	tests := []struct {
+		at   string
+		want Session
+	}{
+		// Monday 2025-07-07, a regular day.
+		{"2025-07-07 03:59", SessionClosed},
+		{"2025-07-07 04:00", SessionPreMarket},
+		{"2025-07-07 09:29", SessionPreMarket},
+		{"2025-07-07 09:30", SessionRegular},
+		{"2025-07-07 15:59", SessionRegular},
+		{"2025-07-07 16:00", SessionAfterHours},
+		{"2025-07-07 19:59", SessionAfterHours},
+		{"2025-07-07 20:00", SessionClosed},
+		// Weekend.
+		{"2025-07-05 12:00", SessionClosed},
+		{"2025-07-06 12:00", SessionClosed},
+		// Holidays.
+		{"2025-07-04 12:00", SessionClosed},
+		{"2025-01-09 12:00", SessionClosed},
+		{"2026-04-03 05:00", SessionClosed},
+		{"2027-12-24 10:00", SessionClosed},
+		// Early close at 13:00; after-hours still lasts four hours.
+		{"2025-07-03 12:59", SessionRegular},
+		{"2025-07-03 13:00", SessionAfterHours},
+		{"2025-07-03 16:59", SessionAfterHours},
+		{"2025-07-03 17:00", SessionClosed},
+		{"2026-11-27 14:00", SessionAfterHours},
+		// The day after Christmas trades normally.
+		{"2025-12-26 15:00", SessionRegular},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			if got := c.Session(at(t, c, tt.at)); got != tt.want {
+				t.Errorf("Session(%s) = %s, want %s", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionAcrossDST(t *testing.T) {
+	c := loadNYSE(t)
+
+	tests := []struct {
+		utc  string
+		want Session
+	}{
+		// 14:30 UTC is the open in winter, 13:30 UTC in summer.
+		{"2025-03-07T14:29:00Z", SessionPreMarket},
+		{"2025-03-07T14:30:00Z", SessionRegular},
+		{"2025-03-10T13:29:00Z", SessionPreMarket},
+		{"2025-03-10T13:30:00Z", SessionRegular},
+	}
+	for _, tt := range tests {
+		t.Run(tt.utc, func(t *testing.T) {
+			tm, _ := time.Parse(time.RFC3339, tt.utc)
+			if got := c.Session(tm); got != tt.want {
+				t.Errorf("Session(%s) = %s, want %s", tt.utc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextTransition(t *testing.T) {
+	c := loadNYSE(t)
+
+	tests := []struct {
+		from string
+		want string
+	}{
+		{"2025-07-07 03:00", "2025-07-07 04:00"},
+		{"2025-07-07 04:00", "2025-07-07 09:30"},
+		{"2025-07-07 12:00", "2025-07-07 16:00"},
+		{"2025-07-07 16:00", "2025-07-07 20:00"},
+		// Early close, then a holiday and a weekend.
+		{"2025-07-03 10:00", "2025-07-03 13:00"},
+		{"2025-07-03 13:00", "2025-07-03 17:00"},
+		{"2025-07-03 18:00", "2025-07-07 04:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			got := c.NextTransition(at(t, c, tt.from))
+			if want := at(t, c, tt.want); !got.Equal(want) {
+				t.Errorf("NextTransition(%s) = %s, want %s", tt.from, got, want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	c := loadNYSE(t)
+
+	tests := []struct {
+		from     string
+		sessions []Session
+		want     string
+	}{
+		{"2025-07-07 10:00", []Session{SessionRegular}, "2025-07-07 10:00"},
+		{"2025-12-24 14:00", []Session{SessionRegular}, "2025-12-26 09:30"},
+		{"2025-12-24 14:00", []Session{SessionPreMarket, SessionRegular}, "2025-12-26 04:00"},
+		{"2025-11-26 21:00", []Session{SessionRegular, SessionAfterHours}, "2025-11-28 09:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			got := c.Next(at(t, c, tt.from), tt.sessions...)
+			if want := at(t, c, tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s, %v) = %s, want %s", tt.from, tt.sessions, got, want)
+			}
+		})
+	}
+}
+
+func TestCovers(t *testing.T) {
+	c := loadNYSE(t)
+
+	if !c.Covers(at(t, c, "2027-12-31 23:59")) {
+		t.Error("last listed year not covered")
+	}
+	if c.Covers(at(t, c, "2028-01-03 10:00")) {
+		t.Error("year without holidays covered")
+	}
+	if c.Name() != "NYSE" {
+		t.Errorf("Name() = %q, want NYSE", c.Name())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load("LSE", ""); err == nil || !strings.Contains(err.Error(), `no calendar for exchange "LSE"`) {
+		t.Errorf("Load(LSE) error = %v", err)
+	}
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	custom := write("custom.json", `{"exchanges": [{"names": ["XTST"], "timezone": "UTC",
+		"pre_market_open": "08:00", "open": "09:00", "close": "17:00", "after_hours_close": "17:00",
+		"holidays": ["2025-07-07"]}]}`)
+	c, err := Load("xtst", custom)
+	if err != nil {
+		t.Fatalf("Load custom: %v", err)
+	}
+	if got := c.Session(time.Date(2025, 7, 8, 9, 0, 0, 0, time.UTC)); got != SessionRegular {
+		t.Errorf("custom calendar session = %s, want regular", got)
+	}
+	if got := c.Session(time.Date(2025, 7, 7, 9, 0, 0, 0, time.UTC)); got != SessionClosed {
+		t.Errorf("custom calendar holiday session = %s, want closed", got)
+	}
+
+	if _, err := Load("XTST", write("broken.json", `{"exchanges": [`)); err == nil || !strings.Contains(err.Error(), "failed to parse calendar file") {
+		t.Errorf("broken file error = %v", err)
+	}
+	if _, err := Load("XTST", filepath.Join(dir, "missing.json")); err == nil || !strings.Contains(err.Error(), "failed to read calendar file") {
+		t.Errorf("missing file error = %v", err)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	valid := Spec{
+		Timezone: "America/New_York", PreMarketOpen: "04:00", Open: "09:30", Close: "16:00", AfterHoursClose: "20:00",
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(*Spec)
+		wantErr string
+	}{
+		{"valid", func(*Spec) {}, ""},
+		{"timezone", func(s *Spec) { s.Timezone = "Mars/Olympus" }, `invalid timezone "Mars/Olympus"`},
+		{"time format", func(s *Spec) { s.Open = "9.30" }, "invalid open for TEST"},
+		{"hours out of order", func(s *Spec) { s.Close = "09:00" }, "hours of TEST are out of order"},
+		{"holiday date", func(s *Spec) { s.Holidays = []string{"2025-13-01"} }, `invalid holiday "2025-13-01"`},
+		{"early close date", func(s *Spec) { s.EarlyCloses = map[string]string{"July 3": "13:00"} }, `invalid early close date "July 3"`},
+		{"early close after close", func(s *Spec) { s.EarlyCloses = map[string]string{"2025-07-03": "17:00"} }, `invalid early close "17:00"`},
+		{"early close before open", func(s *Spec) { s.EarlyCloses = map[string]string{"2025-07-03": "09:00"} }, `invalid early close "09:00"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := valid
+			tt.edit(&spec)
+			_, err := New("TEST", spec)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("New: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
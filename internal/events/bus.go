@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Event types published by the tracker and consumed by WebSocket clients.
+const (
+	TypeStockUpdate = "stock_update"
+	TypeAlert       = "alert"
+	// TypeAlertStateChanged carries an alert after it was acknowledged,
+	// snoozed, resolved or reopened.
+	TypeAlertStateChanged = "alert_state_changed"
+)
+
+// Event is the envelope carried on the bus. The payload is kept as raw JSON
+// so subscribers can forward it without knowing its type.
+type Event struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Publisher is the side of the bus used by producers such as the tracker
+// and the alert monitor.
+type Publisher interface {
+	Publish(ctx context.Context, eventType string, payload interface{}) error
+}
+
+// Bus fans events out from publishers to every subscriber, possibly in
+// other processes.
+type Bus interface {
+	Publisher
+	// Subscribe returns a channel of events that is closed when ctx is done
+	// or the bus is closed. Slow subscribers may miss events.
+	Subscribe(ctx context.Context) (<-chan Event, error)
+	Close() error
+}
+
+// NewBus creates the bus implementation named by kind: "postgres" shares
+// events between processes through LISTEN/NOTIFY on databaseURL, "memory"
+// only within the current process.
+func NewBus(kind, databaseURL string) (Bus, error) {
+	switch kind {
+	case "postgres":
+		return NewPostgresBus(databaseURL)
+	case "memory":
+		return NewMemoryBus(), nil
+	default:
+		return nil, fmt.Errorf("unknown event bus: %s", kind)
+	}
+}
+
+func newEvent(eventType string, payload interface{}) (Event, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	return Event{Type: eventType, Payload: raw}, nil
+}
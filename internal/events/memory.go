@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+	"stock-tracker/pkg/logger"
+	"sync"
+)
+
+const subscriberBuffer = 256
+
+// MemoryBus delivers events to subscribers in the same process. It is used
+// when the tracker and API server run in a single binary.
+type MemoryBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, eventType string, payload interface{}) error {
+	event, err := newEvent(eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.Warn().Str("type", eventType).Msg("Event subscriber full, dropping event")
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(ctx context.Context) (<-chan Event, error) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return ch, nil
+	}
+	b.subscribers[ch] = struct{}{}
+
+	context.AfterFunc(ctx, func() { b.unsubscribe(ch) })
+	return ch, nil
+}
+
+func (b *MemoryBus) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stock-tracker/pkg/logger"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// notifyChannel is the LISTEN/NOTIFY channel shared by all processes.
+	notifyChannel = "stock_tracker_events"
+	// maxNotifyPayload is the PostgreSQL limit on a NOTIFY payload.
+	maxNotifyPayload = 8000
+
+	reconnectDelay    = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// PostgresBus carries events between processes with LISTEN/NOTIFY. Events
+// are not persisted: subscribers that are disconnected miss them.
+type PostgresBus struct {
+	databaseURL string
+	pool        *pgxpool.Pool
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+func NewPostgresBus(databaseURL string) (*PostgresBus, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	config.MaxConns = 2
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create event bus pool: %w", err)
+	}
+
+	return &PostgresBus{
+		databaseURL: databaseURL,
+		pool:        pool,
+		ctx:         ctx,
+		cancel:      cancel,
+	}, nil
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, eventType string, payload interface{}) error {
+	event, err := newEvent(eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if len(raw) >= maxNotifyPayload {
+		return fmt.Errorf("%s event of %d bytes exceeds the NOTIFY payload limit", eventType, len(raw))
+	}
+
+	if _, err := b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", notifyChannel, string(raw)); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// Subscribe listens on a dedicated connection and reconnects with backoff
+// if it is lost.
+func (b *PostgresBus) Subscribe(ctx context.Context) (<-chan Event, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	context.AfterFunc(b.ctx, cancel)
+
+	conn, err := b.listen(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	go b.receive(ctx, cancel, conn, ch)
+	return ch, nil
+}
+
+func (b *PostgresBus) listen(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, b.databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect event listener: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("failed to listen for events: %w", err)
+	}
+
+	logger.Info().Str("channel", notifyChannel).Msg("Listening for events")
+	return conn, nil
+}
+
+func (b *PostgresBus) receive(ctx context.Context, cancel context.CancelFunc, conn *pgx.Conn, ch chan<- Event) {
+	defer close(ch)
+	defer cancel()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			conn.Close(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.Error().Err(err).Msg("Lost event listener connection, reconnecting")
+			if conn = b.reconnect(ctx); conn == nil {
+				return
+			}
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			logger.Error().Err(err).Msg("Failed to decode event")
+			continue
+		}
+
+		select {
+		case ch <- event:
+		default:
+			logger.Warn().Str("type", event.Type).Msg("Event subscriber full, dropping event")
+		}
+	}
+}
+
+// reconnect retries listen until it succeeds or ctx is done, in which case
+// it returns nil.
+func (b *PostgresBus) reconnect(ctx context.Context) *pgx.Conn {
+	delay := reconnectDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, err := b.listen(ctx)
+		if err == nil {
+			return conn
+		}
+
+		logger.Error().Err(err).Dur("retry_in", delay).Msg("Failed to reconnect event listener")
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+func (b *PostgresBus) Close() error {
+	b.cancel()
+	b.pool.Close()
+	return nil
+}
@@ -0,0 +1,50 @@
+package expr
+
+// node is an expression tree node. pos is the offset of the token that
+// introduced it, for error messages.
+type node interface {
+	position() int
+}
+
+type numberLit struct {
+	pos   int
+	value float64
+}
+
+type boolLit struct {
+	pos   int
+	value bool
+}
+
+type ident struct {
+	pos  int
+	name string
+}
+
+// op is the canonical operator and word the operator as written.
+type unary struct {
+	pos  int
+	op   string
+	word string
+	x    node
+}
+
+type binary struct {
+	pos  int
+	op   string
+	word string
+	x, y node
+}
+
+type call struct {
+	pos  int
+	name string
+	args []node
+}
+
+func (n *numberLit) position() int { return n.pos }
+func (n *boolLit) position() int   { return n.pos }
+func (n *ident) position() int     { return n.pos }
+func (n *unary) position() int     { return n.pos }
+func (n *binary) position() int    { return n.pos }
+func (n *call) position() int      { return n.pos }
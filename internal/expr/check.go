@@ -0,0 +1,225 @@
+package expr
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// Type is the type of an expression.
+type Type int
+
+const (
+	Number Type = iota
+	Bool
+)
+
+func (t Type) String() string {
+	if t == Bool {
+		return "boolean"
+	}
+	return "number"
+}
+
+// maxPeriod bounds indicator periods, which decide how much history is
+// loaded.
+const maxPeriod = 1000
+
+// Variables are the names an expression can refer to.
+var Variables = map[string]string{
+	"price":          "current price",
+	"previous_price": "price at the previous update",
+	"change":         "price minus previous_price",
+	"change_percent": "percent change from the previous close",
+	"volume":         "shares traded so far today",
+	"avg_volume":     "average daily volume over the configured window",
+	"volume_ratio":   "volume divided by avg_volume",
+}
+
+type function struct {
+	params int
+	// indicator functions are answered by the Env and take literal
+	// settings, e.g. the period of sma(50).
+	indicator bool
+	// check validates the literal settings of an indicator function.
+	check func(args []float64) error
+}
+
+func periods(args []float64) error {
+	for _, arg := range args {
+		if arg != math.Trunc(arg) || arg < 1 || arg > maxPeriod {
+			return fmt.Errorf("periods must be whole numbers between 1 and %d", maxPeriod)
+		}
+	}
+	return nil
+}
+
+func macdSettings(args []float64) error {
+	if err := periods(args); err != nil {
+		return err
+	}
+	if args[0] >= args[1] {
+		return fmt.Errorf("the fast period must be below the slow period")
+	}
+	return nil
+}
+
+func bollingerSettings(args []float64) error {
+	if err := periods(args[:1]); err != nil {
+		return err
+	}
+	if args[0] < 2 || args[1] <= 0 {
+		return fmt.Errorf("needs a period of at least 2 and a positive number of standard deviations")
+	}
+	return nil
+}
+
+// Functions are the functions an expression can call.
+var Functions = map[string]function{
+	"abs": {params: 1},
+	"min": {params: 2},
+	"max": {params: 2},
+
+	"sma":         {params: 1, indicator: true, check: periods},
+	"ema":         {params: 1, indicator: true, check: periods},
+	"rsi":         {params: 1, indicator: true, check: periods},
+	"macd":        {params: 3, indicator: true, check: macdSettings},
+	"macd_signal": {params: 3, indicator: true, check: macdSettings},
+	"bb_upper":    {params: 2, indicator: true, check: bollingerSettings},
+	"bb_middle":   {params: 2, indicator: true, check: bollingerSettings},
+	"bb_lower":    {params: 2, indicator: true, check: bollingerSettings},
+	"avg_volume":  {params: 1, indicator: true, check: periods},
+}
+
+// check returns the type of n, or the first type error in it.
+func check(n node) (Type, error) {
+	switch n := n.(type) {
+	case *numberLit:
+		return Number, nil
+
+	case *boolLit:
+		return Bool, nil
+
+	case *ident:
+		if _, ok := Variables[n.name]; ok {
+			return Number, nil
+		}
+		if _, ok := Functions[n.name]; ok {
+			return 0, errorf(n.pos, "%s is a function; call it like %s(...)", n.name, n.name)
+		}
+		msg := fmt.Sprintf("unknown variable %q", n.name)
+		if s := suggest(n.name, slices.Sorted(maps.Keys(Variables))); s != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", s)
+		}
+		return 0, errorf(n.pos, "%s", msg)
+
+	case *unary:
+		want := Number
+		if n.op == "!" {
+			want = Bool
+		}
+		if err := expect(n.x, want, "operand of "+n.word); err != nil {
+			return 0, err
+		}
+		return want, nil
+
+	case *binary:
+		switch n.op {
+		case "&&", "||":
+			if err := expect(n.x, Bool, "left side of "+n.word); err != nil {
+				return 0, err
+			}
+			if err := expect(n.y, Bool, "right side of "+n.word); err != nil {
+				return 0, err
+			}
+			return Bool, nil
+
+		case "==", "!=":
+			xt, err := check(n.x)
+			if err != nil {
+				return 0, err
+			}
+			if err := expect(n.y, xt, "right side of "+n.word); err != nil {
+				return 0, err
+			}
+			return Bool, nil
+
+		case "<", "<=", ">", ">=":
+			if err := expect(n.x, Number, "left side of "+n.word); err != nil {
+				return 0, err
+			}
+			if err := expect(n.y, Number, "right side of "+n.word); err != nil {
+				return 0, err
+			}
+			return Bool, nil
+
+		default:
+			if err := expect(n.x, Number, "left side of "+n.word); err != nil {
+				return 0, err
+			}
+			if err := expect(n.y, Number, "right side of "+n.word); err != nil {
+				return 0, err
+			}
+			return Number, nil
+		}
+
+	case *call:
+		fn, ok := Functions[n.name]
+		if !ok {
+			msg := fmt.Sprintf("unknown function %q", n.name)
+			if s := suggest(n.name, slices.Sorted(maps.Keys(Functions))); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			return 0, errorf(n.pos, "%s", msg)
+		}
+		if len(n.args) != fn.params {
+			return 0, errorf(n.pos, "%s takes %d argument(s), got %d", n.name, fn.params, len(n.args))
+		}
+
+		if !fn.indicator {
+			for i, arg := range n.args {
+				if err := expect(arg, Number, fmt.Sprintf("argument %d of %s", i+1, n.name)); err != nil {
+					return 0, err
+				}
+			}
+			return Number, nil
+		}
+
+		settings, err := literalArgs(n)
+		if err != nil {
+			return 0, err
+		}
+		if err := fn.check(settings); err != nil {
+			return 0, errorf(n.pos, "%s: %v", n.name, err)
+		}
+		return Number, nil
+	}
+
+	return 0, errorf(n.position(), "unsupported expression")
+}
+
+func expect(n node, want Type, what string) error {
+	got, err := check(n)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return errorf(n.position(), "%s must be a %s, not a %s", what, want, got)
+	}
+	return nil
+}
+
+// literalArgs returns the settings of an indicator call, which must be
+// plain numbers.
+func literalArgs(c *call) ([]float64, error) {
+	values := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		lit, ok := arg.(*numberLit)
+		if !ok {
+			return nil, errorf(arg.position(), "argument %d of %s must be a number literal", i+1, c.name)
+		}
+		values[i] = lit.value
+	}
+	return values, nil
+}
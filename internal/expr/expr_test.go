@@ -0,0 +1,207 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testEnv answers variables from vars and indicator calls from
+// indicators, keyed like "sma[50]". Missing values are unavailable.
+type testEnv struct {
+	vars       map[string]float64
+	indicators map[string]float64
+	// calls records the indicator calls made.
+	calls []string
+}
+
+func (e *testEnv) Var(name string) (float64, bool) {
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+func (e *testEnv) Indicator(name string, args []float64) (float64, bool) {
+	key := fmt.Sprintf("%s%v", name, args)
+	e.calls = append(e.calls, key)
+	v, ok := e.indicators[key]
+	return v, ok
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{"price": 120, "previous_price": 100, "change_percent": -4, "volume": 3000}
+	indicators := map[string]float64{"sma[50]": 110, "avg_volume[20]": 1000, "macd[12 26 9]": 0.5}
+
+	tests := []struct {
+		src    string
+		want   bool
+		wantOK bool
+	}{
+		{"price > 100", true, true},
+		{"price >= 120 && price <= 120", true, true},
+		{"1 + 2 * 3 == 7", true, true},
+		{"(1 + 2) * 3 == 9", true, true},
+		{"10 - 4 - 3 == 3", true, true},
+		{"12 / 3 / 2 == 2", true, true},
+		{"-price < 0", true, true},
+		{"--5 == 5", true, true},
+		{"price > 100 or price < 50 and false", true, true},
+		{"(price > 100 or price < 50) and false", false, true},
+		{"not price > 100", false, true},
+		{"NOT price > 200 AND true", true, true},
+		{"!false == true", true, true},
+		{"true != false", true, true},
+		{"abs(change_percent) >= 4", true, true},
+		{"min(price, 1) == 1 and max(price, 1) == price", true, true},
+		{"price > sma(50)", true, true},
+		{"volume > 2 * avg_volume_20d", true, true},
+		{"avg_volume(20) == avg_volume_20d", true, true},
+		{"macd(12, 26, 9) > 0", true, true},
+		{"change > 0", false, false},
+		{"price > sma(200)", false, false},
+		{"price / 0 > 1", false, false},
+		// and/or only need the values their result depends on.
+		{"price < 100 and sma(200) > 0", false, true},
+		{"sma(200) > 0 and price < 100", false, true},
+		{"price > 100 or sma(200) > 0", true, true},
+		{"sma(200) > 0 or price > 100", true, true},
+		{"sma(200) > 0 or price < 100", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			program, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			got, ok := program.Eval(&testEnv{vars: vars, indicators: indicators})
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Eval() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEvalShortCircuits(t *testing.T) {
+	program, err := Compile("price > 200 and sma(50) > 0")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	env := &testEnv{vars: map[string]float64{"price": 100}}
+	program.Eval(env)
+	if len(env.calls) != 0 {
+		t.Errorf("indicators called: %v", env.calls)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "column 1: expression is empty"},
+		{"   ", "column 1: expression is empty"},
+		{"price", "column 1: expression must be a condition (a comparison or and/or/not), not a number"},
+		{"price +", "column 8: expression ends unexpectedly"},
+		{"price > 100)", `column 12: unexpected ")"`},
+		{"(price > 100", `column 13: expected ")" to close "(" at column 1, found end of expression`},
+		{"price = 100", `column 7: unexpected "="; use "==" to compare`},
+		{"price > 100 # comment", `column 13: unexpected character '#'`},
+		{"price > 1.2.3", `column 9: invalid number "1.2.3"`},
+		{"price > 100 100", "column 13: unexpected number 100"},
+		{"prise > 100", `column 1: unknown variable "prise" (did you mean "price"?)`},
+		{"foo > 100", `column 1: unknown variable "foo"`},
+		{"price > smaa(50)", `column 9: unknown function "smaa" (did you mean "sma"?)`},
+		{"price > sma", "column 9: sma is a function; call it like sma(...)"},
+		{"price > sma(50, 2)", "column 9: sma takes 1 argument(s), got 2"},
+		{"price > sma(50 2)", `column 16: expected "," or ")" in call to sma, found number 2`},
+		{"price > sma(volume)", "column 13: argument 1 of sma must be a number literal"},
+		{"price > sma(0)", "column 9: sma: periods must be whole numbers between 1 and 1000"},
+		{"price > sma(2.5)", "column 9: sma: periods must be whole numbers between 1 and 1000"},
+		{"price > ema(1001)", "column 9: ema: periods must be whole numbers between 1 and 1000"},
+		{"macd(26, 12, 9) > 0", "column 1: macd: the fast period must be below the slow period"},
+		{"price > bb_upper(1, 2)", "column 9: bb_upper: needs a period of at least 2 and a positive number of standard deviations"},
+		{"price > bb_upper(20, 0)", "column 9: bb_upper: needs a period of at least 2 and a positive number of standard deviations"},
+		{"price > true", "column 9: right side of > must be a number, not a boolean"},
+		{"price and true", "column 1: left side of and must be a boolean, not a number"},
+		{"true || 1", "column 9: right side of || must be a boolean, not a number"},
+		{"price == true", "column 10: right side of == must be a number, not a boolean"},
+		{"not price", "column 5: operand of not must be a boolean, not a number"},
+		{"-true", "column 2: operand of - must be a number, not a boolean"},
+		{"abs(true) > 1", "column 5: argument 1 of abs must be a number, not a boolean"},
+		{"price + true > 1", "column 9: right side of + must be a number, not a boolean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			if err == nil {
+				t.Fatalf("Compile(%q) succeeded, want %q", tt.src, tt.want)
+			}
+			var exprErr *Error
+			if !errors.As(err, &exprErr) {
+				t.Errorf("error %v is a %T, not an *Error", err, err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{"longest", "price > 1" + strings.Repeat(" ", maxLength-len("price > 1")), ""},
+		{"too long", "price > 1" + strings.Repeat(" ", maxLength-len("price > 1")+1), fmt.Sprintf("expression is longer than %d characters", maxLength)},
+		{"out of range number", "price > " + strings.Repeat("9", 400), `invalid number`},
+		// The comparison and its right side take two levels.
+		{"deepest", strings.Repeat("(", maxDepth-2) + "price > 1" + strings.Repeat(")", maxDepth-2), ""},
+		{"too deep", strings.Repeat("(", maxDepth-1) + "price > 1" + strings.Repeat(")", maxDepth-1), fmt.Sprintf("expression is nested more than %d levels deep", maxDepth)},
+		{"too many negations", "price > " + strings.Repeat("-", maxDepth) + "1", fmt.Sprintf("expression is nested more than %d levels deep", maxDepth)},
+		{"long flat chain", strings.Repeat("price > 1 and ", 100) + "true", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Compile: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIndicators(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"price > 100", nil},
+		{"price > sma(50) and rsi(14) < 30", []string{"sma[50]", "rsi[14]"}},
+		{"volume > avg_volume_20d or abs(macd(12, 26, 9)) > bb_upper(20, 2)", []string{"avg_volume[20]", "macd[12 26 9]", "bb_upper[20 2]"}},
+		{"not (ema(10) > ema(20))", []string{"ema[10]", "ema[20]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			program, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			var got []string
+			for _, call := range program.Indicators() {
+				got = append(got, fmt.Sprintf("%s%v", call.Name, call.Args))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Indicators() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package expr implements the alert expression language: arithmetic and
+// comparisons over a stock's numbers, combined with and/or/not, e.g.
+//
+//	price < 150 and change_percent < -3 and volume > 2 * avg_volume_20d
+//
+// Expressions are parsed and type-checked once by Compile and evaluated
+// against an Env for each price update. They have no side effects, loops
+// or access to anything but the Env, and Compile rejects expressions too
+// long or deeply nested to handle, so they are safe to accept from API
+// clients.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokBool
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	// text is the operator in canonical form ("&&" for "and"), or the
+	// identifier.
+	text string
+	// word is the operator as written, for error messages.
+	word string
+	num  float64
+	pos  int
+}
+
+// keywords are word forms of operators and literals, matched
+// case-insensitively.
+var keywords = map[string]token{
+	"and":   {kind: tokOp, text: "&&"},
+	"or":    {kind: tokOp, text: "||"},
+	"not":   {kind: tokOp, text: "!"},
+	"true":  {kind: tokBool, num: 1},
+	"false": {kind: tokBool, num: 0},
+}
+
+// operators are tried longest first.
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "+", "-", "*", "/", "!"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+
+		case isDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (isDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, errorf(start, "invalid number %q", src[start:i])
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: num, pos: start})
+
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && (isIdentStart(rune(src[i])) || isDigit(rune(src[i]))) {
+				i++
+			}
+			word := src[start:i]
+			if kw, ok := keywords[strings.ToLower(word)]; ok {
+				kw.pos, kw.word = start, word
+				if kw.text == "" {
+					kw.text = strings.ToLower(word)
+				}
+				tokens = append(tokens, kw)
+				continue
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				if c == '=' {
+					return nil, errorf(i, "unexpected \"=\"; use \"==\" to compare")
+				}
+				return nil, errorf(i, "unexpected character %q", src[i])
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, word: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokNumber:
+		return "number " + t.text
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
@@ -0,0 +1,188 @@
+package expr
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// Binding powers of infix operators; higher binds tighter.
+const (
+	precOr = iota + 1
+	precAnd
+	precCompare
+	precSum
+	precProduct
+	precUnary
+)
+
+var infixPrecedence = map[string]int{
+	"||": precOr,
+	"&&": precAnd,
+	"==": precCompare, "!=": precCompare,
+	"<": precCompare, "<=": precCompare, ">": precCompare, ">=": precCompare,
+	"+": precSum, "-": precSum,
+	"*": precProduct, "/": precProduct,
+}
+
+// maxLength and maxDepth bound the expressions parse accepts, which keeps
+// the recursion of parsing, checking and evaluating them off the stack's
+// limit whatever a client sends.
+const (
+	maxLength = 2000
+	maxDepth  = 50
+)
+
+// avgVolumeSugar matches avg_volume_<N>d, shorthand for avg_volume(N).
+var avgVolumeSugar = regexp.MustCompile(`^avg_volume_([0-9]+)d$`)
+
+// parser is a Pratt parser over the token stream.
+type parser struct {
+	tokens []token
+	next   int
+	// depth is how many expressions are being parsed, one inside another.
+	depth int
+}
+
+func parse(src string) (node, error) {
+	if len(src) > maxLength {
+		return nil, errorf(maxLength, "expression is longer than %d characters", maxLength)
+	}
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(0, "expression is empty")
+	}
+
+	n, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.pos, "unexpected %s", t.describe())
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+// expression parses operators binding tighter than minPrec.
+func (p *parser) expression(minPrec int) (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, errorf(p.peek().pos, "expression is nested more than %d levels deep", maxDepth)
+	}
+
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		prec, ok := infixPrecedence[t.text]
+		if t.kind != tokOp || !ok || prec <= minPrec {
+			return left, nil
+		}
+		p.advance()
+
+		right, err := p.expression(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{pos: t.pos, op: t.text, word: t.word, x: left, y: right}
+	}
+}
+
+func (p *parser) prefix() (node, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokNumber:
+		return &numberLit{pos: t.pos, value: t.num}, nil
+
+	case tokBool:
+		return &boolLit{pos: t.pos, value: t.num == 1}, nil
+
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			return p.call(t)
+		}
+		if m := avgVolumeSugar.FindStringSubmatch(t.text); m != nil {
+			days, _ := strconv.Atoi(m[1])
+			return &call{pos: t.pos, name: "avg_volume", args: []node{&numberLit{pos: t.pos, value: float64(days)}}}, nil
+		}
+		return &ident{pos: t.pos, name: t.text}, nil
+
+	case tokLParen:
+		n, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokRParen {
+			return nil, errorf(closing.pos, "expected \")\" to close \"(\" at column %d, found %s", t.pos+1, closing.describe())
+		}
+		return n, nil
+
+	case tokOp:
+		if t.text == "-" || t.text == "!" {
+			// "not" reads like a word, so "not price > 100" negates the
+			// whole comparison; "!" binds as tightly as "-".
+			prec := precUnary
+			if t.word != t.text {
+				prec = precAnd
+			}
+			x, err := p.expression(prec)
+			if err != nil {
+				return nil, err
+			}
+			return &unary{pos: t.pos, op: t.text, word: t.word, x: x}, nil
+		}
+	}
+
+	if t.kind == tokEOF {
+		return nil, errorf(t.pos, "expression ends unexpectedly")
+	}
+	return nil, errorf(t.pos, "unexpected %s", t.describe())
+}
+
+func (p *parser) call(name token) (node, error) {
+	p.advance() // (
+	c := &call{pos: name.pos, name: name.text}
+
+	if p.peek().kind == tokRParen {
+		p.advance()
+		return c, nil
+	}
+
+	for {
+		arg, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, arg)
+
+		switch t := p.advance(); t.kind {
+		case tokComma:
+			continue
+		case tokRParen:
+			return c, nil
+		default:
+			return nil, errorf(t.pos, "expected \",\" or \")\" in call to %s, found %s", name.text, t.describe())
+		}
+	}
+}
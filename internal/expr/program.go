@@ -0,0 +1,197 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+)
+
+// Env supplies the values an expression refers to. ok is false when a
+// value isn't available yet, e.g. an indicator without enough history.
+type Env interface {
+	Var(name string) (value float64, ok bool)
+	// Indicator answers indicator function calls such as sma(50).
+	Indicator(name string, args []float64) (value float64, ok bool)
+}
+
+// Program is a compiled boolean expression.
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses and type-checks src, which must be a boolean expression.
+// Errors are *Error values pointing at the offending column.
+func Compile(src string) (*Program, error) {
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := check(root)
+	if err != nil {
+		return nil, err
+	}
+	if t != Bool {
+		return nil, errorf(0, "expression must be a condition (a comparison or and/or/not), not a %s", t)
+	}
+
+	return &Program{src: src, root: root}, nil
+}
+
+func (p *Program) String() string {
+	return p.src
+}
+
+// IndicatorCall is an indicator function call in a program, e.g. sma(50).
+type IndicatorCall struct {
+	Name string
+	Args []float64
+}
+
+// Indicators returns the program's indicator calls in the order they
+// appear, whether or not an evaluation reaches them.
+func (p *Program) Indicators() []IndicatorCall {
+	var calls []IndicatorCall
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case *unary:
+			walk(n.x)
+		case *binary:
+			walk(n.x)
+			walk(n.y)
+		case *call:
+			if Functions[n.name].indicator {
+				args, _ := literalArgs(n)
+				calls = append(calls, IndicatorCall{Name: n.name, Args: args})
+				return
+			}
+			for _, arg := range n.args {
+				walk(arg)
+			}
+		}
+	}
+	walk(p.root)
+	return calls
+}
+
+// Eval evaluates the program. ok is false when a value it needs is not
+// available, or on division by zero.
+func (p *Program) Eval(env Env) (result bool, ok bool) {
+	v, ok := eval(p.root, env)
+	return v != 0, ok
+}
+
+// eval computes n, representing booleans as 0 and 1. and/or short-circuit,
+// so an unavailable value only matters if the result depends on it.
+func eval(n node, env Env) (float64, bool) {
+	switch n := n.(type) {
+	case *numberLit:
+		return n.value, true
+
+	case *boolLit:
+		return truth(n.value), true
+
+	case *ident:
+		return env.Var(n.name)
+
+	case *unary:
+		x, ok := eval(n.x, env)
+		if !ok {
+			return 0, false
+		}
+		if n.op == "!" {
+			return truth(x == 0), true
+		}
+		return -x, true
+
+	case *binary:
+		x, ok := eval(n.x, env)
+		switch {
+		case n.op == "&&" && ok && x == 0:
+			return 0, true
+		case n.op == "||" && ok && x != 0:
+			return 1, true
+		}
+
+		y, yok := eval(n.y, env)
+		switch n.op {
+		case "&&":
+			if yok && y == 0 {
+				return 0, true
+			}
+			return truth(x != 0 && y != 0), ok && yok
+		case "||":
+			if yok && y != 0 {
+				return 1, true
+			}
+			return truth(x != 0 || y != 0), ok && yok
+		}
+		if !ok || !yok {
+			return 0, false
+		}
+		return arithmetic(n.op, x, y)
+
+	case *call:
+		fn := Functions[n.name]
+		if fn.indicator {
+			args, _ := literalArgs(n)
+			return env.Indicator(n.name, args)
+		}
+
+		args := make([]float64, len(n.args))
+		for i, arg := range n.args {
+			v, ok := eval(arg, env)
+			if !ok {
+				return 0, false
+			}
+			args[i] = v
+		}
+		switch n.name {
+		case "abs":
+			return math.Abs(args[0]), true
+		case "min":
+			return math.Min(args[0], args[1]), true
+		case "max":
+			return math.Max(args[0], args[1]), true
+		}
+	}
+
+	panic(fmt.Sprintf("expr: unchecked node %T", n))
+}
+
+func arithmetic(op string, x, y float64) (float64, bool) {
+	switch op {
+	case "+":
+		return x + y, true
+	case "-":
+		return x - y, true
+	case "*":
+		return x * y, true
+	case "/":
+		if y == 0 {
+			return 0, false
+		}
+		return x / y, true
+	case "==":
+		return truth(x == y), true
+	case "!=":
+		return truth(x != y), true
+	case "<":
+		return truth(x < y), true
+	case "<=":
+		return truth(x <= y), true
+	case ">":
+		return truth(x > y), true
+	case ">=":
+		return truth(x >= y), true
+	}
+	panic("expr: unknown operator " + op)
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
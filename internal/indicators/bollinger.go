@@ -0,0 +1,57 @@
+package indicators
+
+import "math"
+
+// Bands are Bollinger Bands around a moving average.
+type Bands struct {
+	Upper  float64 `json:"upper"`
+	Middle float64 `json:"middle"`
+	Lower  float64 `json:"lower"`
+}
+
+// Bollinger places bands k standard deviations above and below the SMA of
+// the last period prices.
+type Bollinger struct {
+	sma   *SMA
+	k     float64
+	sumSq float64
+	last  float64
+}
+
+func NewBollinger(period int, k float64) *Bollinger {
+	return &Bollinger{sma: NewSMA(period), k: k}
+}
+
+func (b *Bollinger) Update(price float64) {
+	if b.sma.count == b.sma.period {
+		old := b.sma.window[b.sma.next]
+		b.sumSq -= old * old
+	}
+	b.sma.Update(price)
+	b.sumSq += price * price
+	b.last = price
+}
+
+func (b *Bollinger) Value() (Bands, bool) {
+	mean, ok := b.sma.Value()
+	if !ok {
+		return Bands{}, false
+	}
+	n := float64(b.sma.period)
+	// Population standard deviation; clamp rounding noise below zero.
+	stddev := math.Sqrt(math.Max(b.sumSq/n-mean*mean, 0))
+	return Bands{
+		Upper:  mean + b.k*stddev,
+		Middle: mean,
+		Lower:  mean - b.k*stddev,
+	}, true
+}
+
+// Last returns the most recent price.
+func (b *Bollinger) Last() float64 {
+	return b.last
+}
+
+func (b *Bollinger) Warmup() int {
+	return b.sma.Warmup()
+}
@@ -0,0 +1,38 @@
+package indicators
+
+// EMA is the exponential moving average with smoothing 2/(period+1),
+// seeded with the simple average of the first period prices.
+type EMA struct {
+	period int
+	k      float64
+	count  int
+	value  float64
+}
+
+func NewEMA(period int) *EMA {
+	return &EMA{period: period, k: 2 / float64(period+1)}
+}
+
+func (e *EMA) Update(price float64) {
+	e.count++
+	switch {
+	case e.count < e.period:
+		e.value += price
+	case e.count == e.period:
+		e.value = (e.value + price) / float64(e.period)
+	default:
+		e.value += e.k * (price - e.value)
+	}
+}
+
+func (e *EMA) Value() (float64, bool) {
+	if e.count < e.period {
+		return 0, false
+	}
+	return e.value, true
+}
+
+// Warmup is how many prices it takes for the seed to stop mattering.
+func (e *EMA) Warmup() int {
+	return 3 * e.period
+}
@@ -0,0 +1,179 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+// closes are the closes of the StockCharts EMA example.
+var closes = []float64{22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29, 22.15, 22.39, 22.38, 22.61, 23.36}
+
+const tolerance = 0.005
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// averager is an SMA or EMA.
+type averager interface {
+	Update(float64)
+	Value() (float64, bool)
+}
+
+func TestAverages(t *testing.T) {
+	tests := []struct {
+		name   string
+		ind    averager
+		prices []float64
+		// want holds the value after each price, NaN while there is none.
+		want []float64
+	}{
+		{"sma(3)", NewSMA(3), []float64{1, 2, 3, 4, 5}, []float64{math.NaN(), math.NaN(), 2, 3, 4}},
+		{"sma(1)", NewSMA(1), []float64{5, 7}, []float64{5, 7}},
+		{"ema(3)", NewEMA(3), []float64{1, 2, 3, 4, 5}, []float64{math.NaN(), math.NaN(), 2, 3, 4}},
+		{"ema(10) stockcharts", NewEMA(10), closes, []float64{
+			math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN(),
+			math.NaN(), math.NaN(), math.NaN(), math.NaN(),
+			22.22, 22.21, 22.24, 22.27, 22.33, 22.52,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, price := range tt.prices {
+				tt.ind.Update(price)
+				got, ok := tt.ind.Value()
+				want := tt.want[i]
+				if math.IsNaN(want) {
+					if ok {
+						t.Errorf("after price %d: value %v, want none", i+1, got)
+					}
+					continue
+				}
+				if !ok || !near(got, want) {
+					t.Errorf("after price %d: value %v (ok %v), want %v", i+1, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRSI(t *testing.T) {
+	// Closes of the StockCharts RSI example. Its table rounds the average
+	// gains and losses, so these values are a few hundredths off it.
+	rsiCloses := []float64{
+		44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+		45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+	}
+
+	tests := []struct {
+		name   string
+		period int
+		prices []float64
+		// want holds the values from the first one on.
+		want []float64
+	}{
+		{"only gains", 2, []float64{1, 2, 3, 4}, []float64{100, 100}},
+		{"only losses", 2, []float64{4, 3, 2}, []float64{0}},
+		{"even then smoothed", 2, []float64{1, 2, 1, 2}, []float64{50, 75}},
+		{"flat", 3, []float64{5, 5, 5, 5}, []float64{100}},
+		{"stockcharts", 14, rsiCloses, []float64{70.46, 66.25, 66.48, 69.35, 66.29, 57.92}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsi := NewRSI(tt.period)
+			var got []float64
+			for _, price := range tt.prices {
+				rsi.Update(price)
+				if value, ok := rsi.Value(); ok {
+					got = append(got, value)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("values = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !near(got[i], tt.want[i]) {
+					t.Errorf("values = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestMACD(t *testing.T) {
+	t.Run("linear prices", func(t *testing.T) {
+		// On a straight line each EMA lags by (period-1)/2 steps, so the
+		// line settles at the difference in lag.
+		macd := NewMACD(2, 3, 2)
+		for price := 1.0; price <= 3; price++ {
+			macd.Update(price)
+			if _, _, _, ok := macd.Value(); ok {
+				t.Fatalf("value after price %v, before the signal line has warmed up", price)
+			}
+		}
+		macd.Update(4)
+		line, signal, histogram, ok := macd.Value()
+		if !ok || !near(line, 0.5) || !near(signal, 0.5) || !near(histogram, 0) {
+			t.Errorf("Value() = %v, %v, %v, %v, want 0.5, 0.5, 0, true", line, signal, histogram, ok)
+		}
+	})
+
+	t.Run("matches its averages", func(t *testing.T) {
+		macd := NewMACD(3, 6, 4)
+		fast, slow, sig := NewEMA(3), NewEMA(6), NewEMA(4)
+		for _, price := range closes {
+			macd.Update(price)
+			fast.Update(price)
+			slow.Update(price)
+			f, _ := fast.Value()
+			s, ok := slow.Value()
+			if ok {
+				sig.Update(f - s)
+			}
+
+			line, signal, histogram, ok := macd.Value()
+			wantSignal, wantOK := sig.Value()
+			if ok != wantOK {
+				t.Fatalf("ok = %v, want %v", ok, wantOK)
+			}
+			if ok && (!near(line, f-s) || !near(signal, wantSignal) || !near(histogram, line-signal)) {
+				t.Errorf("Value() = %v, %v, %v, want %v, %v, %v", line, signal, histogram, f-s, wantSignal, f-s-wantSignal)
+			}
+		}
+	})
+}
+
+func TestBollinger(t *testing.T) {
+	tests := []struct {
+		name   string
+		period int
+		k      float64
+		prices []float64
+		want   Bands
+	}{
+		// Population standard deviation of 1, 2, 3 is sqrt(2/3).
+		{"rising", 3, 2, []float64{1, 2, 3}, Bands{Upper: 3.633, Middle: 2, Lower: 0.367}},
+		{"window slides", 3, 2, []float64{100, 1, 2, 3}, Bands{Upper: 3.633, Middle: 2, Lower: 0.367}},
+		{"flat", 4, 2, []float64{10, 10, 10, 10}, Bands{Upper: 10, Middle: 10, Lower: 10}},
+		{"one deviation", 2, 1, []float64{4, 6}, Bands{Upper: 6, Middle: 5, Lower: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bb := NewBollinger(tt.period, tt.k)
+			for i, price := range tt.prices {
+				if _, ok := bb.Value(); ok && i < tt.period {
+					t.Fatalf("value after %d prices, want none", i)
+				}
+				bb.Update(price)
+			}
+			got, ok := bb.Value()
+			if !ok || !near(got.Upper, tt.want.Upper) || !near(got.Middle, tt.want.Middle) || !near(got.Lower, tt.want.Lower) {
+				t.Errorf("Value() = %+v, %v, want %+v", got, ok, tt.want)
+			}
+			if last := tt.prices[len(tt.prices)-1]; bb.Last() != last {
+				t.Errorf("Last() = %v, want %v", bb.Last(), last)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package indicators
+
+// MACD is the difference between a fast and a slow EMA, with an EMA of
+// that difference as its signal line.
+type MACD struct {
+	fast   *EMA
+	slow   *EMA
+	signal *EMA
+	line   float64
+}
+
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+func (m *MACD) Update(price float64) {
+	m.fast.Update(price)
+	m.slow.Update(price)
+
+	fast, ok := m.fast.Value()
+	if !ok {
+		return
+	}
+	slow, ok := m.slow.Value()
+	if !ok {
+		return
+	}
+	m.line = fast - slow
+	m.signal.Update(m.line)
+}
+
+// Value returns the MACD line, the signal line and their difference.
+func (m *MACD) Value() (line, signal, histogram float64, ok bool) {
+	signal, ok = m.signal.Value()
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return m.line, signal, m.line - signal, true
+}
+
+func (m *MACD) Warmup() int {
+	return m.slow.Warmup() + m.signal.Warmup()
+}
@@ -0,0 +1,57 @@
+package indicators
+
+// RSI is Wilder's relative strength index over period price changes.
+type RSI struct {
+	period  int
+	count   int
+	last    float64
+	avgGain float64
+	avgLoss float64
+}
+
+func NewRSI(period int) *RSI {
+	return &RSI{period: period}
+}
+
+func (r *RSI) Update(price float64) {
+	r.count++
+	if r.count == 1 {
+		r.last = price
+		return
+	}
+
+	change := price - r.last
+	r.last = price
+	gain, loss := 0.0, 0.0
+	if change > 0 {
+		gain = change
+	} else {
+		loss = -change
+	}
+
+	// The first period changes are averaged plainly, then smoothed.
+	n := float64(r.period)
+	if r.count <= r.period+1 {
+		r.avgGain += gain / n
+		r.avgLoss += loss / n
+		return
+	}
+	r.avgGain = (r.avgGain*(n-1) + gain) / n
+	r.avgLoss = (r.avgLoss*(n-1) + loss) / n
+}
+
+// Value returns the RSI, between 0 and 100, once period changes have been
+// seen.
+func (r *RSI) Value() (float64, bool) {
+	if r.count <= r.period {
+		return 0, false
+	}
+	if r.avgLoss == 0 {
+		return 100, true
+	}
+	return 100 - 100/(1+r.avgGain/r.avgLoss), true
+}
+
+func (r *RSI) Warmup() int {
+	return 3*r.period + 1
+}
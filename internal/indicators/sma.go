@@ -0,0 +1,40 @@
+// Package indicators computes technical indicators incrementally: each
+// indicator is fed one price at a time, oldest first, and keeps only the
+// state it needs for the next value.
+package indicators
+
+// SMA is the simple moving average of the last period prices.
+type SMA struct {
+	period int
+	window []float64
+	next   int
+	count  int
+	sum    float64
+}
+
+func NewSMA(period int) *SMA {
+	return &SMA{period: period, window: make([]float64, period)}
+}
+
+func (s *SMA) Update(price float64) {
+	if s.count == s.period {
+		s.sum -= s.window[s.next]
+	} else {
+		s.count++
+	}
+	s.window[s.next] = price
+	s.sum += price
+	s.next = (s.next + 1) % s.period
+}
+
+// Value returns the average once period prices have been seen.
+func (s *SMA) Value() (float64, bool) {
+	if s.count < s.period {
+		return 0, false
+	}
+	return s.sum / float64(s.period), true
+}
+
+func (s *SMA) Warmup() int {
+	return s.period
+}
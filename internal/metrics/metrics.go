@@ -9,13 +9,34 @@ type Metrics struct {
 	APICallsTotal       *prometheus.CounterVec
 	APICallDuration     *prometheus.HistogramVec
 	APICallErrors       *prometheus.CounterVec
+	ProviderHealthScore *prometheus.GaugeVec
+	ProviderDemoted     *prometheus.GaugeVec
+	ProviderFailovers   *prometheus.CounterVec
+	RateLimitWait       *prometheus.HistogramVec
+	QuotaUsed           *prometheus.GaugeVec
+	QuotaRemaining      *prometheus.GaugeVec
+	QuotaResetTime      *prometheus.GaugeVec
+	QuotaExhausted      *prometheus.GaugeVec
 	StockUpdateDuration *prometheus.HistogramVec
 	StockUpdatesTotal   *prometheus.CounterVec
 	CurrentStockPrice   *prometheus.GaugeVec
 	StockPriceChange    *prometheus.GaugeVec
+	StockVolume         *prometheus.GaugeVec
+	PricesSaved         *prometheus.CounterVec
+	PriceBatchSize      prometheus.Histogram
+	PriceIngestDuration prometheus.Histogram
+	BarsSaved           *prometheus.CounterVec
+	RetentionCompacted  *prometheus.CounterVec
+	RetentionDeleted    *prometheus.CounterVec
 	AlertsTriggered     *prometheus.CounterVec
+	AlertsSuppressed    *prometheus.CounterVec
+	AlertRuleErrors     *prometheus.CounterVec
+	NotificationsSent   *prometheus.CounterVec
+	NotificationRetries *prometheus.CounterVec
 	TrackedStocksCount  prometheus.Gauge
 	UpdateCyclesTotal   prometheus.Counter
+	UpdateCycleDuration prometheus.Histogram
+	UpdateCycleStocks   *prometheus.CounterVec
 	WebSocketClients    prometheus.Gauge
 	HTTPRequestsTotal   *prometheus.CounterVec
 }
@@ -44,6 +65,63 @@ func New() *Metrics {
 			},
 			[]string{"provider", "symbol", "error_type"},
 		),
+		ProviderHealthScore: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "stock_tracker_provider_health_score",
+				Help: "Quote provider health score from recent error rate and latency (0-1)",
+			},
+			[]string{"provider"},
+		),
+		ProviderDemoted: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "stock_tracker_provider_demoted",
+				Help: "Whether a quote provider is currently demoted in the failover chain",
+			},
+			[]string{"provider"},
+		),
+		ProviderFailovers: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_provider_failovers_total",
+				Help: "Total number of times a provider failed and the next one was tried",
+			},
+			[]string{"provider"},
+		),
+		RateLimitWait: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "stock_tracker_rate_limit_wait_seconds",
+				Help:    "Time spent waiting for the provider rate limiter",
+				Buckets: []float64{0.01, 0.1, 1, 5, 15, 30, 60},
+			},
+			[]string{"provider"},
+		),
+		QuotaUsed: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "stock_tracker_provider_quota_used",
+				Help: "Provider requests made in the current quota day",
+			},
+			[]string{"provider"},
+		),
+		QuotaRemaining: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "stock_tracker_provider_quota_remaining",
+				Help: "Provider requests left in the current quota day",
+			},
+			[]string{"provider"},
+		),
+		QuotaResetTime: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "stock_tracker_provider_quota_reset_timestamp_seconds",
+				Help: "Unix time at which the provider daily quota resets",
+			},
+			[]string{"provider"},
+		),
+		QuotaExhausted: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "stock_tracker_provider_quota_exhausted",
+				Help: "Whether the provider daily quota is exhausted",
+			},
+			[]string{"provider"},
+		),
 		StockUpdateDuration: promauto.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "stock_tracker_update_duration_seconds",
@@ -73,6 +151,55 @@ func New() *Metrics {
 			},
 			[]string{"symbol"},
 		),
+		StockVolume: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "stock_tracker_volume",
+				Help: "Shares traded so far in the current trading day",
+			},
+			[]string{"symbol"},
+		),
+		PricesSaved: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_prices_saved_total",
+				Help: "Prices written to the database by status (saved, failed)",
+			},
+			[]string{"status"},
+		),
+		PriceBatchSize: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "stock_tracker_price_batch_size",
+				Help:    "Number of prices saved per batch",
+				Buckets: []float64{1, 10, 25, 50, 100, 250, 500, 1000},
+			},
+		),
+		PriceIngestDuration: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "stock_tracker_price_ingest_duration_seconds",
+				Help:    "Time taken to save a batch of prices",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+			},
+		),
+		BarsSaved: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_bars_saved_total",
+				Help: "Intraday price bars fetched and saved, by interval",
+			},
+			[]string{"interval"},
+		),
+		RetentionCompacted: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_retention_rows_compacted_total",
+				Help: "Price rollups written by the retention job, by the tier written to",
+			},
+			[]string{"tier"},
+		),
+		RetentionDeleted: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_retention_rows_deleted_total",
+				Help: "Prices deleted by the retention job, by the tier deleted from",
+			},
+			[]string{"tier"},
+		),
 		AlertsTriggered: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "stock_tracker_alerts_triggered_total",
@@ -80,6 +207,34 @@ func New() *Metrics {
 			},
 			[]string{"symbol", "alert_type"},
 		),
+		AlertsSuppressed: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_alerts_suppressed_total",
+				Help: "Alerts not sent because the rule was cooling down, disarmed or the alert was a duplicate",
+			},
+			[]string{"symbol", "reason"},
+		),
+		AlertRuleErrors: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_alert_rule_errors_total",
+				Help: "Alert rule evaluations that failed, e.g. because history could not be loaded",
+			},
+			[]string{"symbol", "rule_type"},
+		),
+		NotificationsSent: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_notifications_total",
+				Help: "Alert notifications by channel type and final status (delivered, failed)",
+			},
+			[]string{"channel_type", "status"},
+		),
+		NotificationRetries: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_notification_retries_total",
+				Help: "Alert notification attempts that failed and were retried",
+			},
+			[]string{"channel_type"},
+		),
 		TrackedStocksCount: promauto.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "stock_tracker_tracked_stocks_count",
@@ -92,6 +247,20 @@ func New() *Metrics {
 				Help: "Total number of update cycles completed",
 			},
 		),
+		UpdateCycleDuration: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "stock_tracker_update_cycle_duration_seconds",
+				Help:    "Duration of full update cycles",
+				Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+			},
+		),
+		UpdateCycleStocks: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "stock_tracker_update_cycle_stocks_total",
+				Help: "Stocks processed by update cycles by result (success, failed, skipped)",
+			},
+			[]string{"result"},
+		),
 		WebSocketClients: promauto.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "stock_tracker_websocket_clients",
@@ -0,0 +1,360 @@
+// Package migrate applies the numbered SQL migrations embedded in the
+// binaries and records the applied versions in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-tracker/pkg/logger"
+)
+
+// ErrSchemaBehind is returned by Check when the database lacks migrations
+// this build needs.
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// Migration is one schema change: NNN_name.sql, undone by NNN_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// Down is empty when the migration can't be rolled back.
+	Down string
+}
+
+// Status is a migration and whether the database has it.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Dialect holds the SQL that differs between databases.
+type Dialect struct {
+	createTable string
+	tableExists string
+	isApplied   string
+	insert      string
+	delete      string
+	// lock and unlock serialize migrators across processes; empty when
+	// the database serializes writers itself.
+	lock   string
+	unlock string
+}
+
+// lockKey is the PostgreSQL advisory lock the migrators share. The value
+// is arbitrary but must never change.
+const lockKey int64 = 4127355019
+
+var (
+	Postgres = Dialect{
+		createTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+			)
+		`,
+		tableExists: `SELECT to_regclass('schema_migrations') IS NOT NULL`,
+		isApplied:   `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`,
+		insert:      `INSERT INTO schema_migrations (version) VALUES ($1)`,
+		delete:      `DELETE FROM schema_migrations WHERE version = $1`,
+		lock:        `SELECT pg_advisory_lock($1)`,
+		unlock:      `SELECT pg_advisory_unlock($1)`,
+	}
+
+	// SQLite takes the write lock when a migration's transaction begins,
+	// given the _txlock=immediate connection setting.
+	SQLite = Dialect{
+		createTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				applied_at INTEGER NOT NULL
+			)
+		`,
+		tableExists: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`,
+		isApplied:   `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?1)`,
+		insert:      `INSERT INTO schema_migrations (version, applied_at) VALUES (?1, CAST(unixepoch('subsec') * 1000000 AS INTEGER))`,
+		delete:      `DELETE FROM schema_migrations WHERE version = ?1`,
+	}
+)
+
+// Load reads the migrations at the root of fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base, down := strings.CutSuffix(file, ".down.sql")
+		if !down {
+			base = strings.TrimSuffix(file, ".sql")
+		}
+		prefix, _, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version number", file)
+		}
+
+		script, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: base}
+			byVersion[version] = m
+		}
+		if m.Name != base {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", m.Name, base, version)
+		}
+		if down {
+			m.Down = string(script)
+		} else {
+			m.Up = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has a down script but no up script", m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies a set of migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}
+}
+
+// Close closes the database the Migrator was created with.
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Status lists every migration of this build and whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+	return statuses, nil
+}
+
+// Check returns an error wrapping ErrSchemaBehind unless every migration
+// of this build is applied. A database migrated by a newer build passes
+// with a warning, as migrations only ever add to the schema.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]bool, len(m.migrations))
+	var pending []string
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration.Name)
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s not applied; run `tracker migrate up`",
+			ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+
+	for version := range applied {
+		if !known[version] {
+			logger.Warn().Int("version", version).Msg("Database has a migration this build doesn't know; is the binary out of date?")
+		}
+	}
+
+	return nil
+}
+
+// Up applies the pending migrations in order, each in its own
+// transaction, and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		for _, migration := range m.migrations {
+			applied, err := m.apply(ctx, conn, migration.Version, migration.Up, m.dialect.insert, false)
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", migration.Name, err)
+			}
+			if applied {
+				logger.Info().Str("migration", migration.Name).Msg("Applied database migration")
+				done = append(done, migration)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns the ones it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %s can't be rolled back", migration.Name)
+			}
+
+			if _, err := m.apply(ctx, conn, migration.Version, migration.Down, m.dialect.delete, true); err != nil {
+				return fmt.Errorf("failed to roll back migration %s: %w", migration.Name, err)
+			}
+			logger.Info().Str("migration", migration.Name).Msg("Rolled back database migration")
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// locked runs fn on one connection while holding the migration lock, with
+// the schema_migrations table in place.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	if m.dialect.lock != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lock, lockKey); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), m.dialect.unlock, lockKey); err != nil {
+				logger.Error().Err(err).Msg("Failed to release migration lock")
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// apply runs script and records it with record, unless the migration is
+// already in the wanted state. wasApplied is the state script expects.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version int, script, record string, wasApplied bool) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Checked inside the transaction as well, for databases that rely on
+	// their write lock rather than an advisory lock.
+	var applied bool
+	err = tx.QueryRowContext(ctx, m.dialect.isApplied, version).Scan(&applied)
+	if err != nil || applied != wasApplied {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, record, version); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// applied returns the applied versions and when they were applied; none
+// if the database has never been migrated.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]time.Time, error) {
+	var exists bool
+	if err := q.QueryRowContext(ctx, m.dialect.tableExists).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	applied := make(map[int]time.Time)
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, appliedAt{&at}); err != nil {
+			return nil, fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// appliedAt scans applied_at, a timestamp in PostgreSQL and microseconds
+// since the Unix epoch in SQLite.
+type appliedAt struct {
+	t *time.Time
+}
+
+func (a appliedAt) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*a.t = v
+	case int64:
+		*a.t = time.UnixMicro(v).UTC()
+	default:
+		return fmt.Errorf("unexpected applied_at type %T", src)
+	}
+	return nil
+}
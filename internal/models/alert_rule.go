@@ -0,0 +1,246 @@
+package models
+
+import (
+	"fmt"
+	"stock-tracker/internal/expr"
+	"time"
+)
+
+// Alert rule types.
+const (
+	// RulePriceAbove fires when the price is at or above Threshold.
+	RulePriceAbove = "price_above"
+	// RulePriceBelow fires when the price is at or below Threshold.
+	RulePriceBelow = "price_below"
+	// RuleChangeOverWindow fires when the price moved by at least Threshold
+	// percent, either way, compared with the price WindowSeconds ago.
+	RuleChangeOverWindow = "percent_change_window"
+	// RuleChangeFromClose fires when the price moved by at least Threshold
+	// percent, either way, from the previous close.
+	RuleChangeFromClose = "percent_change_prev_close"
+
+	// Indicator rules; their periods count stored price samples.
+
+	// RuleSMACross fires when the fast SMA crosses the slow SMA in
+	// Params.Direction, e.g. a 50/200 golden cross.
+	RuleSMACross = "sma_cross"
+	// RuleEMACross is RuleSMACross with exponential averages.
+	RuleEMACross = "ema_cross"
+	// RuleMACDCross fires when the MACD line crosses its signal line in
+	// Params.Direction.
+	RuleMACDCross = "macd_cross"
+	// RuleRSIAbove fires when RSI(Params.Period) is at or above Threshold.
+	RuleRSIAbove = "rsi_above"
+	// RuleRSIBelow fires when RSI(Params.Period) is at or below Threshold.
+	RuleRSIBelow = "rsi_below"
+	// RuleBollingerBreakout fires when the price closes outside the upper
+	// (Direction "above") or lower ("below") Bollinger Band.
+	RuleBollingerBreakout = "bollinger_breakout"
+
+	// RuleVolumeSpike fires when the day's volume is at least Threshold
+	// times the average daily volume.
+	RuleVolumeSpike = "volume_spike"
+
+	// RuleExpression fires when Expression, a condition in the expr
+	// language, holds.
+	RuleExpression = "expression"
+)
+
+// Directions of crossover and breakout rules.
+const (
+	DirectionAbove = "above"
+	DirectionBelow = "below"
+)
+
+// RuleParams are the indicator settings of indicator rules.
+type RuleParams struct {
+	Period    int     `json:"period,omitempty"`
+	Fast      int     `json:"fast,omitempty"`
+	Slow      int     `json:"slow,omitempty"`
+	Signal    int     `json:"signal,omitempty"`
+	StdDev    float64 `json:"stddev,omitempty"`
+	Direction string  `json:"direction,omitempty"`
+	// MinVolumeRatio, on any rule, holds the rule back until the day's
+	// volume is at least this multiple of the average daily volume.
+	MinVolumeRatio float64 `json:"min_volume_ratio,omitempty"`
+}
+
+type AlertRule struct {
+	ID            int     `json:"id"`
+	StockID       int     `json:"stock_id"`
+	Symbol        string  `json:"symbol"`
+	RuleType      string  `json:"rule_type"`
+	Threshold     float64 `json:"threshold"`
+	WindowSeconds int     `json:"window_seconds,omitempty"`
+	// Expression is the condition of expression rules.
+	Expression string `json:"expression,omitempty"`
+	// CooldownSeconds is the minimum time between two alerts of the rule.
+	CooldownSeconds int `json:"cooldown_seconds"`
+	// Hysteresis is how far, in the units of Threshold, the value has to
+	// come back inside the threshold before the rule can fire again.
+	Hysteresis float64        `json:"hysteresis"`
+	Params     RuleParams     `json:"params"`
+	Enabled    bool           `json:"enabled"`
+	State      AlertRuleState `json:"state"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// AlertRuleState is where a rule is in its fire/re-arm cycle.
+type AlertRuleState struct {
+	// Armed is cleared when the rule fires and set again once the value is
+	// back inside the hysteresis band.
+	Armed bool `json:"armed"`
+	// Episode counts the times the rule fired; it makes up the dedup key.
+	Episode     int        `json:"episode"`
+	LastFiredAt *time.Time `json:"last_fired_at,omitempty"`
+}
+
+func (r *AlertRule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+func (r *AlertRule) Cooldown() time.Duration {
+	return time.Duration(r.CooldownSeconds) * time.Second
+}
+
+// CoolingDown reports whether the rule fired less than its cooldown ago.
+func (r *AlertRule) CoolingDown(now time.Time) bool {
+	return r.State.LastFiredAt != nil && now.Sub(*r.State.LastFiredAt) < r.Cooldown()
+}
+
+// DedupKey identifies the rule's next alert.
+func (r *AlertRule) DedupKey() string {
+	return fmt.Sprintf("rule:%d:%d", r.ID, r.State.Episode+1)
+}
+
+// IsCrossing reports whether the rule fires on a line being crossed rather
+// than on a threshold.
+func (r *AlertRule) IsCrossing() bool {
+	switch r.RuleType {
+	case RuleSMACross, RuleEMACross, RuleMACDCross, RuleBollingerBreakout:
+		return true
+	}
+	return false
+}
+
+// SetDefaults fills in unset indicator parameters with the customary ones.
+func (r *AlertRule) SetDefaults() {
+	if r.RuleType != RuleExpression {
+		r.Expression = ""
+	}
+
+	p := &r.Params
+	switch r.RuleType {
+	case RuleSMACross, RuleEMACross:
+		if p.Fast == 0 {
+			p.Fast = 50
+		}
+		if p.Slow == 0 {
+			p.Slow = 200
+		}
+	case RuleMACDCross:
+		if p.Fast == 0 {
+			p.Fast = 12
+		}
+		if p.Slow == 0 {
+			p.Slow = 26
+		}
+		if p.Signal == 0 {
+			p.Signal = 9
+		}
+	case RuleRSIAbove, RuleRSIBelow:
+		if p.Period == 0 {
+			p.Period = 14
+		}
+	case RuleBollingerBreakout:
+		if p.Period == 0 {
+			p.Period = 20
+		}
+		if p.StdDev == 0 {
+			p.StdDev = 2
+		}
+	}
+	if r.IsCrossing() && p.Direction == "" {
+		p.Direction = DirectionAbove
+	}
+}
+
+// Validate checks that the rule's fields make sense for its type.
+func (r *AlertRule) Validate() error {
+	p := r.Params
+	switch r.RuleType {
+	case RulePriceAbove, RulePriceBelow:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be a positive price")
+		}
+	case RuleChangeOverWindow:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be a positive percentage")
+		}
+		if r.WindowSeconds <= 0 {
+			return fmt.Errorf("window_seconds is required for %s rules", r.RuleType)
+		}
+	case RuleChangeFromClose:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be a positive percentage")
+		}
+	case RuleSMACross, RuleEMACross:
+		if p.Fast < 1 || p.Slow <= p.Fast {
+			return fmt.Errorf("params.fast must be positive and below params.slow")
+		}
+	case RuleMACDCross:
+		if p.Fast < 1 || p.Slow <= p.Fast || p.Signal < 1 {
+			return fmt.Errorf("params.fast must be positive and below params.slow, and params.signal positive")
+		}
+	case RuleRSIAbove, RuleRSIBelow:
+		if p.Period < 1 {
+			return fmt.Errorf("params.period must be positive")
+		}
+		if r.Threshold <= 0 || r.Threshold >= 100 {
+			return fmt.Errorf("threshold must be an RSI level between 0 and 100")
+		}
+	case RuleBollingerBreakout:
+		if p.Period < 2 || p.StdDev <= 0 {
+			return fmt.Errorf("params.period must be at least 2 and params.stddev positive")
+		}
+	case RuleVolumeSpike:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be a positive multiple of the average volume")
+		}
+	case RuleExpression:
+		if r.Expression == "" {
+			return fmt.Errorf("expression is required for %s rules", r.RuleType)
+		}
+		if _, err := expr.Compile(r.Expression); err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+		if r.Hysteresis != 0 {
+			return fmt.Errorf("hysteresis is not supported by %s rules", r.RuleType)
+		}
+	case "":
+		return fmt.Errorf("rule_type is required")
+	default:
+		return fmt.Errorf("unknown rule_type %q", r.RuleType)
+	}
+
+	if r.IsCrossing() && p.Direction != DirectionAbove && p.Direction != DirectionBelow {
+		return fmt.Errorf("params.direction must be %q or %q", DirectionAbove, DirectionBelow)
+	}
+	if p.MinVolumeRatio < 0 {
+		return fmt.Errorf("params.min_volume_ratio must not be negative")
+	}
+	if r.WindowSeconds < 0 {
+		return fmt.Errorf("window_seconds must not be negative")
+	}
+	if r.CooldownSeconds < 0 {
+		return fmt.Errorf("cooldown_seconds must not be negative")
+	}
+	if r.Hysteresis < 0 {
+		return fmt.Errorf("hysteresis must not be negative")
+	}
+	if !r.IsCrossing() && r.RuleType != RuleExpression && r.Hysteresis >= r.Threshold {
+		return fmt.Errorf("hysteresis must be below the threshold")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Alert states.
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	// AlertSnoozed alerts count as open again once SnoozedUntil has passed.
+	AlertSnoozed  = "snoozed"
+	AlertResolved = "resolved"
+)
+
+// AlertStateChange is one entry of an alert's audit trail.
+type AlertStateChange struct {
+	ID           int64      `json:"id"`
+	AlertID      int        `json:"alert_id"`
+	FromState    string     `json:"from_state"`
+	ToState      string     `json:"to_state"`
+	ChangedBy    string     `json:"changed_by"`
+	Note         string     `json:"note,omitempty"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	ChangedAt    time.Time  `json:"changed_at"`
+}
+
+// ValidateTransition checks that an alert may move from one state to
+// another. Snoozing a snoozed alert again moves its deadline, and resolved
+// alerts can only be reopened.
+func ValidateTransition(from, to string) error {
+	switch to {
+	case AlertOpen, AlertAcknowledged, AlertSnoozed, AlertResolved:
+	default:
+		return fmt.Errorf("unknown alert state %q", to)
+	}
+
+	if from == to && to != AlertSnoozed {
+		return fmt.Errorf("alert is already %s", to)
+	}
+	if from == AlertResolved && to != AlertOpen {
+		return fmt.Errorf("resolved alerts can only be reopened")
+	}
+	return nil
+}
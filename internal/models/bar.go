@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Bar intervals.
+const (
+	Bar1Min  = "1m"
+	Bar5Min  = "5m"
+	Bar15Min = "15m"
+	Bar1Hour = "1h"
+	BarDaily = "1d"
+)
+
+// IntradayIntervals are the bar intervals shorter than a day.
+var IntradayIntervals = []string{Bar1Min, Bar5Min, Bar15Min, Bar1Hour}
+
+var barDurations = map[string]time.Duration{
+	Bar1Min:  time.Minute,
+	Bar5Min:  5 * time.Minute,
+	Bar15Min: 15 * time.Minute,
+	Bar1Hour: time.Hour,
+	BarDaily: 24 * time.Hour,
+}
+
+// BarDuration returns the length of a bar interval, and false for an
+// unknown interval.
+func BarDuration(interval string) (time.Duration, bool) {
+	d, ok := barDurations[interval]
+	return d, ok
+}
+
+// PriceBar is the open, high, low and close price and the volume of a
+// symbol over one interval.
+type PriceBar struct {
+	StockID  int    `json:"stock_id"`
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	// Timestamp is the start of the bar; daily bars start at midnight UTC
+	// of their trading day.
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    int64     `json:"volume"`
+	Provider  string    `json:"provider,omitempty"`
+}
+
+// TierRaw is the retention tier of the price ticks in stock_prices. The
+// other tiers are rollups named after their bar interval.
+const TierRaw = "raw"
+
+// Candle aggregates the stored price ticks of one time bucket.
+type Candle struct {
+	// Timestamp is the start of the bucket.
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	// Volume is the shares traded during the bucket, from the change in
+	// the cumulative daily volume of its ticks.
+	Volume int64 `json:"volume"`
+	// Count is the number of ticks; zero for gap-filled candles.
+	Count int `json:"count"`
+}
+
+// FillCandleGaps returns candles, which must be in order and aligned to
+// interval, with the buckets missing between them filled in with flat
+// candles at the previous close.
+func FillCandleGaps(candles []*Candle, interval time.Duration) []*Candle {
+	if len(candles) == 0 {
+		return candles
+	}
+
+	filled := make([]*Candle, 0, len(candles))
+	for i, candle := range candles {
+		if i > 0 {
+			prev := candles[i-1]
+			for t := prev.Timestamp.Add(interval); t.Before(candle.Timestamp); t = t.Add(interval) {
+				filled = append(filled, &Candle{Timestamp: t, Open: prev.Close, High: prev.Close, Low: prev.Close, Close: prev.Close})
+			}
+		}
+		filled = append(filled, candle)
+	}
+	return filled
+}
+
+// BackfillProgress is the range of days whose bars of an interval have
+// been backfilled for a symbol.
+type BackfillProgress struct {
+	Symbol    string    `json:"symbol"`
+	Interval  string    `json:"interval"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Covers reports whether the backfilled range includes from through to.
+func (p *BackfillProgress) Covers(from, to time.Time) bool {
+	return !p.From.After(from) && !p.To.Before(to)
+}
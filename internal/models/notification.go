@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification channel types.
+const (
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+	ChannelSlack   = "slack"
+	ChannelTeams   = "teams"
+)
+
+// Delivery statuses.
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// NotificationChannel is somewhere alerts are sent. Config holds the
+// type-specific settings, e.g. the URL of a webhook.
+type NotificationChannel struct {
+	ID     int             `json:"id"`
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+	// Default channels receive alerts that no rule routes, i.e. those
+	// raised by the global threshold.
+	Default   bool      `json:"default"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationDelivery records one attempt to send an alert to a channel.
+type NotificationDelivery struct {
+	ID        int64     `json:"id"`
+	AlertID   int       `json:"alert_id"`
+	ChannelID int       `json:"channel_id"`
+	Attempt   int       `json:"attempt"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
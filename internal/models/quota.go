@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ProviderQuota is the rate limiter state of a quote provider, stored so
+// that every process calling the provider draws on the same budget.
+type ProviderQuota struct {
+	Provider string `json:"provider"`
+	// Tokens is what was left in the per-minute bucket at RefilledAt.
+	Tokens     float64   `json:"tokens"`
+	RefilledAt time.Time `json:"refilled_at"`
+	// Day is the UTC day whose calls UsedToday counts.
+	Day       time.Time `json:"day"`
+	UsedToday int       `json:"used_today"`
+	// Version is incremented by every save, so a limiter can tell that
+	// another process changed the quota since it read it.
+	Version int64 `json:"version"`
+}
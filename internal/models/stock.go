@@ -3,40 +3,63 @@ package models
 import "time"
 
 type Stock struct {
-	ID            int       `json:"id"`
-	Symbol        string    `json:"symbol"`
-	Name          string    `json:"name,omitempty"`
-	CurrentPrice  float64   `json:"current_price"`
-	PreviousPrice float64   `json:"previous_price"`
-	ChangePercent float64   `json:"change_percent"`
-	LastUpdated   time.Time `json:"last_updated"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            int     `json:"id"`
+	Symbol        string  `json:"symbol"`
+	Name          string  `json:"name,omitempty"`
+	Active        bool    `json:"active"`
+	CurrentPrice  float64 `json:"current_price"`
+	PreviousPrice float64 `json:"previous_price"`
+	ChangePercent float64 `json:"change_percent"`
+	// Volume is the number of shares traded so far in the current
+	// trading day.
+	Volume int64 `json:"volume"`
+	// AvgVolume is the average daily volume over the previous trading
+	// days; zero until there is enough history.
+	AvgVolume   float64   `json:"avg_volume,omitempty"`
+	LastUpdated time.Time `json:"last_updated"`
+	Provider    string    `json:"provider,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type StockPrice struct {
-	ID            int64     `json:"id"`
-	StockID       int       `json:"stock_id"`
-	Symbol        string    `json:"symbol"`
-	Price         float64   `json:"price"`
-	ChangePercent float64   `json:"change_percent"`
-	Volume        int64     `json:"volume,omitempty"`
-	Timestamp     time.Time `json:"timestamp"`
+	ID            int64   `json:"id"`
+	StockID       int     `json:"stock_id"`
+	Symbol        string  `json:"symbol"`
+	Price         float64 `json:"price"`
+	ChangePercent float64 `json:"change_percent"`
+	Volume        int64   `json:"volume,omitempty"`
+	Provider      string  `json:"provider,omitempty"`
+	// Session is the market session the price was recorded in, e.g.
+	// "regular" or "after_hours"; empty without a market calendar.
+	Session string `json:"session,omitempty"`
+	// Tier is the rollup interval of a price downsampled by the retention
+	// job, whose Price is the close of that bucket; empty for raw ticks.
+	Tier      string    `json:"tier,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 type Alert struct {
 	ID          int       `json:"id"`
 	StockID     int       `json:"stock_id"`
 	Symbol      string    `json:"symbol"`
+	RuleID      *int      `json:"rule_id,omitempty"`
 	AlertType   string    `json:"alert_type"`
 	Threshold   float64   `json:"threshold"`
 	Message     string    `json:"message"`
+	DedupKey    string    `json:"dedup_key,omitempty"`
 	TriggeredAt time.Time `json:"triggered_at"`
+	// State is where the alert is in its lifecycle; see AlertOpen.
+	State          string     `json:"state"`
+	StateChangedAt *time.Time `json:"state_changed_at,omitempty"`
+	StateChangedBy string     `json:"state_changed_by,omitempty"`
+	SnoozedUntil   *time.Time `json:"snoozed_until,omitempty"`
 }
 
 func NewStock(symbol string) *Stock {
 	return &Stock{
 		Symbol: symbol,
+		Active: true,
 	}
 }
 
@@ -47,6 +70,18 @@ func (s *Stock) UpdatePrice(newPrice, changePercent float64) {
 	s.LastUpdated = time.Now()
 }
 
+// MinVolumeDays is how many trading days of history an average daily
+// volume needs before it is reported.
+const MinVolumeDays = 5
+
+// VolumeRatio is today's volume as a multiple of the average daily volume.
+func (s *Stock) VolumeRatio() (float64, bool) {
+	if s.AvgVolume <= 0 {
+		return 0, false
+	}
+	return float64(s.Volume) / s.AvgVolume, true
+}
+
 func (s *Stock) CalculatePriceChange() float64 {
 	if s.PreviousPrice == 0 {
 		return 0
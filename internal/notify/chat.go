@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"stock-tracker/internal/models"
+)
+
+type ChatConfig struct {
+	// URL is the incoming webhook URL of the Slack or Teams channel.
+	URL string `json:"url"`
+}
+
+// ChatNotifier posts a formatted message to a Slack or Microsoft Teams
+// incoming webhook. Slack-compatible services (Mattermost, Rocket.Chat)
+// work with the slack type.
+type ChatNotifier struct {
+	kind   string
+	cfg    ChatConfig
+	client *http.Client
+}
+
+func NewChat(kind string, cfg ChatConfig) (*ChatNotifier, error) {
+	if err := validateURL(cfg.URL); err != nil {
+		return nil, err
+	}
+	return &ChatNotifier{
+		kind:   kind,
+		cfg:    cfg,
+		client: &http.Client{Timeout: deliveryTimeout},
+	}, nil
+}
+
+func (n *ChatNotifier) Notify(ctx context.Context, alert *models.Alert) error {
+	var payload interface{}
+	if n.kind == models.ChannelTeams {
+		payload = teamsPayload(alert)
+	} else {
+		payload = slackPayload(alert)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return permanent(fmt.Errorf("failed to encode %s message: %w", n.kind, err))
+	}
+
+	return post(ctx, n.client, n.cfg.URL, body, nil)
+}
+
+func slackPayload(alert *models.Alert) map[string]interface{} {
+	return map[string]interface{}{
+		"text": summary(alert),
+		"blocks": []map[string]interface{}{
+			{
+				"type": "section",
+				"text": map[string]string{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf("*%s* `%s`\n%s", alert.Symbol, alert.AlertType, alert.Message),
+				},
+			},
+			{
+				"type": "context",
+				"elements": []map[string]string{
+					{"type": "mrkdwn", "text": "Triggered " + alert.TriggeredAt.Format("2006-01-02 15:04:05 MST")},
+				},
+			},
+		},
+	}
+}
+
+// teamsPayload builds a legacy MessageCard, which Teams incoming webhooks
+// and workflows both accept.
+func teamsPayload(alert *models.Alert) map[string]interface{} {
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    summary(alert),
+		"themeColor": "D93F0B",
+		"title":      fmt.Sprintf("%s: %s", alert.Symbol, alert.AlertType),
+		"text":       alert.Message,
+		"sections": []map[string]interface{}{
+			{
+				"facts": []map[string]string{
+					{"name": "Threshold", "value": fmt.Sprintf("%g", alert.Threshold)},
+					{"name": "Triggered", "value": alert.TriggeredAt.Format("2006-01-02 15:04:05 MST")},
+				},
+			},
+		},
+	}
+}
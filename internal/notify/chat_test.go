@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"stock-tracker/internal/models"
+)
+
+func TestChatPayloads(t *testing.T) {
+	alert := testAlert()
+
+	tests := []struct {
+		kind  string
+		check func(t *testing.T, payload map[string]interface{})
+	}{
+		{models.ChannelSlack, func(t *testing.T, payload map[string]interface{}) {
+			if got := payload["text"]; got != summary(alert) {
+				t.Errorf("text = %v, want %q", got, summary(alert))
+			}
+			blocks, _ := payload["blocks"].([]interface{})
+			if len(blocks) != 2 {
+				t.Fatalf("blocks = %v, want a section and a context block", payload["blocks"])
+			}
+			section := blocks[0].(map[string]interface{})
+			text, _ := section["text"].(map[string]interface{})
+			if section["type"] != "section" || text["type"] != "mrkdwn" ||
+				text["text"] != "*AAPL* `price_above`\n"+alert.Message {
+				t.Errorf("section block = %v", section)
+			}
+			footer := blocks[1].(map[string]interface{})
+			elements, _ := footer["elements"].([]interface{})
+			if footer["type"] != "context" || len(elements) != 1 ||
+				elements[0].(map[string]interface{})["text"] != "Triggered 2026-03-02 15:30:00 UTC" {
+				t.Errorf("context block = %v", footer)
+			}
+		}},
+		{models.ChannelTeams, func(t *testing.T, payload map[string]interface{}) {
+			want := map[string]interface{}{
+				"@type":    "MessageCard",
+				"@context": "https://schema.org/extensions",
+				"summary":  summary(alert),
+				"title":    "AAPL: price_above",
+				"text":     alert.Message,
+			}
+			for key, value := range want {
+				if payload[key] != value {
+					t.Errorf("%s = %v, want %v", key, payload[key], value)
+				}
+			}
+			sections, _ := payload["sections"].([]interface{})
+			if len(sections) != 1 {
+				t.Fatalf("sections = %v, want one", payload["sections"])
+			}
+			facts, _ := sections[0].(map[string]interface{})["facts"].([]interface{})
+			if len(facts) != 2 {
+				t.Fatalf("facts = %v, want threshold and trigger time", facts)
+			}
+			threshold := facts[0].(map[string]interface{})
+			if threshold["name"] != "Threshold" || threshold["value"] != "200" {
+				t.Errorf("first fact = %v, want Threshold 200", threshold)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			server, received := recorder(t)
+			notifier, err := NewChat(tt.kind, ChatConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("NewChat: %v", err)
+			}
+
+			if err := notifier.Notify(context.Background(), alert); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			req := <-received
+
+			if got := req.header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			var payload map[string]interface{}
+			if err := json.Unmarshal(req.body, &payload); err != nil {
+				t.Fatalf("payload is not JSON: %v", err)
+			}
+			tt.check(t, payload)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"context"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"sync"
+	"time"
+)
+
+// maxBackoff caps the delay between two delivery attempts.
+const maxBackoff = 5 * time.Minute
+
+// RetryPolicy controls redelivery of failed notifications. The delay
+// doubles after every failed attempt, starting at Backoff.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff << (attempt - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// Dispatcher sends alerts to the channels they are routed to: the rule's
+// channels, or the default channels for alerts not raised by a rule. Every
+// attempt is recorded in the delivery log.
+type Dispatcher struct {
+	repo    repository.StockRepository
+	metrics *metrics.Metrics
+	policy  RetryPolicy
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewDispatcher(repo repository.StockRepository, m *metrics.Metrics, policy RetryPolicy) *Dispatcher {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		repo:    repo,
+		metrics: m,
+		policy:  policy,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Dispatch starts delivering an alert to each of its channels and returns
+// without waiting for them.
+func (d *Dispatcher) Dispatch(alert *models.Alert) {
+	var channels []*models.NotificationChannel
+	var err error
+	if alert.RuleID != nil {
+		channels, err = d.repo.GetRuleChannels(d.ctx, *alert.RuleID)
+	} else {
+		channels, err = d.repo.GetDefaultChannels(d.ctx)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("symbol", alert.Symbol).Msg("Failed to load notification channels")
+		return
+	}
+
+	for _, channel := range channels {
+		if !channel.Enabled {
+			continue
+		}
+
+		notifier, err := New(channel)
+		if err != nil {
+			logger.Error().Err(err).Int("channel_id", channel.ID).Msg("Invalid notification channel")
+			continue
+		}
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(channel, notifier, alert)
+		}()
+	}
+}
+
+// deliver retries until the notifier succeeds, fails permanently, runs
+// out of attempts or the dispatcher is closed.
+func (d *Dispatcher) deliver(channel *models.NotificationChannel, notifier Notifier, alert *models.Alert) {
+	log := logger.Log.With().Str("symbol", alert.Symbol).Int("alert_id", alert.ID).Str("channel", channel.Name).Logger()
+
+	for attempt := 1; ; attempt++ {
+		err := notifier.Notify(d.ctx, alert)
+		d.record(channel, alert, attempt, err)
+
+		if err == nil {
+			d.metrics.NotificationsSent.WithLabelValues(channel.Type, models.DeliveryDelivered).Inc()
+			log.Info().Int("attempt", attempt).Msg("Alert notification delivered")
+			return
+		}
+
+		if IsPermanent(err) || attempt >= d.policy.MaxAttempts || d.ctx.Err() != nil {
+			d.metrics.NotificationsSent.WithLabelValues(channel.Type, models.DeliveryFailed).Inc()
+			log.Error().Err(err).Int("attempt", attempt).Msg("Alert notification failed")
+			return
+		}
+
+		delay := d.policy.delay(attempt)
+		log.Warn().Err(err).Int("attempt", attempt).Dur("retry_in", delay).Msg("Alert notification failed, retrying")
+		d.metrics.NotificationRetries.WithLabelValues(channel.Type).Inc()
+
+		select {
+		case <-time.After(delay):
+		case <-d.ctx.Done():
+			d.metrics.NotificationsSent.WithLabelValues(channel.Type, models.DeliveryFailed).Inc()
+			log.Warn().Int("attempt", attempt).Msg("Shutting down, giving up on alert notification")
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) record(channel *models.NotificationChannel, alert *models.Alert, attempt int, err error) {
+	// An alert that failed to save has no row to log the delivery against
+	if alert.ID == 0 {
+		logger.Warn().Err(err).Int("channel_id", channel.ID).Int("attempt", attempt).Str("symbol", alert.Symbol).Msg("Not recording delivery of unsaved alert")
+		return
+	}
+
+	delivery := &models.NotificationDelivery{
+		AlertID:   alert.ID,
+		ChannelID: channel.ID,
+		Attempt:   attempt,
+		Status:    models.DeliveryDelivered,
+	}
+	if err != nil {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = err.Error()
+	}
+
+	// The delivery log outlives shutdown of the dispatcher's own context.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := d.repo.SaveDelivery(ctx, delivery); err != nil {
+		logger.Error().Err(err).Int("channel_id", channel.ID).Msg("Failed to record notification delivery")
+	}
+}
+
+// Close abandons pending retries and waits for in-flight deliveries.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
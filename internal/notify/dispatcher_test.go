@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+)
+
+// testMetrics is shared because metrics register with the default registry.
+var testMetrics = metrics.New()
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, Backoff: 5 * time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{6, 160 * time.Second},
+		{7, maxBackoff},
+		{40, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.attempt); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+// flakyServer answers with statuses in turn, then 200, and records when
+// each request arrived.
+type flakyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	arrivals []time.Time
+}
+
+func newFlakyServer(t *testing.T, statuses ...int) *flakyServer {
+	t.Helper()
+	s := &flakyServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.arrivals = append(s.arrivals, time.Now())
+		if len(s.statuses) > 0 {
+			w.WriteHeader(s.statuses[0])
+			s.statuses = s.statuses[1:]
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// dispatch sends a saved alert to a default webhook channel pointing at
+// url and waits for every attempt to finish.
+func dispatch(t *testing.T, url string, policy RetryPolicy) []*models.NotificationDelivery {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+
+	stock := models.NewStock("AAPL")
+	if err := repo.CreateStock(ctx, stock); err != nil {
+		t.Fatalf("CreateStock: %v", err)
+	}
+	alert := testAlert()
+	alert.ID, alert.StockID = 0, stock.ID
+	if err := repo.SaveAlert(ctx, alert); err != nil {
+		t.Fatalf("SaveAlert: %v", err)
+	}
+
+	config, _ := json.Marshal(WebhookConfig{URL: url})
+	channel := &models.NotificationChannel{Name: "hook", Type: models.ChannelWebhook, Config: config, Default: true, Enabled: true}
+	if err := repo.CreateNotificationChannel(ctx, channel); err != nil {
+		t.Fatalf("CreateNotificationChannel: %v", err)
+	}
+
+	d := NewDispatcher(repo, testMetrics, policy)
+	d.Dispatch(alert)
+	d.wg.Wait()
+	d.Close()
+
+	deliveries, err := repo.GetDeliveries(ctx, alert.ID)
+	if err != nil {
+		t.Fatalf("GetDeliveries: %v", err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.ChannelID != channel.ID {
+			t.Errorf("delivery %d is for channel %d, want %d", delivery.Attempt, delivery.ChannelID, channel.ID)
+		}
+	}
+	return deliveries
+}
+
+func checkDeliveries(t *testing.T, deliveries []*models.NotificationDelivery, want []string) {
+	t.Helper()
+	if len(deliveries) != len(want) {
+		t.Fatalf("got %d deliveries, want %d", len(deliveries), len(want))
+	}
+	for i, delivery := range deliveries {
+		if delivery.Attempt != i+1 || delivery.Status != want[i] {
+			t.Errorf("delivery %d = attempt %d %s, want attempt %d %s", i, delivery.Attempt, delivery.Status, i+1, want[i])
+		}
+		if failed := delivery.Status == models.DeliveryFailed; failed != (delivery.Error != "") {
+			t.Errorf("delivery %d is %s with error %q", i, delivery.Status, delivery.Error)
+		}
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	server := newFlakyServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	backoff := 20 * time.Millisecond
+
+	deliveries := dispatch(t, server.URL, RetryPolicy{MaxAttempts: 5, Backoff: backoff})
+	checkDeliveries(t, deliveries, []string{models.DeliveryFailed, models.DeliveryFailed, models.DeliveryDelivered})
+	if deliveries[0].Error != "unexpected status 503" {
+		t.Errorf("first delivery error = %q, want the status", deliveries[0].Error)
+	}
+
+	server.mu.Lock()
+	arrivals := server.arrivals
+	server.mu.Unlock()
+	if len(arrivals) != 3 {
+		t.Fatalf("server got %d requests, want 3", len(arrivals))
+	}
+	// The delay doubles after every failure
+	if gap := arrivals[1].Sub(arrivals[0]); gap < backoff {
+		t.Errorf("first retry after %v, want at least %v", gap, backoff)
+	}
+	if gap := arrivals[2].Sub(arrivals[1]); gap < 2*backoff {
+		t.Errorf("second retry after %v, want at least %v", gap, 2*backoff)
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	t.Run("permanent failure", func(t *testing.T) {
+		server := newFlakyServer(t, http.StatusBadRequest)
+		deliveries := dispatch(t, server.URL, RetryPolicy{MaxAttempts: 5, Backoff: time.Millisecond})
+		checkDeliveries(t, deliveries, []string{models.DeliveryFailed})
+	})
+
+	t.Run("out of attempts", func(t *testing.T) {
+		server := newFlakyServer(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+		deliveries := dispatch(t, server.URL, RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+		checkDeliveries(t, deliveries, []string{models.DeliveryFailed, models.DeliveryFailed, models.DeliveryFailed})
+	})
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"stock-tracker/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type EmailConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// EmailNotifier sends a plain-text email over SMTP, upgrading to TLS when
+// the server offers STARTTLS.
+type EmailNotifier struct {
+	cfg EmailConfig
+}
+
+func NewEmail(cfg EmailConfig) (*EmailNotifier, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("from is required")
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required")
+	}
+	return &EmailNotifier{cfg: cfg}, nil
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, alert *models.Alert) error {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if n.cfg.Username != "" {
+		// PlainAuth refuses to send credentials without TLS, except to localhost.
+		auth := smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return permanent(fmt.Errorf("SMTP authentication failed: %w", err))
+		}
+	}
+
+	if err := client.Mail(n.cfg.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %w", err)
+	}
+	for _, to := range n.cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s failed: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(n.message(alert)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+func (n *EmailNotifier) message(alert *models.Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: [stock-tracker] %s %s\r\n", alert.Symbol, alert.AlertType)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", alert.Message)
+	fmt.Fprintf(&b, "Symbol:    %s\r\n", alert.Symbol)
+	fmt.Fprintf(&b, "Type:      %s\r\n", alert.AlertType)
+	fmt.Fprintf(&b, "Threshold: %g\r\n", alert.Threshold)
+	fmt.Fprintf(&b, "Triggered: %s\r\n", alert.TriggeredAt.Format(time.RFC1123Z))
+	return []byte(b.String())
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer is a stand-in SMTP server that accepts every message and
+// records the commands and message it received.
+type smtpServer struct {
+	listener net.Listener
+	// rejectRcpt, if set, is answered with 550 instead of accepted.
+	rejectRcpt string
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPServer(t *testing.T, rejectRcpt string) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &smtpServer{listener: listener, rejectRcpt: rejectRcpt, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost test SMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			tp.PrintfLine("250 localhost")
+		case verb == "MAIL":
+			tp.PrintfLine("250 OK")
+		case verb == "RCPT" && s.rejectRcpt != "" && strings.Contains(line, s.rejectRcpt):
+			tp.PrintfLine("550 No such user")
+		case verb == "RCPT":
+			tp.PrintfLine("250 OK")
+		case verb == "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = strings.Join(lines, "\n")
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK: queued")
+		case verb == "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// received waits for the session to end and returns what was sent.
+func (s *smtpServer) received() ([]string, string) {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands, s.data
+}
+
+func TestEmailSendsMessage(t *testing.T) {
+	server := newSMTPServer(t, "")
+	notifier, err := NewEmail(EmailConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "alerts@example.com",
+		To:   []string{"ops@example.com", "trader@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("NewEmail: %v", err)
+	}
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	commands, data := server.received()
+
+	var sequence []string
+	for _, command := range commands {
+		if !strings.HasPrefix(command, "EHLO") {
+			sequence = append(sequence, command)
+		}
+	}
+	want := []string{
+		"MAIL FROM:<alerts@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<trader@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	if strings.Join(sequence, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %q, want %q", sequence, want)
+	}
+
+	for _, line := range []string{
+		"From: alerts@example.com",
+		"To: ops@example.com, trader@example.com",
+		"Subject: [stock-tracker] AAPL price_above",
+		"Content-Type: text/plain; charset=UTF-8",
+		"AAPL is at $201.50, at or above $200.00",
+		"Threshold: 200",
+	} {
+		if !strings.Contains(data, line) {
+			t.Errorf("message lacks %q:\n%s", line, data)
+		}
+	}
+}
+
+func TestEmailRejectedRecipient(t *testing.T) {
+	server := newSMTPServer(t, "nobody@example.com")
+	notifier, err := NewEmail(EmailConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "alerts@example.com",
+		To:   []string{"nobody@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("NewEmail: %v", err)
+	}
+
+	err = notifier.Notify(context.Background(), testAlert())
+	if err == nil || !strings.Contains(err.Error(), "RCPT TO nobody@example.com") {
+		t.Errorf("Notify = %v, want the RCPT TO failure", err)
+	}
+}
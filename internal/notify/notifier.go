@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"stock-tracker/internal/models"
+	"time"
+)
+
+// deliveryTimeout bounds a single delivery attempt.
+const deliveryTimeout = 10 * time.Second
+
+// Notifier sends an alert to one destination.
+type Notifier interface {
+	Notify(ctx context.Context, alert *models.Alert) error
+}
+
+// permanentError marks a failure that retrying won't fix, such as a
+// rejected request.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether a delivery error should not be retried.
+func IsPermanent(err error) bool {
+	var perm *permanentError
+	return errors.As(err, &perm)
+}
+
+// New builds the notifier for a channel, validating its config.
+func New(channel *models.NotificationChannel) (Notifier, error) {
+	switch channel.Type {
+	case models.ChannelWebhook:
+		var cfg WebhookConfig
+		if err := decodeConfig(channel, &cfg); err != nil {
+			return nil, err
+		}
+		return NewWebhook(cfg)
+
+	case models.ChannelSlack, models.ChannelTeams:
+		var cfg ChatConfig
+		if err := decodeConfig(channel, &cfg); err != nil {
+			return nil, err
+		}
+		return NewChat(channel.Type, cfg)
+
+	case models.ChannelEmail:
+		var cfg EmailConfig
+		if err := decodeConfig(channel, &cfg); err != nil {
+			return nil, err
+		}
+		return NewEmail(cfg)
+
+	case "":
+		return nil, fmt.Errorf("channel type is required")
+
+	default:
+		return nil, fmt.Errorf("unknown channel type %q", channel.Type)
+	}
+}
+
+func decodeConfig(channel *models.NotificationChannel, cfg interface{}) error {
+	if len(channel.Config) == 0 {
+		return fmt.Errorf("config is required for %s channels", channel.Type)
+	}
+	if err := json.Unmarshal(channel.Config, cfg); err != nil {
+		return fmt.Errorf("invalid %s config: %w", channel.Type, err)
+	}
+	return nil
+}
+
+// post sends a JSON body and treats any non-2xx answer as a failure; 4xx
+// answers other than 408 and 429 are permanent.
+func post(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanent(err)
+	}
+	return err
+}
+
+// summary is the one-line text used by chat and email notifications.
+func summary(alert *models.Alert) string {
+	return fmt.Sprintf("[%s] %s: %s", alert.Symbol, alert.AlertType, alert.Message)
+}
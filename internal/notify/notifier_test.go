@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stock-tracker/internal/models"
+)
+
+func testAlert() *models.Alert {
+	return &models.Alert{
+		ID:          7,
+		Symbol:      "AAPL",
+		AlertType:   models.RulePriceAbove,
+		Threshold:   200,
+		Message:     "AAPL is at $201.50, at or above $200.00",
+		TriggeredAt: time.Date(2026, 3, 2, 15, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestPostStatuses(t *testing.T) {
+	tests := []struct {
+		status        int
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusNoContent, false, false},
+		{http.StatusBadRequest, true, true},
+		{http.StatusUnauthorized, true, true},
+		{http.StatusNotFound, true, true},
+		{http.StatusGone, true, true},
+		{http.StatusRequestTimeout, true, false},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusInternalServerError, true, false},
+		{http.StatusBadGateway, true, false},
+		{http.StatusServiceUnavailable, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			notifier, err := NewWebhook(WebhookConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("NewWebhook: %v", err)
+			}
+
+			err = notifier.Notify(context.Background(), testAlert())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify = %v, want error %v", err, tt.wantErr)
+			}
+			if IsPermanent(err) != tt.wantPermanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, IsPermanent(err), tt.wantPermanent)
+			}
+		})
+	}
+}
+
+func TestPostUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	notifier, err := NewWebhook(WebhookConfig{URL: url})
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+	if err := notifier.Notify(context.Background(), testAlert()); err == nil || IsPermanent(err) {
+		t.Errorf("Notify to a closed server = %v, want a retryable error", err)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel models.NotificationChannel
+		wantErr bool
+	}{
+		{"webhook", models.NotificationChannel{Type: models.ChannelWebhook, Config: []byte(`{"url":"https://example.com/hook"}`)}, false},
+		{"webhook without url", models.NotificationChannel{Type: models.ChannelWebhook, Config: []byte(`{}`)}, true},
+		{"webhook with relative url", models.NotificationChannel{Type: models.ChannelWebhook, Config: []byte(`{"url":"/hook"}`)}, true},
+		{"slack", models.NotificationChannel{Type: models.ChannelSlack, Config: []byte(`{"url":"https://hooks.slack.com/services/x"}`)}, false},
+		{"teams with ftp url", models.NotificationChannel{Type: models.ChannelTeams, Config: []byte(`{"url":"ftp://example.com"}`)}, true},
+		{"email", models.NotificationChannel{Type: models.ChannelEmail, Config: []byte(`{"host":"smtp.example.com","from":"a@example.com","to":["b@example.com"]}`)}, false},
+		{"email without recipients", models.NotificationChannel{Type: models.ChannelEmail, Config: []byte(`{"host":"smtp.example.com","from":"a@example.com"}`)}, true},
+		{"no config", models.NotificationChannel{Type: models.ChannelWebhook}, true},
+		{"invalid config", models.NotificationChannel{Type: models.ChannelWebhook, Config: []byte(`{"url":`)}, true},
+		{"no type", models.NotificationChannel{Config: []byte(`{}`)}, true},
+		{"unknown type", models.NotificationChannel{Type: "pager", Config: []byte(`{}`)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&tt.channel)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
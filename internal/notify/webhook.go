@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"stock-tracker/internal/models"
+	"strconv"
+	"time"
+)
+
+// Headers set on webhook requests. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the channel secret, so receivers can
+// verify the sender and reject replays.
+const (
+	HeaderTimestamp = "X-Stock-Tracker-Timestamp"
+	HeaderSignature = "X-Stock-Tracker-Signature"
+)
+
+type WebhookConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookNotifier posts the alert as JSON to a URL.
+type WebhookNotifier struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+func NewWebhook(cfg WebhookConfig) (*WebhookNotifier, error) {
+	if err := validateURL(cfg.URL); err != nil {
+		return nil, err
+	}
+	return &WebhookNotifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: deliveryTimeout},
+	}, nil
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert *models.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return permanent(fmt.Errorf("failed to encode alert: %w", err))
+	}
+
+	header := http.Header{}
+	if n.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		header.Set(HeaderTimestamp, timestamp)
+		header.Set(HeaderSignature, "sha256="+Sign(n.cfg.Secret, timestamp, body))
+	}
+
+	return post(ctx, n.client, n.cfg.URL, body, header)
+}
+
+// Sign computes the webhook signature of a body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL, got %q", raw)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"stock-tracker/internal/models"
+)
+
+// request is what a stand-in server received.
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// recorder returns a server that answers 200 and sends what it receives
+// to the returned channel.
+func recorder(t *testing.T) (*httptest.Server, <-chan request) {
+	t.Helper()
+	received := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{header: r.Header.Clone(), body: body}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestWebhookSignsBody(t *testing.T) {
+	server, received := recorder(t)
+	notifier, err := NewWebhook(WebhookConfig{URL: server.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+
+	alert := testAlert()
+	if err := notifier.Notify(context.Background(), alert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	req := <-received
+
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	var sent models.Alert
+	if err := json.Unmarshal(req.body, &sent); err != nil {
+		t.Fatalf("body is not an alert: %v", err)
+	}
+	if sent.ID != alert.ID || sent.Symbol != alert.Symbol || sent.Message != alert.Message {
+		t.Errorf("sent alert = %+v, want %+v", sent, *alert)
+	}
+
+	timestamp := req.header.Get(HeaderTimestamp)
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sentAt, 0)).Abs() > time.Minute {
+		t.Errorf("%s = %q, want the current Unix time", HeaderTimestamp, timestamp)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "." + string(req.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get(HeaderSignature); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+}
+
+func TestWebhookWithoutSecretIsUnsigned(t *testing.T) {
+	server, received := recorder(t)
+	notifier, err := NewWebhook(WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	req := <-received
+
+	for _, header := range []string{HeaderTimestamp, HeaderSignature} {
+		if got := req.header.Get(header); got != "" {
+			t.Errorf("%s = %q, want none", header, got)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac key
+	const want = "a438e398bfafc57e4396bb7fc2304422f0f768e965d073ca313cb52e22e6ad03"
+
+	if got := Sign("key", "1700000000", []byte(`{"a":1}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
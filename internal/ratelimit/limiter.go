@@ -0,0 +1,259 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQuotaExhausted is matched by every QuotaExhaustedError.
+var ErrQuotaExhausted = errors.New("daily quota exhausted")
+
+// QuotaExhaustedError reports that no more calls are allowed until the
+// daily quota resets.
+type QuotaExhaustedError struct {
+	Provider string
+	Until    time.Time
+}
+
+func (e *QuotaExhaustedError) Error() string {
+	return fmt.Sprintf("%s daily quota exhausted until %s", e.Provider, e.Until.Format(time.RFC3339))
+}
+
+func (e *QuotaExhaustedError) Unwrap() error {
+	return ErrQuotaExhausted
+}
+
+// Store keeps the limiter state of each provider where every process
+// calling the provider can see it. repository.StockRepository is one.
+type Store interface {
+	GetProviderQuota(ctx context.Context, provider string) (*models.ProviderQuota, error)
+	SaveProviderQuota(ctx context.Context, quota *models.ProviderQuota) error
+}
+
+// maxConflicts bounds how many times in a row a reservation is retried
+// because another process saved the quota first.
+const maxConflicts = 10
+
+// Limiter is a token bucket refilled at perMinute tokens per minute with a
+// burst of perMinute, combined with a hard cap of perDay calls per UTC day.
+// A zero limit disables that half of the check. The bucket and the day's
+// count live in the Store, so the tracker, the API server and the backfill
+// command share one budget per provider.
+type Limiter struct {
+	provider  string
+	perMinute int
+	perDay    int
+	store     Store
+	metrics   *metrics.Metrics
+
+	// mu serializes the process's reservations, so only other processes
+	// make a save conflict.
+	mu sync.Mutex
+	// last is the quota as this limiter last read or saved it.
+	last atomic.Pointer[models.ProviderQuota]
+}
+
+func New(provider string, perMinute, perDay int, store Store, m *metrics.Metrics) *Limiter {
+	l := &Limiter{
+		provider:  provider,
+		perMinute: perMinute,
+		perDay:    perDay,
+		store:     store,
+		metrics:   m,
+	}
+	l.remember(l.fresh(time.Now()))
+	return l
+}
+
+// Wait blocks until a call is allowed, the context is done, or the daily
+// quota is exhausted, in which case it returns a *QuotaExhaustedError
+// without waiting.
+func (l *Limiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		l.metrics.RateLimitWait.WithLabelValues(l.provider).Observe(time.Since(start).Seconds())
+	}()
+
+	for {
+		delay, err := l.reserve(ctx, time.Now())
+		if err != nil || delay == 0 {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available and otherwise returns how long
+// until the next one is.
+func (l *Limiter) reserve(ctx context.Context, now time.Time) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for conflicts := 0; ; conflicts++ {
+		quota, err := l.load(ctx, now)
+		if err != nil {
+			return 0, err
+		}
+
+		if l.perDay > 0 && quota.UsedToday >= l.perDay {
+			return 0, &QuotaExhaustedError{Provider: l.provider, Until: quota.Day.AddDate(0, 0, 1)}
+		}
+		if l.perMinute > 0 {
+			if quota.Tokens < 1 {
+				perToken := time.Minute / time.Duration(l.perMinute)
+				return time.Duration((1 - quota.Tokens) * float64(perToken)), nil
+			}
+			quota.Tokens--
+		}
+		quota.UsedToday++
+
+		err = l.store.SaveProviderQuota(ctx, quota)
+		if errors.Is(err, repository.ErrProviderQuotaChanged) && conflicts < maxConflicts {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to reserve %s call: %w", l.provider, err)
+		}
+
+		l.remember(quota)
+		if l.perDay > 0 && quota.UsedToday == l.perDay {
+			logger.Warn().
+				Str("provider", l.provider).
+				Int("requests_per_day", l.perDay).
+				Time("until", quota.Day.AddDate(0, 0, 1)).
+				Msg("Daily API quota exhausted")
+		}
+		return 0, nil
+	}
+}
+
+// Drain empties the bucket after the provider itself rejected a call for
+// exceeding its limit, so the next caller backs off for a full token period.
+func (l *Limiter) Drain(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for conflicts := 0; ; conflicts++ {
+		quota, err := l.load(ctx, time.Now())
+		if err == nil {
+			quota.Tokens = 0
+			err = l.store.SaveProviderQuota(ctx, quota)
+		}
+		if errors.Is(err, repository.ErrProviderQuotaChanged) && conflicts < maxConflicts {
+			continue
+		}
+		if err != nil {
+			logger.Warn().Err(err).Str("provider", l.provider).Msg("Failed to drain rate limiter")
+			return
+		}
+
+		l.remember(quota)
+		return
+	}
+}
+
+// ExhaustedUntil reports whether the daily quota is used up and when it
+// resets, as of the limiter's last call, so it doesn't query the Store.
+func (l *Limiter) ExhaustedUntil() (time.Time, bool) {
+	quota := l.last.Load()
+	if startOfDay(time.Now()).After(quota.Day) {
+		return time.Time{}, false
+	}
+	if l.perDay > 0 && quota.UsedToday >= l.perDay {
+		return quota.Day.AddDate(0, 0, 1), true
+	}
+	return time.Time{}, false
+}
+
+// load reads the stored quota, or a full one if none is stored, refilled
+// and rolled over to now.
+func (l *Limiter) load(ctx context.Context, now time.Time) (*models.ProviderQuota, error) {
+	quota, err := l.store.GetProviderQuota(ctx, l.provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s quota: %w", l.provider, err)
+	}
+	if quota == nil {
+		quota = l.fresh(now)
+	}
+
+	l.refill(quota, now)
+	l.rollDay(quota, now)
+	l.remember(quota)
+	return quota, nil
+}
+
+func (l *Limiter) fresh(now time.Time) *models.ProviderQuota {
+	return &models.ProviderQuota{
+		Provider:   l.provider,
+		Tokens:     float64(l.perMinute),
+		RefilledAt: now,
+		Day:        startOfDay(now),
+	}
+}
+
+func (l *Limiter) refill(quota *models.ProviderQuota, now time.Time) {
+	// Another process's clock may be a little ahead
+	elapsed := now.Sub(quota.RefilledAt)
+	if elapsed < 0 {
+		return
+	}
+	quota.RefilledAt = now
+	quota.Tokens += elapsed.Minutes() * float64(l.perMinute)
+	if quota.Tokens > float64(l.perMinute) {
+		quota.Tokens = float64(l.perMinute)
+	}
+}
+
+func (l *Limiter) rollDay(quota *models.ProviderQuota, now time.Time) {
+	if day := startOfDay(now); day.After(quota.Day) {
+		if l.perDay > 0 && quota.UsedToday >= l.perDay {
+			logger.Info().Str("provider", l.provider).Msg("Daily API quota reset")
+		}
+		quota.Day = day
+		quota.UsedToday = 0
+	}
+}
+
+// remember keeps a copy of quota for ExhaustedUntil and reports it.
+func (l *Limiter) remember(quota *models.ProviderQuota) {
+	last := *quota
+	l.last.Store(&last)
+	l.reportQuota(&last)
+}
+
+func (l *Limiter) reportQuota(quota *models.ProviderQuota) {
+	l.metrics.QuotaUsed.WithLabelValues(l.provider).Set(float64(quota.UsedToday))
+	l.metrics.QuotaResetTime.WithLabelValues(l.provider).Set(float64(quota.Day.AddDate(0, 0, 1).Unix()))
+
+	if l.perDay == 0 {
+		return
+	}
+
+	remaining := l.perDay - quota.UsedToday
+	l.metrics.QuotaRemaining.WithLabelValues(l.provider).Set(float64(remaining))
+
+	exhausted := 0.0
+	if remaining <= 0 {
+		exhausted = 1
+	}
+	l.metrics.QuotaExhausted.WithLabelValues(l.provider).Set(exhausted)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
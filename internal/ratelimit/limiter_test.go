@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/repository"
+)
+
+// testMetrics is shared because metrics register with the default registry.
+var testMetrics = metrics.New()
+
+func TestLimitersShareQuota(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+
+	// As if the tracker and the API server each had a limiter
+	tracker := New("test", 60, 3, repo, testMetrics)
+	api := New("test", 60, 3, repo, testMetrics)
+
+	for i, l := range []*Limiter{tracker, api, tracker} {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatalf("call %d: Wait = %v", i+1, err)
+		}
+	}
+
+	err := api.Wait(ctx)
+	var exhausted *QuotaExhaustedError
+	if !errors.As(err, &exhausted) || !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("fourth call: Wait = %v, want a QuotaExhaustedError", err)
+	}
+	if until := startOfDay(time.Now()).AddDate(0, 0, 1); !exhausted.Until.Equal(until) {
+		t.Errorf("exhausted until %v, want %v", exhausted.Until, until)
+	}
+
+	// The tracker learns of the API server's last call from the store
+	if err := tracker.Wait(ctx); !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("Wait after exhaustion = %v, want ErrQuotaExhausted", err)
+	}
+	for name, l := range map[string]*Limiter{"tracker": tracker, "api": api} {
+		if _, ok := l.ExhaustedUntil(); !ok {
+			t.Errorf("%s limiter does not report the quota exhausted", name)
+		}
+	}
+}
+
+func TestLimiterSharesBucket(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	a := New("test", 2, 0, repo, testMetrics)
+	b := New("test", 2, 0, repo, testMetrics)
+
+	now := time.Now()
+	for i, l := range []*Limiter{a, b} {
+		if delay, err := l.reserve(context.Background(), now); delay != 0 || err != nil {
+			t.Fatalf("call %d: reserve = %v, %v; want a token", i+1, delay, err)
+		}
+	}
+
+	// Both tokens of the burst are gone, and one refills every 30 seconds
+	delay, err := a.reserve(context.Background(), now)
+	if err != nil || delay <= 0 || delay > 30*time.Second {
+		t.Errorf("third call: reserve = %v, %v; want a wait of up to 30s", delay, err)
+	}
+	if delay, err := b.reserve(context.Background(), now.Add(30*time.Second)); delay != 0 || err != nil {
+		t.Errorf("reserve after 30s = %v, %v; want a token", delay, err)
+	}
+}
@@ -2,10 +2,77 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"stock-tracker/internal/models"
 	"time"
 )
 
+// Repository errors wrap one of these, or a *ValidationError, when the
+// caller rather than the database is at fault or a retry may succeed.
+var (
+	// ErrNotFound is returned when the stock, price, alert, rule or
+	// channel asked for doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write collides with stored data or
+	// with a concurrent write.
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable is returned when the database can't be reached or is
+	// too busy to answer.
+	ErrUnavailable = errors.New("database unavailable")
+)
+
+// ValidationError is returned when the database rejects a value, e.g. a
+// negative limit or a reference to a channel that doesn't exist. Its
+// message is fit to show to API clients.
+type ValidationError struct {
+	Message string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// translated reports whether err already carries a repository error, so
+// the database error translators leave it alone.
+func translated(err error) bool {
+	var validation *ValidationError
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrUnavailable) || errors.As(err, &validation)
+}
+
+// ErrDuplicateAlert is returned by SaveAlert when an alert with the same
+// dedup key has already been stored.
+var ErrDuplicateAlert = fmt.Errorf("duplicate alert: %w", ErrConflict)
+
+// ErrAlertStateChanged is returned by UpdateAlertState when another change
+// got there first.
+var ErrAlertStateChanged = fmt.Errorf("alert state changed: %w", ErrConflict)
+
+// ErrProviderQuotaChanged is returned by SaveProviderQuota when the quota
+// was saved by someone else since it was read.
+var ErrProviderQuotaChanged = fmt.Errorf("provider quota changed: %w", ErrConflict)
+
+// AlertFilter narrows ListAlerts; zero fields match everything.
+type AlertFilter struct {
+	Symbol    string
+	State     string
+	AlertType string
+	// From and To bound triggered_at, From inclusive and To exclusive.
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// StockRepository stores stocks, prices, bars, alerts and notification
+// settings. Getting or updating a record that doesn't exist returns an
+// error wrapping ErrNotFound, as do GetPriceHistory, GetCandles, GetBars
+// and GetAlertRules for an unknown symbol. Deleting one is not an error.
 type StockRepository interface {
 	// Stock operations
 	CreateStock(ctx context.Context, stock *models.Stock) error
@@ -15,12 +82,84 @@ type StockRepository interface {
 	DeleteStock(ctx context.Context, symbol string) error
 
 	// Price history operations
+	// Reads cover the retention job's rollups as well as raw prices, so
+	// compacted history is still returned, at the rollups' resolution.
 	SavePrice(ctx context.Context, price *models.StockPrice) error
+	// SavePrices inserts prices in one batch, all or none. A price without
+	// a StockID is saved for the stock of its Symbol. Unlike SavePrice it
+	// doesn't set the prices' IDs.
+	SavePrices(ctx context.Context, prices []*models.StockPrice) error
 	GetPriceHistory(ctx context.Context, symbol string, from, to time.Time, limit int) ([]*models.StockPrice, error)
 	GetLatestPrice(ctx context.Context, symbol string) (*models.StockPrice, error)
+	// GetPriceAt returns the last price recorded at or before at.
+	GetPriceAt(ctx context.Context, symbol string, at time.Time) (*models.StockPrice, error)
+	// GetAverageDailyVolume averages the final volume of up to days trading
+	// days before the given time, and returns how many days it found.
+	GetAverageDailyVolume(ctx context.Context, symbol string, days int, before time.Time) (float64, int, error)
+	// GetCandles aggregates the prices from from to to into buckets of
+	// interval, aligned to midnight UTC, oldest first. Buckets without
+	// prices are left out.
+	GetCandles(ctx context.Context, symbol string, interval time.Duration, from, to time.Time) ([]*models.Candle, error)
+	// CompactPrices deletes the prices of tier recorded before the given
+	// time and, unless into is empty, rolls them up into rollups of that
+	// interval in the same statement. tier is models.TierRaw or a rollup
+	// interval. It returns how many rollups it wrote and prices it deleted.
+	CompactPrices(ctx context.Context, tier, into string, before time.Time) (int64, int64, error)
+
+	// Bar operations
+	// SaveBars inserts bars, replacing stored bars with the same symbol,
+	// interval and start, so saving the same bars again is harmless.
+	SaveBars(ctx context.Context, bars []*models.PriceBar) error
+	// GetBars returns up to limit bars of interval starting between from
+	// and to, newest first, like GetPriceHistory.
+	GetBars(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]*models.PriceBar, error)
+	// GetLatestBar returns nil if no bars of interval are stored.
+	GetLatestBar(ctx context.Context, symbol, interval string) (*models.PriceBar, error)
+	// GetBackfillProgress returns nil if the symbol's bars of interval
+	// were never backfilled.
+	GetBackfillProgress(ctx context.Context, symbol, interval string) (*models.BackfillProgress, error)
+	SaveBackfillProgress(ctx context.Context, progress *models.BackfillProgress) error
+
+	// Provider quota operations
+	// GetProviderQuota returns nil if the provider's quota was never saved.
+	GetProviderQuota(ctx context.Context, provider string) (*models.ProviderQuota, error)
+	// SaveProviderQuota stores the quota if its Version is still the stored
+	// one, zero meaning not stored yet, and increments Version. Otherwise it
+	// returns ErrProviderQuotaChanged.
+	SaveProviderQuota(ctx context.Context, quota *models.ProviderQuota) error
 
 	// Alert operations
+	// SaveAlert returns ErrDuplicateAlert if the alert's dedup key is taken.
 	SaveAlert(ctx context.Context, alert *models.Alert) error
-	GetAlerts(ctx context.Context, symbol string, limit int) ([]*models.Alert, error)
-	GetRecentAlerts(ctx context.Context, limit int) ([]*models.Alert, error)
+	GetAlert(ctx context.Context, id int) (*models.Alert, error)
+	// ListAlerts returns the newest alerts matching filter.
+	ListAlerts(ctx context.Context, filter AlertFilter) ([]*models.Alert, error)
+	// UpdateAlertState applies a state change and records it in the audit
+	// trail. It returns ErrAlertStateChanged if the alert is no longer in
+	// change.FromState.
+	UpdateAlertState(ctx context.Context, change *models.AlertStateChange) (*models.Alert, error)
+	GetAlertStateChanges(ctx context.Context, alertID int) ([]*models.AlertStateChange, error)
+
+	// Alert rule operations
+	CreateAlertRule(ctx context.Context, rule *models.AlertRule) error
+	GetAlertRule(ctx context.Context, id int) (*models.AlertRule, error)
+	GetAlertRules(ctx context.Context, symbol string) ([]*models.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *models.AlertRule) error
+	DeleteAlertRule(ctx context.Context, id int) error
+	SaveAlertRuleState(ctx context.Context, ruleID int, state *models.AlertRuleState) error
+
+	// Notification operations
+	CreateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error
+	GetNotificationChannel(ctx context.Context, id int) (*models.NotificationChannel, error)
+	GetNotificationChannels(ctx context.Context) ([]*models.NotificationChannel, error)
+	UpdateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error
+	DeleteNotificationChannel(ctx context.Context, id int) error
+	// SetRuleChannels replaces the channels a rule's alerts are sent to.
+	SetRuleChannels(ctx context.Context, ruleID int, channelIDs []int) error
+	GetRuleChannels(ctx context.Context, ruleID int) ([]*models.NotificationChannel, error)
+	GetDefaultChannels(ctx context.Context) ([]*models.NotificationChannel, error)
+	SaveDelivery(ctx context.Context, delivery *models.NotificationDelivery) error
+	GetDeliveries(ctx context.Context, alertID int) ([]*models.NotificationDelivery, error)
+
+	Close()
 }
@@ -0,0 +1,1235 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"stock-tracker/internal/models"
+)
+
+// bucketOrigin aligns candles and rollups, like date_bin's origin in SQL.
+var bucketOrigin = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type barKey struct {
+	stockID   int
+	interval  string
+	timestamp time.Time
+}
+
+type progressKey struct {
+	stockID  int
+	interval string
+}
+
+// rollup is a row of price_rollups.
+type rollup struct {
+	interval               string
+	timestamp              time.Time
+	open, high, low, close float64
+	volume                 int64
+	count                  int
+}
+
+// point is a row of the priceSeries union: a raw price, a rollup or a
+// daily bar.
+type point struct {
+	price           models.StockPrice
+	open, high, low float64
+	count           int
+}
+
+// MemoryRepository is a StockRepository that keeps everything in memory,
+// for tests and demos. It follows PostgresRepository's semantics, down to
+// ordering, limits, upserts and cascading deletes, but loses its data when
+// the process exits. It is safe for concurrent use.
+type MemoryRepository struct {
+	mu sync.RWMutex
+
+	// seq holds the last ID handed out per table, like SERIAL columns.
+	seq map[string]int64
+
+	stocks       map[int]*models.Stock
+	symbols      map[string]int
+	prices       map[int][]*models.StockPrice
+	rollups      map[int][]*rollup
+	bars         map[barKey]*models.PriceBar
+	progress     map[progressKey]*models.BackfillProgress
+	quotas       map[string]*models.ProviderQuota
+	alerts       map[int]*models.Alert
+	stateChanges []*models.AlertStateChange
+	rules        map[int]*models.AlertRule
+	ruleStates   map[int]*models.AlertRuleState
+	channels     map[int]*models.NotificationChannel
+	ruleChannels map[int]map[int]bool
+	deliveries   []*models.NotificationDelivery
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		seq:          make(map[string]int64),
+		stocks:       make(map[int]*models.Stock),
+		symbols:      make(map[string]int),
+		prices:       make(map[int][]*models.StockPrice),
+		rollups:      make(map[int][]*rollup),
+		bars:         make(map[barKey]*models.PriceBar),
+		progress:     make(map[progressKey]*models.BackfillProgress),
+		quotas:       make(map[string]*models.ProviderQuota),
+		alerts:       make(map[int]*models.Alert),
+		rules:        make(map[int]*models.AlertRule),
+		ruleStates:   make(map[int]*models.AlertRuleState),
+		channels:     make(map[int]*models.NotificationChannel),
+		ruleChannels: make(map[int]map[int]bool),
+	}
+}
+
+// Close is a no-op.
+func (r *MemoryRepository) Close() {}
+
+func (r *MemoryRepository) next(table string) int64 {
+	r.seq[table]++
+	return r.seq[table]
+}
+
+// dbTime stores t the way a TIMESTAMP column does: the wall clock without
+// its time zone, to the microsecond.
+func dbTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Truncate(time.Microsecond)
+}
+
+func dbTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	stored := dbTime(*t)
+	return &stored
+}
+
+// dbDate stores t the way a DATE column does.
+func dbDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func now() time.Time {
+	return dbTime(time.Now().UTC())
+}
+
+func sameDay(a, b time.Time) bool {
+	return dbDate(a).Equal(dbDate(b))
+}
+
+func bucket(t time.Time, interval time.Duration) time.Time {
+	d := t.Sub(bucketOrigin)
+	offset := d % interval
+	if offset < 0 {
+		offset += interval
+	}
+	return t.Add(-offset)
+}
+
+// limitRows applies a SQL LIMIT.
+// missing is the error of a reference to a row that doesn't exist, which
+// a foreign key rejects in SQL.
+func missing(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+func limitRows[T any](rows []T, limit int) ([]T, error) {
+	if limit < 0 {
+		return nil, &ValidationError{Message: "LIMIT must not be negative"}
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (r *MemoryRepository) stockBySymbol(symbol string) (*models.Stock, bool) {
+	id, ok := r.symbols[symbol]
+	if !ok {
+		return nil, false
+	}
+	return r.stocks[id], true
+}
+
+func (r *MemoryRepository) CreateStock(ctx context.Context, stock *models.Stock) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.stockBySymbol(stock.Symbol); ok {
+		stored.UpdatedAt = now()
+		stock.ID, stock.Active, stock.CreatedAt, stock.UpdatedAt = stored.ID, stored.Active, stored.CreatedAt, stored.UpdatedAt
+		return nil
+	}
+
+	created := now()
+	stored := &models.Stock{
+		ID:        int(r.next("stocks")),
+		Symbol:    stock.Symbol,
+		Name:      stock.Name,
+		Active:    stock.Active,
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+	r.stocks[stored.ID] = stored
+	r.symbols[stored.Symbol] = stored.ID
+
+	stock.ID, stock.CreatedAt, stock.UpdatedAt = stored.ID, stored.CreatedAt, stored.UpdatedAt
+	return nil
+}
+
+func (r *MemoryRepository) GetStock(ctx context.Context, symbol string) (*models.Stock, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.stockBySymbol(symbol)
+	if !ok {
+		return nil, fmt.Errorf("failed to get stock: %w", ErrNotFound)
+	}
+
+	stock := *stored
+	return &stock, nil
+}
+
+func (r *MemoryRepository) GetAllStocks(ctx context.Context) ([]*models.Stock, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var stocks []*models.Stock
+	for _, stored := range r.stocks {
+		stock := *stored
+		if series := r.series(stock.ID); len(series) > 0 {
+			latest := series[len(series)-1].price
+			stock.CurrentPrice = latest.Price
+			stock.ChangePercent = latest.ChangePercent
+			stock.Volume = latest.Volume
+			stock.Provider = latest.Provider
+			stock.LastUpdated = latest.Timestamp
+		}
+		stocks = append(stocks, &stock)
+	}
+	sort.Slice(stocks, func(i, j int) bool { return stocks[i].Symbol < stocks[j].Symbol })
+
+	return stocks, nil
+}
+
+func (r *MemoryRepository) UpdateStock(ctx context.Context, stock *models.Stock) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.stockBySymbol(stock.Symbol)
+	if !ok {
+		return fmt.Errorf("failed to update stock: %w", ErrNotFound)
+	}
+
+	stored.Name, stored.Active, stored.UpdatedAt = stock.Name, stock.Active, now()
+	stock.ID, stock.CreatedAt, stock.UpdatedAt = stored.ID, stored.CreatedAt, stored.UpdatedAt
+	return nil
+}
+
+// DeleteStock removes a stock and, like the ON DELETE CASCADE foreign
+// keys, everything that belongs to it.
+func (r *MemoryRepository) DeleteStock(ctx context.Context, symbol string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stock, ok := r.stockBySymbol(symbol)
+	if !ok {
+		return nil
+	}
+
+	delete(r.stocks, stock.ID)
+	delete(r.symbols, symbol)
+	delete(r.prices, stock.ID)
+	delete(r.rollups, stock.ID)
+	for key := range r.bars {
+		if key.stockID == stock.ID {
+			delete(r.bars, key)
+		}
+	}
+	for key := range r.progress {
+		if key.stockID == stock.ID {
+			delete(r.progress, key)
+		}
+	}
+	for id, alert := range r.alerts {
+		if alert.StockID == stock.ID {
+			r.deleteAlert(id)
+		}
+	}
+	for id, rule := range r.rules {
+		if rule.StockID == stock.ID {
+			r.deleteRule(id)
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) SavePrice(ctx context.Context, price *models.StockPrice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.stocks[price.StockID]; !ok {
+		return fmt.Errorf("failed to save price: %w", missing("stock %d does not exist", price.StockID))
+	}
+
+	price.ID = r.insertPrice(price.StockID, price)
+	return nil
+}
+
+func (r *MemoryRepository) SavePrices(ctx context.Context, prices []*models.StockPrice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check every price first so a bad one saves none
+	stockIDs := make([]int, len(prices))
+	for i, price := range prices {
+		if price.StockID != 0 {
+			if _, ok := r.stocks[price.StockID]; !ok {
+				return fmt.Errorf("failed to save prices: %w", missing("stock %d does not exist", price.StockID))
+			}
+			stockIDs[i] = price.StockID
+			continue
+		}
+		stock, ok := r.stockBySymbol(price.Symbol)
+		if !ok {
+			return fmt.Errorf("failed to save prices: %w", missing("stock %s does not exist", price.Symbol))
+		}
+		stockIDs[i] = stock.ID
+	}
+
+	for i, price := range prices {
+		r.insertPrice(stockIDs[i], price)
+	}
+	return nil
+}
+
+// insertPrice stores a copy of price for the stock and returns its ID.
+func (r *MemoryRepository) insertPrice(stockID int, price *models.StockPrice) int64 {
+	stored := *price
+	stored.ID = r.next("stock_prices")
+	stored.StockID = stockID
+	stored.Symbol, stored.Tier = "", ""
+	stored.Timestamp = dbTime(price.Timestamp)
+
+	prices := append(r.prices[stockID], &stored)
+	if n := len(prices); n > 1 && prices[n-1].Timestamp.Before(prices[n-2].Timestamp) {
+		sort.SliceStable(prices, func(i, j int) bool { return prices[i].Timestamp.Before(prices[j].Timestamp) })
+	}
+	r.prices[stockID] = prices
+
+	return stored.ID
+}
+
+// series returns a stock's raw prices, rollups and daily bars, oldest
+// first, like priceSeries in PostgresRepository.
+func (r *MemoryRepository) series(stockID int) []point {
+	stock := r.stocks[stockID]
+
+	points := make([]point, 0, len(r.prices[stockID])+len(r.rollups[stockID]))
+	for _, p := range r.prices[stockID] {
+		price := *p
+		price.Symbol = stock.Symbol
+		points = append(points, point{price: price, open: p.Price, high: p.Price, low: p.Price, count: 1})
+	}
+	for _, ru := range r.rollups[stockID] {
+		points = append(points, point{
+			price: models.StockPrice{
+				StockID:   stockID,
+				Symbol:    stock.Symbol,
+				Price:     ru.close,
+				Volume:    ru.volume,
+				Tier:      ru.interval,
+				Timestamp: ru.timestamp,
+			},
+			open: ru.open, high: ru.high, low: ru.low, count: ru.count,
+		})
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].price.Timestamp.Before(points[j].price.Timestamp) })
+
+	// Daily bars fill in the days without prices in either tier.
+	var bars []point
+	for key, bar := range r.bars {
+		if key.stockID != stockID || key.interval != models.BarDaily {
+			continue
+		}
+		dayEnd := bar.Timestamp.Add(24 * time.Hour)
+		i := sort.Search(len(points), func(i int) bool { return !points[i].price.Timestamp.Before(bar.Timestamp) })
+		if i < len(points) && points[i].price.Timestamp.Before(dayEnd) {
+			continue
+		}
+		bars = append(bars, point{
+			price: models.StockPrice{
+				StockID:   stockID,
+				Symbol:    stock.Symbol,
+				Price:     bar.Close,
+				Volume:    bar.Volume,
+				Provider:  bar.Provider,
+				Tier:      bar.Interval,
+				Timestamp: bar.Timestamp,
+			},
+			open: bar.Open, high: bar.High, low: bar.Low, count: 1,
+		})
+	}
+	if len(bars) > 0 {
+		points = append(points, bars...)
+		sort.SliceStable(points, func(i, j int) bool { return points[i].price.Timestamp.Before(points[j].price.Timestamp) })
+	}
+	return points
+}
+
+func (r *MemoryRepository) seriesOf(symbol string) []point {
+	stock, ok := r.stockBySymbol(symbol)
+	if !ok {
+		return nil
+	}
+	return r.series(stock.ID)
+}
+
+func (r *MemoryRepository) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time, limit int) ([]*models.StockPrice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.stockBySymbol(symbol); !ok {
+		return nil, fmt.Errorf("failed to get price history: %w", ErrNotFound)
+	}
+
+	from, to = dbTime(from), dbTime(to)
+
+	var prices []*models.StockPrice
+	series := r.seriesOf(symbol)
+	for i := len(series) - 1; i >= 0; i-- {
+		price := series[i].price
+		if price.Timestamp.Before(from) || price.Timestamp.After(to) {
+			continue
+		}
+		prices = append(prices, &price)
+	}
+
+	prices, err := limitRows(prices, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price history: %w", err)
+	}
+	return prices, nil
+}
+
+func (r *MemoryRepository) GetLatestPrice(ctx context.Context, symbol string) (*models.StockPrice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	series := r.seriesOf(symbol)
+	if len(series) == 0 {
+		return nil, fmt.Errorf("failed to get latest price: %w", ErrNotFound)
+	}
+
+	price := series[len(series)-1].price
+	return &price, nil
+}
+
+func (r *MemoryRepository) GetPriceAt(ctx context.Context, symbol string, at time.Time) (*models.StockPrice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := dbTime(at)
+	series := r.seriesOf(symbol)
+	for i := len(series) - 1; i >= 0; i-- {
+		if !series[i].price.Timestamp.After(stored) {
+			price := series[i].price
+			return &price, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to get price at %s: %w", at.Format(time.RFC3339), ErrNotFound)
+}
+
+func (r *MemoryRepository) GetAverageDailyVolume(ctx context.Context, symbol string, days int, before time.Time) (float64, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// A quote's volume is cumulative for its day, so the day's largest
+	// value is its final volume.
+	before = dbTime(before)
+	dayVolumes := make(map[time.Time]int64)
+	for _, p := range r.seriesOf(symbol) {
+		if !p.price.Timestamp.Before(before) || p.price.Volume <= 0 {
+			continue
+		}
+		day := dbDate(p.price.Timestamp)
+		dayVolumes[day] = max(dayVolumes[day], p.price.Volume)
+	}
+
+	dates := make([]time.Time, 0, len(dayVolumes))
+	for day := range dayVolumes {
+		dates = append(dates, day)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].After(dates[j]) })
+
+	dates, err := limitRows(dates, days)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get average daily volume: %w", err)
+	}
+	if len(dates) == 0 {
+		return 0, 0, nil
+	}
+
+	var total float64
+	for _, day := range dates {
+		total += float64(dayVolumes[day])
+	}
+	return total / float64(len(dates)), len(dates), nil
+}
+
+func (r *MemoryRepository) GetCandles(ctx context.Context, symbol string, interval time.Duration, from, to time.Time) ([]*models.Candle, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if interval <= 0 {
+		return nil, fmt.Errorf("failed to get candles: %w", &ValidationError{Message: "stride must be greater than zero"})
+	}
+	if _, ok := r.stockBySymbol(symbol); !ok {
+		return nil, fmt.Errorf("failed to get candles: %w", ErrNotFound)
+	}
+
+	// Volumes are deltas of the cumulative daily volume, counting from the
+	// row before from, as in PostgresRepository.GetCandles.
+	from, to = dbTime(from), dbTime(to)
+	start := from.Add(-24 * time.Hour)
+
+	var candles []*models.Candle
+	var prev *point
+	for _, p := range r.seriesOf(symbol) {
+		ts := p.price.Timestamp
+		if ts.Before(start) || !ts.Before(to) {
+			continue
+		}
+
+		volume := p.price.Volume
+		if prev != nil && sameDay(prev.price.Timestamp, ts) && volume >= prev.price.Volume {
+			volume -= prev.price.Volume
+		}
+		prev = &p
+
+		if ts.Before(from) {
+			continue
+		}
+
+		b := bucket(ts, interval)
+		if n := len(candles); n > 0 && candles[n-1].Timestamp.Equal(b) {
+			c := candles[n-1]
+			c.High = max(c.High, p.high)
+			c.Low = min(c.Low, p.low)
+			c.Close = p.price.Price
+			c.Volume += volume
+			c.Count += p.count
+			continue
+		}
+		candles = append(candles, &models.Candle{
+			Timestamp: b,
+			Open:      p.open,
+			High:      p.high,
+			Low:       p.low,
+			Close:     p.price.Price,
+			Volume:    volume,
+			Count:     p.count,
+		})
+	}
+
+	return candles, nil
+}
+
+func (r *MemoryRepository) CompactPrices(ctx context.Context, tier, into string, before time.Time) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var length time.Duration
+	if into != "" {
+		var ok bool
+		if length, ok = models.BarDuration(into); !ok {
+			return 0, 0, &ValidationError{Message: fmt.Sprintf("unknown rollup interval %q", into)}
+		}
+	}
+
+	// Take the rows out of their tier, as rollups, oldest first.
+	before = dbTime(before)
+	moved := make(map[int][]*rollup)
+	var deleted int64
+	if tier == models.TierRaw {
+		for stockID, prices := range r.prices {
+			kept := prices[:0:0]
+			for _, p := range prices {
+				if p.Timestamp.Before(before) {
+					moved[stockID] = append(moved[stockID], &rollup{
+						timestamp: p.Timestamp,
+						open:      p.Price, high: p.Price, low: p.Price, close: p.Price,
+						volume: p.Volume,
+						count:  1,
+					})
+					continue
+				}
+				kept = append(kept, p)
+			}
+			r.prices[stockID] = kept
+		}
+	} else {
+		for stockID, rollups := range r.rollups {
+			kept := rollups[:0:0]
+			for _, ru := range rollups {
+				if ru.interval == tier && ru.timestamp.Before(before) {
+					moved[stockID] = append(moved[stockID], ru)
+					continue
+				}
+				kept = append(kept, ru)
+			}
+			r.rollups[stockID] = kept
+		}
+	}
+	for _, rows := range moved {
+		deleted += int64(len(rows))
+	}
+
+	if into == "" {
+		return 0, deleted, nil
+	}
+
+	var compacted int64
+	for stockID, rows := range moved {
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].timestamp.Before(rows[j].timestamp) })
+
+		var built []*rollup
+		for _, row := range rows {
+			b := bucket(row.timestamp, length)
+			if n := len(built); n > 0 && built[n-1].timestamp.Equal(b) {
+				ru := built[n-1]
+				ru.high = max(ru.high, row.high)
+				ru.low = min(ru.low, row.low)
+				ru.close = row.close
+				ru.volume = row.volume
+				ru.count += row.count
+				continue
+			}
+			built = append(built, &rollup{
+				interval:  into,
+				timestamp: b,
+				open:      row.open, high: row.high, low: row.low, close: row.close,
+				volume: row.volume,
+				count:  row.count,
+			})
+		}
+
+		for _, ru := range built {
+			r.upsertRollup(stockID, ru)
+		}
+		compacted += int64(len(built))
+	}
+
+	return compacted, deleted, nil
+}
+
+func (r *MemoryRepository) upsertRollup(stockID int, ru *rollup) {
+	for _, existing := range r.rollups[stockID] {
+		if existing.interval == ru.interval && existing.timestamp.Equal(ru.timestamp) {
+			existing.high = max(existing.high, ru.high)
+			existing.low = min(existing.low, ru.low)
+			existing.close = ru.close
+			existing.volume = max(existing.volume, ru.volume)
+			existing.count += ru.count
+			return
+		}
+	}
+	r.rollups[stockID] = append(r.rollups[stockID], ru)
+}
+
+func (r *MemoryRepository) SaveBars(ctx context.Context, bars []*models.PriceBar) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, bar := range bars {
+		stock, ok := r.stockBySymbol(bar.Symbol)
+		if !ok {
+			continue
+		}
+
+		stored := *bar
+		stored.StockID = stock.ID
+		stored.Timestamp = dbTime(bar.Timestamp)
+		r.bars[barKey{stock.ID, bar.Interval, stored.Timestamp}] = &stored
+	}
+
+	return nil
+}
+
+// barsOf returns a symbol's bars of interval, newest first.
+func (r *MemoryRepository) barsOf(symbol, interval string) []*models.PriceBar {
+	stock, ok := r.stockBySymbol(symbol)
+	if !ok {
+		return nil
+	}
+
+	var bars []*models.PriceBar
+	for key, stored := range r.bars {
+		if key.stockID == stock.ID && key.interval == interval {
+			bar := *stored
+			bar.Symbol = stock.Symbol
+			bars = append(bars, &bar)
+		}
+	}
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Timestamp.After(bars[j].Timestamp) })
+	return bars
+}
+
+func (r *MemoryRepository) GetBars(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]*models.PriceBar, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.stockBySymbol(symbol); !ok {
+		return nil, fmt.Errorf("failed to get bars: %w", ErrNotFound)
+	}
+
+	from, to = dbTime(from), dbTime(to)
+
+	var bars []*models.PriceBar
+	for _, bar := range r.barsOf(symbol, interval) {
+		if !bar.Timestamp.Before(from) && !bar.Timestamp.After(to) {
+			bars = append(bars, bar)
+		}
+	}
+
+	bars, err := limitRows(bars, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bars: %w", err)
+	}
+	return bars, nil
+}
+
+func (r *MemoryRepository) GetLatestBar(ctx context.Context, symbol, interval string) (*models.PriceBar, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bars := r.barsOf(symbol, interval)
+	if len(bars) == 0 {
+		return nil, nil
+	}
+	return bars[0], nil
+}
+
+func (r *MemoryRepository) GetBackfillProgress(ctx context.Context, symbol, interval string) (*models.BackfillProgress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stock, ok := r.stockBySymbol(symbol)
+	if !ok {
+		return nil, nil
+	}
+	stored, ok := r.progress[progressKey{stock.ID, interval}]
+	if !ok {
+		return nil, nil
+	}
+
+	progress := *stored
+	progress.Symbol = stock.Symbol
+	return &progress, nil
+}
+
+func (r *MemoryRepository) SaveBackfillProgress(ctx context.Context, progress *models.BackfillProgress) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stock, ok := r.stockBySymbol(progress.Symbol)
+	if !ok {
+		return fmt.Errorf("failed to save backfill progress: %w", ErrNotFound)
+	}
+
+	progress.UpdatedAt = now()
+	r.progress[progressKey{stock.ID, progress.Interval}] = &models.BackfillProgress{
+		Interval:  progress.Interval,
+		From:      dbDate(progress.From),
+		To:        dbDate(progress.To),
+		UpdatedAt: progress.UpdatedAt,
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) GetProviderQuota(ctx context.Context, provider string) (*models.ProviderQuota, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.quotas[provider]
+	if !ok {
+		return nil, nil
+	}
+
+	quota := *stored
+	return &quota, nil
+}
+
+func (r *MemoryRepository) SaveProviderQuota(ctx context.Context, quota *models.ProviderQuota) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var version int64
+	if stored, ok := r.quotas[quota.Provider]; ok {
+		version = stored.Version
+	}
+	if quota.Version != version {
+		return fmt.Errorf("failed to save %s quota: %w", quota.Provider, ErrProviderQuotaChanged)
+	}
+
+	quota.Version++
+	stored := *quota
+	stored.RefilledAt = dbTime(quota.RefilledAt)
+	stored.Day = dbDate(quota.Day)
+	r.quotas[quota.Provider] = &stored
+
+	return nil
+}
+
+func (r *MemoryRepository) SaveAlert(ctx context.Context, alert *models.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.stocks[alert.StockID]; !ok {
+		return fmt.Errorf("failed to save alert: %w", missing("stock %d does not exist", alert.StockID))
+	}
+	if alert.RuleID != nil {
+		if _, ok := r.rules[*alert.RuleID]; !ok {
+			return fmt.Errorf("failed to save alert: %w", missing("alert rule %d does not exist", *alert.RuleID))
+		}
+	}
+	if alert.DedupKey != "" {
+		for _, stored := range r.alerts {
+			if stored.DedupKey == alert.DedupKey {
+				return fmt.Errorf("failed to save alert %s: %w", alert.DedupKey, ErrDuplicateAlert)
+			}
+		}
+	}
+
+	stored := *alert
+	stored.ID = int(r.next("alerts"))
+	stored.Symbol = ""
+	stored.RuleID = copyInt(alert.RuleID)
+	stored.TriggeredAt = dbTime(alert.TriggeredAt)
+	stored.StateChangedAt, stored.StateChangedBy, stored.SnoozedUntil = nil, "", nil
+	r.alerts[stored.ID] = &stored
+
+	alert.ID = stored.ID
+	return nil
+}
+
+func copyInt(v *int) *int {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// alert returns a copy of a stored alert as PostgresRepository reads it:
+// with its symbol, and open once its snooze has run out.
+func (r *MemoryRepository) alert(stored *models.Alert) *models.Alert {
+	alert := *stored
+	alert.Symbol = r.stocks[stored.StockID].Symbol
+	alert.RuleID = copyInt(stored.RuleID)
+	alert.StateChangedAt = dbTimePtr(stored.StateChangedAt)
+	alert.SnoozedUntil = dbTimePtr(stored.SnoozedUntil)
+	alert.State = effectiveState(stored)
+	return &alert
+}
+
+func effectiveState(alert *models.Alert) string {
+	if alert.State == models.AlertSnoozed && alert.SnoozedUntil != nil && !alert.SnoozedUntil.After(now()) {
+		return models.AlertOpen
+	}
+	return alert.State
+}
+
+func (r *MemoryRepository) deleteAlert(id int) {
+	delete(r.alerts, id)
+	r.stateChanges = slices.DeleteFunc(r.stateChanges, func(c *models.AlertStateChange) bool { return c.AlertID == id })
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d *models.NotificationDelivery) bool { return d.AlertID == id })
+}
+
+func (r *MemoryRepository) GetAlert(ctx context.Context, id int) (*models.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.alerts[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get alert: %w", ErrNotFound)
+	}
+	return r.alert(stored), nil
+}
+
+func (r *MemoryRepository) ListAlerts(ctx context.Context, filter AlertFilter) ([]*models.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	from, to := dbTime(filter.From), dbTime(filter.To)
+
+	var alerts []*models.Alert
+	for _, stored := range r.alerts {
+		alert := r.alert(stored)
+		switch {
+		case filter.Symbol != "" && alert.Symbol != filter.Symbol,
+			filter.State != "" && alert.State != filter.State,
+			filter.AlertType != "" && alert.AlertType != filter.AlertType,
+			!filter.From.IsZero() && alert.TriggeredAt.Before(from),
+			!filter.To.IsZero() && !alert.TriggeredAt.Before(to):
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].TriggeredAt.After(alerts[j].TriggeredAt) })
+
+	alerts, err := limitRows(alerts, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+func (r *MemoryRepository) UpdateAlertState(ctx context.Context, change *models.AlertStateChange) (*models.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.alerts[change.AlertID]
+	if !ok {
+		return nil, fmt.Errorf("failed to lock alert: %w", ErrNotFound)
+	}
+	if current := effectiveState(stored); current != change.FromState {
+		return nil, fmt.Errorf("alert %d is %s, not %s: %w", change.AlertID, current, change.FromState, ErrAlertStateChanged)
+	}
+
+	changedAt := now()
+	stored.State = change.ToState
+	stored.StateChangedAt = &changedAt
+	stored.StateChangedBy = change.ChangedBy
+	stored.SnoozedUntil = dbTimePtr(change.SnoozedUntil)
+
+	change.ID = r.next("alert_state_changes")
+	change.ChangedAt = changedAt
+	recorded := *change
+	recorded.SnoozedUntil = dbTimePtr(change.SnoozedUntil)
+	r.stateChanges = append(r.stateChanges, &recorded)
+
+	return r.alert(stored), nil
+}
+
+func (r *MemoryRepository) GetAlertStateChanges(ctx context.Context, alertID int) ([]*models.AlertStateChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var changes []*models.AlertStateChange
+	for _, stored := range r.stateChanges {
+		if stored.AlertID == alertID {
+			change := *stored
+			change.SnoozedUntil = dbTimePtr(stored.SnoozedUntil)
+			changes = append(changes, &change)
+		}
+	}
+
+	return changes, nil
+}
+
+func (r *MemoryRepository) CreateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stock, ok := r.stockBySymbol(rule.Symbol)
+	if !ok {
+		return fmt.Errorf("failed to create alert rule: %w", ErrNotFound)
+	}
+
+	created := now()
+	rule.ID = int(r.next("alert_rules"))
+	rule.StockID = stock.ID
+	rule.CreatedAt, rule.UpdatedAt = created, created
+	rule.State = models.AlertRuleState{Armed: true}
+
+	stored := *rule
+	stored.Symbol = ""
+	stored.State = models.AlertRuleState{}
+	r.rules[stored.ID] = &stored
+
+	return nil
+}
+
+// rule returns a copy of a stored rule with its symbol and state.
+func (r *MemoryRepository) rule(stored *models.AlertRule) *models.AlertRule {
+	rule := *stored
+	rule.Symbol = r.stocks[stored.StockID].Symbol
+	rule.State = models.AlertRuleState{Armed: true}
+	if state, ok := r.ruleStates[stored.ID]; ok {
+		rule.State = *state
+		rule.State.LastFiredAt = dbTimePtr(state.LastFiredAt)
+	}
+	return &rule
+}
+
+func (r *MemoryRepository) deleteRule(id int) {
+	delete(r.rules, id)
+	delete(r.ruleStates, id)
+	delete(r.ruleChannels, id)
+	for _, alert := range r.alerts {
+		if alert.RuleID != nil && *alert.RuleID == id {
+			alert.RuleID = nil
+		}
+	}
+}
+
+func (r *MemoryRepository) GetAlertRule(ctx context.Context, id int) (*models.AlertRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.rules[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get alert rule: %w", ErrNotFound)
+	}
+	return r.rule(stored), nil
+}
+
+func (r *MemoryRepository) GetAlertRules(ctx context.Context, symbol string) ([]*models.AlertRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stock, ok := r.stockBySymbol(symbol)
+	if !ok {
+		return nil, fmt.Errorf("failed to get alert rules: %w", ErrNotFound)
+	}
+
+	var rules []*models.AlertRule
+	for _, stored := range r.rules {
+		if stored.StockID == stock.ID {
+			rules = append(rules, r.rule(stored))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	return rules, nil
+}
+
+func (r *MemoryRepository) UpdateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.rules[rule.ID]
+	if !ok {
+		return fmt.Errorf("failed to update alert rule: %w", ErrNotFound)
+	}
+
+	stored.RuleType = rule.RuleType
+	stored.Threshold = rule.Threshold
+	stored.WindowSeconds = rule.WindowSeconds
+	stored.CooldownSeconds = rule.CooldownSeconds
+	stored.Hysteresis = rule.Hysteresis
+	stored.Params = rule.Params
+	stored.Expression = rule.Expression
+	stored.Enabled = rule.Enabled
+	stored.UpdatedAt = now()
+
+	rule.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (r *MemoryRepository) DeleteAlertRule(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteRule(id)
+	return nil
+}
+
+func (r *MemoryRepository) SaveAlertRuleState(ctx context.Context, ruleID int, state *models.AlertRuleState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[ruleID]; !ok {
+		return fmt.Errorf("failed to save alert rule state: %w", missing("alert rule %d does not exist", ruleID))
+	}
+
+	stored := *state
+	stored.LastFiredAt = dbTimePtr(state.LastFiredAt)
+	r.ruleStates[ruleID] = &stored
+
+	return nil
+}
+
+func (r *MemoryRepository) channelNameTaken(name string, exceptID int) bool {
+	for _, channel := range r.channels {
+		if channel.Name == name && channel.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func copyChannel(stored *models.NotificationChannel) *models.NotificationChannel {
+	channel := *stored
+	channel.Config = slices.Clone(stored.Config)
+	return &channel
+}
+
+func (r *MemoryRepository) CreateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.channelNameTaken(channel.Name, 0) {
+		return fmt.Errorf("failed to create notification channel: %w: name %q is taken", ErrConflict, channel.Name)
+	}
+
+	created := now()
+	channel.ID = int(r.next("notification_channels"))
+	channel.CreatedAt, channel.UpdatedAt = created, created
+	r.channels[channel.ID] = copyChannel(channel)
+
+	return nil
+}
+
+func (r *MemoryRepository) GetNotificationChannel(ctx context.Context, id int) (*models.NotificationChannel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.channels[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get notification channel: %w", ErrNotFound)
+	}
+	return copyChannel(stored), nil
+}
+
+// channelsWhere returns copies of the channels keep accepts, by ID.
+func (r *MemoryRepository) channelsWhere(keep func(*models.NotificationChannel) bool) []*models.NotificationChannel {
+	var channels []*models.NotificationChannel
+	for _, stored := range r.channels {
+		if keep(stored) {
+			channels = append(channels, copyChannel(stored))
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
+	return channels
+}
+
+func (r *MemoryRepository) GetNotificationChannels(ctx context.Context) ([]*models.NotificationChannel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.channelsWhere(func(*models.NotificationChannel) bool { return true }), nil
+}
+
+func (r *MemoryRepository) UpdateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.channels[channel.ID]
+	if !ok {
+		return fmt.Errorf("failed to update notification channel: %w", ErrNotFound)
+	}
+	if r.channelNameTaken(channel.Name, channel.ID) {
+		return fmt.Errorf("failed to update notification channel: %w: name %q is taken", ErrConflict, channel.Name)
+	}
+
+	stored.Name = channel.Name
+	stored.Config = slices.Clone(channel.Config)
+	stored.Default = channel.Default
+	stored.Enabled = channel.Enabled
+	stored.UpdatedAt = now()
+
+	channel.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (r *MemoryRepository) DeleteNotificationChannel(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.channels, id)
+	for _, channels := range r.ruleChannels {
+		delete(channels, id)
+	}
+	for _, delivery := range r.deliveries {
+		if delivery.ChannelID == id {
+			delivery.ChannelID = 0
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) SetRuleChannels(ctx context.Context, ruleID int, channelIDs []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(channelIDs) > 0 {
+		if _, ok := r.rules[ruleID]; !ok {
+			return fmt.Errorf("failed to set rule channels: %w", missing("alert rule %d does not exist", ruleID))
+		}
+	}
+	channels := make(map[int]bool, len(channelIDs))
+	for _, id := range channelIDs {
+		if _, ok := r.channels[id]; !ok {
+			return fmt.Errorf("failed to set rule channels: %w", missing("notification channel %d does not exist", id))
+		}
+		channels[id] = true
+	}
+
+	delete(r.ruleChannels, ruleID)
+	if len(channels) > 0 {
+		r.ruleChannels[ruleID] = channels
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) GetRuleChannels(ctx context.Context, ruleID int) ([]*models.NotificationChannel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routed := r.ruleChannels[ruleID]
+	return r.channelsWhere(func(c *models.NotificationChannel) bool { return routed[c.ID] }), nil
+}
+
+func (r *MemoryRepository) GetDefaultChannels(ctx context.Context) ([]*models.NotificationChannel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.channelsWhere(func(c *models.NotificationChannel) bool { return c.Default }), nil
+}
+
+func (r *MemoryRepository) SaveDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.alerts[delivery.AlertID]; delivery.AlertID != 0 && !ok {
+		return fmt.Errorf("failed to save notification delivery: %w", missing("alert %d does not exist", delivery.AlertID))
+	}
+	if _, ok := r.channels[delivery.ChannelID]; !ok {
+		return fmt.Errorf("failed to save notification delivery: %w", missing("notification channel %d does not exist", delivery.ChannelID))
+	}
+
+	delivery.ID = r.next("notification_deliveries")
+	delivery.CreatedAt = now()
+	stored := *delivery
+	r.deliveries = append(r.deliveries, &stored)
+
+	return nil
+}
+
+func (r *MemoryRepository) GetDeliveries(ctx context.Context, alertID int) ([]*models.NotificationDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var deliveries []*models.NotificationDelivery
+	for _, stored := range r.deliveries {
+		if stored.AlertID == alertID && alertID != 0 {
+			delivery := *stored
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"testing"
+
+	"stock-tracker/internal/repository"
+	"stock-tracker/internal/repository/repotest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.StockRepository {
+		return repository.NewMemoryRepository()
+	})
+}
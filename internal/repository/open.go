@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"stock-tracker/internal/migrate"
+	"stock-tracker/migrations"
+)
+
+// Open connects to the repository databaseURL names by its scheme:
+// postgres:// or postgresql:// for PostgreSQL, sqlite:// followed by a file
+// path for SQLite, e.g. sqlite:///var/lib/stock-tracker/tracker.db.
+func Open(databaseURL string) (StockRepository, error) {
+	scheme, rest, _ := strings.Cut(databaseURL, "://")
+	switch scheme {
+	case "postgres", "postgresql":
+		repo, err := NewPostgresRepository(databaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case "sqlite":
+		if rest == "" {
+			return nil, fmt.Errorf("database URL %q has no file path", databaseURL)
+		}
+		repo, err := NewSQLiteRepository(rest)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("unsupported database URL scheme %q; expected postgres or sqlite", scheme)
+	}
+}
+
+// OpenMigrator connects to databaseURL like Open, without checking its
+// schema, and returns a Migrator with the migrations of its backend. The
+// caller closes the Migrator.
+func OpenMigrator(databaseURL string) (*migrate.Migrator, error) {
+	scheme, rest, _ := strings.Cut(databaseURL, "://")
+
+	var db *sql.DB
+	var dialect migrate.Dialect
+	var ms []migrate.Migration
+	var err error
+	switch scheme {
+	case "postgres", "postgresql":
+		db, err = sql.Open("pgx", databaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		dialect = migrate.Postgres
+		ms, err = migrate.Load(migrations.Postgres)
+	case "sqlite":
+		if rest == "" {
+			return nil, fmt.Errorf("database URL %q has no file path", databaseURL)
+		}
+		db, _, err = openSQLite(rest)
+		if err != nil {
+			return nil, err
+		}
+		dialect = migrate.SQLite
+		ms, err = migrate.Load(migrations.SQLite)
+	default:
+		return nil, fmt.Errorf("unsupported database URL scheme %q; expected postgres or sqlite", scheme)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return migrate.New(db, dialect, ms), nil
+}
+
+// Migrate applies the pending migrations of databaseURL.
+func Migrate(ctx context.Context, databaseURL string) error {
+	m, err := OpenMigrator(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	_, err = m.Up(ctx)
+	return err
+}
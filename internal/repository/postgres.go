@@ -2,17 +2,30 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 
+	"stock-tracker/internal/migrate"
 	"stock-tracker/internal/models"
+	"stock-tracker/migrations"
 	"stock-tracker/pkg/logger"
 	"time"
 )
 
 type PostgresRepository struct {
 	pool *pgxpool.Pool
+
+	// stockIDs caches the IDs SavePrices looks up by symbol.
+	idsMu    sync.RWMutex
+	stockIDs map[string]int
 }
 
 func NewPostgresRepository(databaseURL string) (*PostgresRepository, error) {
@@ -20,21 +33,90 @@ func NewPostgresRepository(databaseURL string) (*PostgresRepository, error) {
 
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+		return nil, fmt.Errorf("failed to parse database URL: %w", pgError(err))
 	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+		return nil, fmt.Errorf("failed to create connection pool: %w", pgError(err))
 	}
 
 	if err := pool.Ping(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, fmt.Errorf("failed to ping database: %w", pgError(err))
+	}
+
+	// Migrations are applied by "tracker migrate up", not here; refuse to
+	// run against a schema this build doesn't match
+	if err := checkPostgresSchema(ctx, pool); err != nil {
+		pool.Close()
+		return nil, err
 	}
 
 	logger.Info().Msg("Successfully connected to PostgreSQL database")
 
-	return &PostgresRepository{pool: pool}, nil
+	return &PostgresRepository{pool: pool, stockIDs: make(map[string]int)}, nil
+}
+
+func checkPostgresSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	ms, err := migrate.Load(migrations.Postgres)
+	if err != nil {
+		return err
+	}
+
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+
+	return migrate.New(db, migrate.Postgres, ms).Check(ctx)
+}
+
+// pgError wraps err in the repository error it amounts to, keeping it in
+// the chain, or returns it as is.
+func pgError(err error) error {
+	if translated(err) {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case errors.As(err, &pgErr):
+		switch class := pgErr.Code[:2]; {
+		// Unique violations, serialization failures and deadlocks
+		case pgErr.Code == "23505" || class == "40":
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		// A foreign key's detail names the missing row
+		case pgErr.Code == "23503" && pgErr.Detail != "":
+			return &ValidationError{Message: pgErr.Detail, Err: err}
+		// Other constraint violations and invalid data
+		case class == "22" || class == "23":
+			return &ValidationError{Message: pgErr.Message, Err: err}
+		// Lost connections, exhausted resources and shutdowns
+		case class == "08" || class == "53" || class == "57":
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+	case errors.As(err, &connectErr), errors.As(err, &netErr), pgconn.Timeout(err):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// requireStock returns an error wrapping ErrNotFound if symbol isn't a
+// stock. Reads by symbol call it when they find nothing, to tell an
+// unknown symbol from a stock without data.
+func (r *PostgresRepository) requireStock(ctx context.Context, symbol string) error {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM stocks WHERE symbol = $1)`, symbol).Scan(&exists)
+	if err != nil {
+		return pgError(err)
+	}
+	if !exists {
+		return fmt.Errorf("stock %s: %w", symbol, ErrNotFound)
+	}
+	return nil
 }
 
 func (r *PostgresRepository) Close() {
@@ -43,19 +125,23 @@ func (r *PostgresRepository) Close() {
 
 func (r *PostgresRepository) CreateStock(ctx context.Context, stock *models.Stock) error {
 	query := `
-		INSERT INTO stocks (symbol, name, created_at, updated_at)
-		VALUES ($1, $2, NOW(), NOW())
+		INSERT INTO stocks (symbol, name, active, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
 		ON CONFLICT (symbol) DO UPDATE SET updated_at = NOW()
-		RETURNING id, created_at, updated_at
+		RETURNING id, active, created_at, updated_at
 	`
 
-	err := r.pool.QueryRow(ctx, query, stock.Symbol, stock.Name).
-		Scan(&stock.ID, &stock.CreatedAt, &stock.UpdatedAt)
+	err := r.pool.QueryRow(ctx, query, stock.Symbol, stock.Name, stock.Active).
+		Scan(&stock.ID, &stock.Active, &stock.CreatedAt, &stock.UpdatedAt)
 
 	if err != nil {
-		return fmt.Errorf("failed to create stock: %w", err)
+		return fmt.Errorf("failed to create stock: %w", pgError(err))
 	}
 
+	r.idsMu.Lock()
+	r.stockIDs[stock.Symbol] = stock.ID
+	r.idsMu.Unlock()
+
 	logger.Debug().
 		Str("symbol", stock.Symbol).
 		Int("id", stock.ID).
@@ -66,19 +152,19 @@ func (r *PostgresRepository) CreateStock(ctx context.Context, stock *models.Stoc
 
 func (r *PostgresRepository) GetStock(ctx context.Context, symbol string) (*models.Stock, error) {
 	query := `
-		SELECT id, symbol, name, created_at, updated_at
+		SELECT id, symbol, name, active, created_at, updated_at
 		FROM stocks
 		WHERE symbol = $1
 	`
 
 	stock := &models.Stock{}
 	err := r.pool.QueryRow(ctx, query, symbol).Scan(
-		&stock.ID, &stock.Symbol, &stock.Name,
+		&stock.ID, &stock.Symbol, &stock.Name, &stock.Active,
 		&stock.CreatedAt, &stock.UpdatedAt,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get stock: %w", err)
+		return nil, fmt.Errorf("failed to get stock: %w", pgError(err))
 	}
 
 	return stock, nil
@@ -86,12 +172,12 @@ func (r *PostgresRepository) GetStock(ctx context.Context, symbol string) (*mode
 
 func (r *PostgresRepository) GetAllStocks(ctx context.Context) ([]*models.Stock, error) {
 	query := `
-		SELECT s.id, s.symbol, s.name, s.created_at, s.updated_at,
-		       sp.price, sp.change_percent, sp.timestamp
+		SELECT s.id, s.symbol, s.name, s.active, s.created_at, s.updated_at,
+		       sp.price, sp.change_percent, sp.volume, sp.provider, sp.timestamp
 		FROM stocks s
 		LEFT JOIN LATERAL (
-			SELECT price, change_percent, timestamp
-			FROM stock_prices
+			SELECT price, change_percent, volume, provider, timestamp
+			FROM ` + priceSeries + ` p
 			WHERE stock_id = s.id
 			ORDER BY timestamp DESC
 			LIMIT 1
@@ -101,7 +187,7 @@ func (r *PostgresRepository) GetAllStocks(ctx context.Context) ([]*models.Stock,
 
 	rows, err := r.pool.Query(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all stocks: %w", err)
+		return nil, fmt.Errorf("failed to get all stocks: %w", pgError(err))
 	}
 	defer rows.Close()
 
@@ -109,15 +195,17 @@ func (r *PostgresRepository) GetAllStocks(ctx context.Context) ([]*models.Stock,
 	for rows.Next() {
 		stock := &models.Stock{}
 		var price, changePercent *float64
+		var volume *int64
+		var provider *string
 		var timestamp *time.Time
 
 		err := rows.Scan(
-			&stock.ID, &stock.Symbol, &stock.Name,
+			&stock.ID, &stock.Symbol, &stock.Name, &stock.Active,
 			&stock.CreatedAt, &stock.UpdatedAt,
-			&price, &changePercent, &timestamp,
+			&price, &changePercent, &volume, &provider, &timestamp,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan stock: %w", err)
+			return nil, fmt.Errorf("failed to scan stock: %w", pgError(err))
 		}
 
 		if price != nil {
@@ -126,6 +214,12 @@ func (r *PostgresRepository) GetAllStocks(ctx context.Context) ([]*models.Stock,
 		if changePercent != nil {
 			stock.ChangePercent = *changePercent
 		}
+		if volume != nil {
+			stock.Volume = *volume
+		}
+		if provider != nil {
+			stock.Provider = *provider
+		}
 		if timestamp != nil {
 			stock.LastUpdated = *timestamp
 		}
@@ -139,16 +233,16 @@ func (r *PostgresRepository) GetAllStocks(ctx context.Context) ([]*models.Stock,
 func (r *PostgresRepository) UpdateStock(ctx context.Context, stock *models.Stock) error {
 	query := `
 		UPDATE stocks
-		SET name = $1, updated_at = NOW()
-		WHERE symbol = $2
-		RETURNING updated_at
+		SET name = $1, active = $2, updated_at = NOW()
+		WHERE symbol = $3
+		RETURNING id, created_at, updated_at
 	`
 
-	err := r.pool.QueryRow(ctx, query, stock.Name, stock.Symbol).
-		Scan(&stock.UpdatedAt)
+	err := r.pool.QueryRow(ctx, query, stock.Name, stock.Active, stock.Symbol).
+		Scan(&stock.ID, &stock.CreatedAt, &stock.UpdatedAt)
 
 	if err != nil {
-		return fmt.Errorf("failed to update stock: %w", err)
+		return fmt.Errorf("failed to update stock: %w", pgError(err))
 	}
 
 	return nil
@@ -159,36 +253,182 @@ func (r *PostgresRepository) DeleteStock(ctx context.Context, symbol string) err
 
 	_, err := r.pool.Exec(ctx, query, symbol)
 	if err != nil {
-		return fmt.Errorf("failed to delete stock: %w", err)
+		return fmt.Errorf("failed to delete stock: %w", pgError(err))
 	}
 
+	r.idsMu.Lock()
+	delete(r.stockIDs, symbol)
+	r.idsMu.Unlock()
+
 	logger.Info().Str("symbol", symbol).Msg("Stock deleted from database")
 	return nil
 }
 
+// priceSeries is stock_prices together with price_rollups, the rollups of
+// ticks the retention job has deleted, as one series of prices. A time
+// range only lives in one tier, so reading it through priceSeries routes
+// the query to whichever tier holds it. Daily bars of backfills fill in
+// the days neither tier has prices for. Rollup and bar rows have an id of
+// 0 and their interval as tier; raw ticks have an empty tier.
+var priceSeries = buildPriceSeries(`pb.timestamp + INTERVAL '1 day'`)
+
+// buildPriceSeries returns priceSeries with dayEnd, the end of the day of
+// bar pb, in the backend's SQL.
+func buildPriceSeries(dayEnd string) string {
+	return `(
+	SELECT id, stock_id, price AS open, price AS high, price AS low, price,
+	       change_percent, volume, provider, session, timestamp, '' AS tier, 1 AS count
+	FROM stock_prices
+	UNION ALL
+	SELECT 0, stock_id, open, high, low, close,
+	       0, volume, NULL, NULL, timestamp, interval, count
+	FROM price_rollups
+	UNION ALL
+	SELECT 0, pb.stock_id, pb.open, pb.high, pb.low, pb.close,
+	       0, pb.volume, pb.provider, NULL, pb.timestamp, pb.interval, 1
+	FROM price_bars pb
+	WHERE pb.interval = '` + models.BarDaily + `'
+	  AND NOT EXISTS (
+	      SELECT 1 FROM stock_prices p
+	      WHERE p.stock_id = pb.stock_id AND p.timestamp >= pb.timestamp AND p.timestamp < ` + dayEnd + `
+	  )
+	  AND NOT EXISTS (
+	      SELECT 1 FROM price_rollups ru
+	      WHERE ru.stock_id = pb.stock_id AND ru.timestamp >= pb.timestamp AND ru.timestamp < ` + dayEnd + `
+	  )
+)`
+}
+
 func (r *PostgresRepository) SavePrice(ctx context.Context, price *models.StockPrice) error {
 	query := `
-		INSERT INTO stock_prices (stock_id, price, change_percent, volume, timestamp)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO stock_prices (stock_id, price, change_percent, volume, provider, session, timestamp)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7)
 		RETURNING id
 	`
 
 	err := r.pool.QueryRow(ctx, query,
 		price.StockID, price.Price, price.ChangePercent,
-		price.Volume, price.Timestamp,
+		price.Volume, price.Provider, price.Session, price.Timestamp,
 	).Scan(&price.ID)
 
 	if err != nil {
-		return fmt.Errorf("failed to save price: %w", err)
+		return fmt.Errorf("failed to save price: %w", pgError(err))
+	}
+
+	return nil
+}
+
+// SavePrices streams the prices into stock_prices with COPY, which takes
+// one round trip however many there are.
+func (r *PostgresRepository) SavePrices(ctx context.Context, prices []*models.StockPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	ids, err := r.lookupStockIDs(ctx, prices)
+	if err != nil {
+		return fmt.Errorf("failed to save prices: %w", err)
+	}
+
+	rows := make([][]any, len(prices))
+	for i, price := range prices {
+		stockID := price.StockID
+		if stockID == 0 {
+			stockID = ids[price.Symbol]
+		}
+		rows[i] = []any{
+			stockID, price.Price, price.ChangePercent, price.Volume,
+			nullIfEmpty(price.Provider), nullIfEmpty(price.Session), price.Timestamp,
+		}
+	}
+
+	_, err = r.pool.CopyFrom(ctx, pgx.Identifier{"stock_prices"},
+		[]string{"stock_id", "price", "change_percent", "volume", "provider", "session", "timestamp"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		// A cached ID is stale if another process deleted the stock, so
+		// look the symbols up again next time
+		r.idsMu.Lock()
+		for symbol := range ids {
+			delete(r.stockIDs, symbol)
+		}
+		r.idsMu.Unlock()
+		return fmt.Errorf("failed to save prices: %w", pgError(err))
 	}
 
 	return nil
 }
 
+// lookupStockIDs returns the stock IDs of the symbols of the prices that
+// have no StockID, querying the ones not cached in one go.
+func (r *PostgresRepository) lookupStockIDs(ctx context.Context, prices []*models.StockPrice) (map[string]int, error) {
+	ids := make(map[string]int)
+	var unknown []string
+
+	r.idsMu.RLock()
+	for _, price := range prices {
+		if price.StockID != 0 {
+			continue
+		}
+		if _, ok := ids[price.Symbol]; ok {
+			continue
+		}
+		id, ok := r.stockIDs[price.Symbol]
+		ids[price.Symbol] = id
+		if !ok {
+			unknown = append(unknown, price.Symbol)
+		}
+	}
+	r.idsMu.RUnlock()
+
+	if len(unknown) == 0 {
+		return ids, nil
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT symbol, id FROM stocks WHERE symbol = ANY($1)`, unknown)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var symbol string
+		var id int
+		if err := rows.Scan(&symbol, &id); err != nil {
+			return nil, pgError(err)
+		}
+		ids[symbol] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgError(err)
+	}
+
+	r.idsMu.Lock()
+	defer r.idsMu.Unlock()
+	for _, symbol := range unknown {
+		if ids[symbol] == 0 {
+			return nil, &ValidationError{Message: fmt.Sprintf("stock %s does not exist", symbol)}
+		}
+		r.stockIDs[symbol] = ids[symbol]
+	}
+
+	return ids, nil
+}
+
+// nullIfEmpty turns an empty string into NULL for COPY, which can't use NULLIF.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 func (r *PostgresRepository) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time, limit int) ([]*models.StockPrice, error) {
 	query := `
-		SELECT sp.id, sp.stock_id, s.symbol, sp.price, sp.change_percent, sp.volume, sp.timestamp
-		FROM stock_prices sp
+		SELECT sp.id, sp.stock_id, s.symbol, sp.price, sp.change_percent, sp.volume,
+		       COALESCE(sp.provider, ''), COALESCE(sp.session, ''), sp.tier, sp.timestamp
+		FROM ` + priceSeries + ` sp
 		JOIN stocks s ON s.id = sp.stock_id
 		WHERE s.symbol = $1 AND sp.timestamp BETWEEN $2 AND $3
 		ORDER BY sp.timestamp DESC
@@ -197,7 +437,7 @@ func (r *PostgresRepository) GetPriceHistory(ctx context.Context, symbol string,
 
 	rows, err := r.pool.Query(ctx, query, symbol, from, to, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get price history: %w", err)
+		return nil, fmt.Errorf("failed to get price history: %w", pgError(err))
 	}
 	defer rows.Close()
 
@@ -207,21 +447,28 @@ func (r *PostgresRepository) GetPriceHistory(ctx context.Context, symbol string,
 		err := rows.Scan(
 			&price.ID, &price.StockID, &price.Symbol,
 			&price.Price, &price.ChangePercent, &price.Volume,
-			&price.Timestamp,
+			&price.Provider, &price.Session, &price.Tier, &price.Timestamp,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan price: %w", err)
+			return nil, fmt.Errorf("failed to scan price: %w", pgError(err))
 		}
 		prices = append(prices, price)
 	}
 
+	if len(prices) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get price history: %w", err)
+		}
+	}
+
 	return prices, nil
 }
 
 func (r *PostgresRepository) GetLatestPrice(ctx context.Context, symbol string) (*models.StockPrice, error) {
 	query := `
-		SELECT sp.id, sp.stock_id, s.symbol, sp.price, sp.change_percent, sp.volume, sp.timestamp
-		FROM stock_prices sp
+		SELECT sp.id, sp.stock_id, s.symbol, sp.price, sp.change_percent, sp.volume,
+		       COALESCE(sp.provider, ''), COALESCE(sp.session, ''), sp.tier, sp.timestamp
+		FROM ` + priceSeries + ` sp
 		JOIN stocks s ON s.id = sp.stock_id
 		WHERE s.symbol = $1
 		ORDER BY sp.timestamp DESC
@@ -232,96 +479,888 @@ func (r *PostgresRepository) GetLatestPrice(ctx context.Context, symbol string)
 	err := r.pool.QueryRow(ctx, query, symbol).Scan(
 		&price.ID, &price.StockID, &price.Symbol,
 		&price.Price, &price.ChangePercent, &price.Volume,
-		&price.Timestamp,
+		&price.Provider, &price.Session, &price.Tier, &price.Timestamp,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest price: %w", pgError(err))
+	}
+
+	return price, nil
+}
+
+func (r *PostgresRepository) GetPriceAt(ctx context.Context, symbol string, at time.Time) (*models.StockPrice, error) {
+	query := `
+		SELECT sp.id, sp.stock_id, s.symbol, sp.price, sp.change_percent, sp.volume,
+		       COALESCE(sp.provider, ''), COALESCE(sp.session, ''), sp.tier, sp.timestamp
+		FROM ` + priceSeries + ` sp
+		JOIN stocks s ON s.id = sp.stock_id
+		WHERE s.symbol = $1 AND sp.timestamp <= $2
+		ORDER BY sp.timestamp DESC
+		LIMIT 1
+	`
+
+	price := &models.StockPrice{}
+	err := r.pool.QueryRow(ctx, query, symbol, at).Scan(
+		&price.ID, &price.StockID, &price.Symbol,
+		&price.Price, &price.ChangePercent, &price.Volume,
+		&price.Provider, &price.Session, &price.Tier, &price.Timestamp,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get latest price: %w", err)
+		return nil, fmt.Errorf("failed to get price at %s: %w", at.Format(time.RFC3339), pgError(err))
 	}
 
 	return price, nil
 }
 
+func (r *PostgresRepository) GetAverageDailyVolume(ctx context.Context, symbol string, days int, before time.Time) (float64, int, error) {
+	// A quote's volume is cumulative for its day, so the day's largest
+	// value is its final volume.
+	query := `
+		SELECT COALESCE(AVG(day_volume), 0), COUNT(*)
+		FROM (
+			SELECT MAX(sp.volume) AS day_volume
+			FROM ` + priceSeries + ` sp
+			JOIN stocks s ON s.id = sp.stock_id
+			WHERE s.symbol = $1 AND sp.timestamp < $2 AND sp.volume > 0
+			GROUP BY sp.timestamp::date
+			ORDER BY sp.timestamp::date DESC
+			LIMIT $3
+		) d
+	`
+
+	var avg float64
+	var count int
+	err := r.pool.QueryRow(ctx, query, symbol, before, days).Scan(&avg, &count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get average daily volume: %w", pgError(err))
+	}
+
+	return avg, count, nil
+}
+
+func (r *PostgresRepository) GetCandles(ctx context.Context, symbol string, interval time.Duration, from, to time.Time) ([]*models.Candle, error) {
+	// Stored volumes are cumulative for the day, so a row's own volume is
+	// the increase since the row before it, which may precede from. The
+	// first row of a day, or one whose volume dropped because the provider
+	// started a new day, counts in full. Rollup rows take part with their
+	// whole bucket, so candles finer than a rollup come out at its size.
+	query := `
+		WITH lagged AS (
+			SELECT sp.timestamp AS ts, sp.open, sp.high, sp.low, sp.price AS close,
+			       COALESCE(sp.volume, 0) AS volume, sp.count,
+			       LAG(sp.timestamp) OVER (ORDER BY sp.timestamp) AS prev_ts,
+			       LAG(COALESCE(sp.volume, 0)) OVER (ORDER BY sp.timestamp) AS prev_volume
+			FROM ` + priceSeries + ` sp
+			JOIN stocks s ON s.id = sp.stock_id
+			WHERE s.symbol = $1 AND sp.timestamp >= $2::timestamp - INTERVAL '1 day' AND sp.timestamp < $3
+		),
+		deltas AS (
+			SELECT ts, open, high, low, close, count,
+			       CASE
+			           WHEN prev_ts IS NULL OR prev_ts::date <> ts::date OR volume < prev_volume THEN volume
+			           ELSE volume - prev_volume
+			       END AS volume
+			FROM lagged
+		)
+		SELECT date_bin($4::interval, ts, TIMESTAMP '2000-01-01') AS bucket,
+		       (array_agg(open ORDER BY ts))[1],
+		       MAX(high), MIN(low),
+		       (array_agg(close ORDER BY ts DESC))[1],
+		       SUM(volume)::bigint, SUM(count)::bigint
+		FROM deltas
+		WHERE ts >= $2
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+
+	rows, err := r.pool.Query(ctx, query, symbol, from, to, interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles: %w", pgError(err))
+	}
+	defer rows.Close()
+
+	var candles []*models.Candle
+	for rows.Next() {
+		candle := &models.Candle{}
+		err := rows.Scan(
+			&candle.Timestamp, &candle.Open, &candle.High, &candle.Low, &candle.Close,
+			&candle.Volume, &candle.Count,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan candle: %w", pgError(err))
+		}
+		candles = append(candles, candle)
+	}
+
+	if len(candles) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get candles: %w", err)
+		}
+	}
+
+	return candles, nil
+}
+
+func (r *PostgresRepository) CompactPrices(ctx context.Context, tier, into string, before time.Time) (int64, int64, error) {
+	// Both tiers look alike to the rest of the statement.
+	source := `
+		DELETE FROM stock_prices WHERE timestamp < $1
+		RETURNING stock_id, timestamp, price AS open, price AS high, price AS low, price AS close,
+		          COALESCE(volume, 0) AS volume, 1 AS count
+	`
+	args := []interface{}{before}
+	if tier != models.TierRaw {
+		source = `
+		DELETE FROM price_rollups WHERE interval = $2 AND timestamp < $1
+		RETURNING stock_id, timestamp, open, high, low, close, volume, count
+	`
+		args = append(args, tier)
+	}
+
+	if into == "" {
+		var deleted int64
+		query := `WITH moved AS (` + source + `) SELECT COUNT(*) FROM moved`
+		if err := r.pool.QueryRow(ctx, query, args...).Scan(&deleted); err != nil {
+			return 0, 0, fmt.Errorf("failed to delete %s prices: %w", tier, pgError(err))
+		}
+		return 0, deleted, nil
+	}
+
+	length, ok := models.BarDuration(into)
+	if !ok {
+		return 0, 0, &ValidationError{Message: fmt.Sprintf("unknown rollup interval %q", into)}
+	}
+
+	// volume is cumulative for the day, so a rollup keeps its last one. A
+	// bucket only conflicts when ticks arrive after it was rolled up.
+	query := fmt.Sprintf(`
+		WITH moved AS (%s),
+		rolled AS (
+			INSERT INTO price_rollups (stock_id, interval, timestamp, open, high, low, close, volume, count)
+			SELECT stock_id, $%d, date_bin($%d::interval, timestamp, TIMESTAMP '2000-01-01') AS bucket,
+			       (array_agg(open ORDER BY timestamp))[1], MAX(high), MIN(low),
+			       (array_agg(close ORDER BY timestamp DESC))[1],
+			       (array_agg(volume ORDER BY timestamp DESC))[1], SUM(count)
+			FROM moved
+			GROUP BY stock_id, bucket
+			ON CONFLICT (stock_id, interval, timestamp) DO UPDATE SET
+				high = GREATEST(price_rollups.high, EXCLUDED.high),
+				low = LEAST(price_rollups.low, EXCLUDED.low),
+				close = EXCLUDED.close,
+				volume = GREATEST(price_rollups.volume, EXCLUDED.volume),
+				count = price_rollups.count + EXCLUDED.count
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM rolled), (SELECT COUNT(*) FROM moved)
+	`, source, len(args)+1, len(args)+2)
+
+	var compacted, deleted int64
+	err := r.pool.QueryRow(ctx, query, append(args, into, length)...).Scan(&compacted, &deleted)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compact %s prices into %s: %w", tier, into, pgError(err))
+	}
+
+	return compacted, deleted, nil
+}
+
+func (r *PostgresRepository) SaveBars(ctx context.Context, bars []*models.PriceBar) error {
+	if len(bars) == 0 {
+		return nil
+	}
+
+	n := len(bars)
+	symbols, intervals, providers := make([]string, n), make([]string, n), make([]string, n)
+	timestamps := make([]time.Time, n)
+	opens, highs, lows, closes := make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+	volumes := make([]int64, n)
+	for i, bar := range bars {
+		symbols[i], intervals[i], providers[i] = bar.Symbol, bar.Interval, bar.Provider
+		timestamps[i] = bar.Timestamp
+		opens[i], highs[i], lows[i], closes[i] = bar.Open, bar.High, bar.Low, bar.Close
+		volumes[i] = bar.Volume
+	}
+
+	query := `
+		INSERT INTO price_bars (stock_id, interval, timestamp, open, high, low, close, volume, provider)
+		SELECT s.id, b.interval, b.timestamp, b.open, b.high, b.low, b.close, b.volume, NULLIF(b.provider, '')
+		FROM unnest($1::text[], $2::text[], $3::timestamp[], $4::float8[], $5::float8[], $6::float8[],
+		            $7::float8[], $8::bigint[], $9::text[])
+		     AS b(symbol, interval, timestamp, open, high, low, close, volume, provider)
+		JOIN stocks s ON s.symbol = b.symbol
+		ON CONFLICT (stock_id, interval, timestamp) DO UPDATE
+		SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close,
+		    volume = EXCLUDED.volume, provider = EXCLUDED.provider
+	`
+
+	_, err := r.pool.Exec(ctx, query, symbols, intervals, timestamps, opens, highs, lows, closes, volumes, providers)
+	if err != nil {
+		return fmt.Errorf("failed to save bars: %w", pgError(err))
+	}
+
+	return nil
+}
+
+const barColumns = `pb.stock_id, s.symbol, pb.interval, pb.timestamp, pb.open, pb.high, pb.low, pb.close, pb.volume, COALESCE(pb.provider, '')`
+
+func scanBar(row pgx.Row) (*models.PriceBar, error) {
+	bar := &models.PriceBar{}
+	err := row.Scan(
+		&bar.StockID, &bar.Symbol, &bar.Interval, &bar.Timestamp,
+		&bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume, &bar.Provider,
+	)
+	return bar, err
+}
+
+func (r *PostgresRepository) GetBars(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]*models.PriceBar, error) {
+	query := `
+		SELECT ` + barColumns + `
+		FROM price_bars pb
+		JOIN stocks s ON s.id = pb.stock_id
+		WHERE s.symbol = $1 AND pb.interval = $2 AND pb.timestamp BETWEEN $3 AND $4
+		ORDER BY pb.timestamp DESC
+		LIMIT $5
+	`
+
+	rows, err := r.pool.Query(ctx, query, symbol, interval, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bars: %w", pgError(err))
+	}
+	defer rows.Close()
+
+	var bars []*models.PriceBar
+	for rows.Next() {
+		bar, err := scanBar(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bar: %w", pgError(err))
+		}
+		bars = append(bars, bar)
+	}
+
+	if len(bars) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get bars: %w", err)
+		}
+	}
+
+	return bars, nil
+}
+
+func (r *PostgresRepository) GetLatestBar(ctx context.Context, symbol, interval string) (*models.PriceBar, error) {
+	query := `
+		SELECT ` + barColumns + `
+		FROM price_bars pb
+		JOIN stocks s ON s.id = pb.stock_id
+		WHERE s.symbol = $1 AND pb.interval = $2
+		ORDER BY pb.timestamp DESC
+		LIMIT 1
+	`
+
+	bar, err := scanBar(r.pool.QueryRow(ctx, query, symbol, interval))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest bar: %w", pgError(err))
+	}
+
+	return bar, nil
+}
+
+func (r *PostgresRepository) GetBackfillProgress(ctx context.Context, symbol, interval string) (*models.BackfillProgress, error) {
+	query := `
+		SELECT s.symbol, bp.interval, bp.from_date, bp.to_date, bp.updated_at
+		FROM backfill_progress bp
+		JOIN stocks s ON s.id = bp.stock_id
+		WHERE s.symbol = $1 AND bp.interval = $2
+	`
+
+	progress := &models.BackfillProgress{}
+	err := r.pool.QueryRow(ctx, query, symbol, interval).Scan(
+		&progress.Symbol, &progress.Interval, &progress.From, &progress.To, &progress.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backfill progress: %w", pgError(err))
+	}
+
+	return progress, nil
+}
+
+func (r *PostgresRepository) SaveBackfillProgress(ctx context.Context, progress *models.BackfillProgress) error {
+	query := `
+		INSERT INTO backfill_progress (stock_id, interval, from_date, to_date, updated_at)
+		SELECT s.id, $2, $3, $4, NOW()
+		FROM stocks s
+		WHERE s.symbol = $1
+		ON CONFLICT (stock_id, interval) DO UPDATE
+		SET from_date = EXCLUDED.from_date, to_date = EXCLUDED.to_date, updated_at = NOW()
+		RETURNING updated_at
+	`
+
+	err := r.pool.QueryRow(ctx, query, progress.Symbol, progress.Interval, progress.From, progress.To).Scan(&progress.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save backfill progress: %w", pgError(err))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetProviderQuota(ctx context.Context, provider string) (*models.ProviderQuota, error) {
+	query := `
+		SELECT provider, tokens, refilled_at, day, used_today, version
+		FROM provider_quotas
+		WHERE provider = $1
+	`
+
+	quota := &models.ProviderQuota{}
+	err := r.pool.QueryRow(ctx, query, provider).Scan(
+		&quota.Provider, &quota.Tokens, &quota.RefilledAt, &quota.Day, &quota.UsedToday, &quota.Version,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider quota: %w", pgError(err))
+	}
+
+	return quota, nil
+}
+
+func (r *PostgresRepository) SaveProviderQuota(ctx context.Context, quota *models.ProviderQuota) error {
+	// The update only applies to the version the quota was read at, and
+	// an insert of a quota already stored becomes such an update
+	query := `
+		INSERT INTO provider_quotas (provider, tokens, refilled_at, day, used_today, version)
+		VALUES ($1, $2, $3, $4, $5, $6 + 1)
+		ON CONFLICT (provider) DO UPDATE
+		SET tokens = EXCLUDED.tokens, refilled_at = EXCLUDED.refilled_at, day = EXCLUDED.day,
+		    used_today = EXCLUDED.used_today, version = EXCLUDED.version
+		WHERE provider_quotas.version = $6
+		RETURNING version
+	`
+
+	err := r.pool.QueryRow(ctx, query,
+		quota.Provider, quota.Tokens, quota.RefilledAt, quota.Day, quota.UsedToday, quota.Version,
+	).Scan(&quota.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to save %s quota: %w", quota.Provider, ErrProviderQuotaChanged)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save provider quota: %w", pgError(err))
+	}
+
+	return nil
+}
+
 func (r *PostgresRepository) SaveAlert(ctx context.Context, alert *models.Alert) error {
 	query := `
-		INSERT INTO alerts (stock_id, alert_type, threshold, message, triggered_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO alerts (stock_id, rule_id, alert_type, threshold, message, dedup_key, triggered_at, state)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+		ON CONFLICT (dedup_key) DO NOTHING
 		RETURNING id
 	`
 
 	err := r.pool.QueryRow(ctx, query,
-		alert.StockID, alert.AlertType, alert.Threshold,
-		alert.Message, alert.TriggeredAt,
+		alert.StockID, alert.RuleID, alert.AlertType, alert.Threshold,
+		alert.Message, alert.DedupKey, alert.TriggeredAt, alert.State,
 	).Scan(&alert.ID)
 
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to save alert %s: %w", alert.DedupKey, ErrDuplicateAlert)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save alert: %w", pgError(err))
+	}
+
+	return nil
+}
+
+// alertColumns selects an alert joined with its stock as "s". A snoozed
+// alert whose snooze has run out reads as open.
+const alertColumns = `
+		a.id, a.stock_id, s.symbol, a.rule_id, a.alert_type, a.threshold, a.message,
+		COALESCE(a.dedup_key, ''), a.triggered_at,
+		` + effectiveAlertState + `, a.state_changed_at, COALESCE(a.state_changed_by, ''), a.snoozed_until`
+
+const effectiveAlertState = `CASE WHEN a.state = 'snoozed' AND a.snoozed_until <= NOW() THEN 'open' ELSE a.state END`
+
+func scanAlert(row pgx.Row) (*models.Alert, error) {
+	alert := &models.Alert{}
+	err := row.Scan(
+		&alert.ID, &alert.StockID, &alert.Symbol, &alert.RuleID,
+		&alert.AlertType, &alert.Threshold, &alert.Message,
+		&alert.DedupKey, &alert.TriggeredAt,
+		&alert.State, &alert.StateChangedAt, &alert.StateChangedBy, &alert.SnoozedUntil,
+	)
+	return alert, err
+}
+
+func (r *PostgresRepository) GetAlert(ctx context.Context, id int) (*models.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts a JOIN stocks s ON s.id = a.stock_id WHERE a.id = $1`
+
+	alert, err := scanAlert(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", pgError(err))
+	}
+
+	return alert, nil
+}
+
+func (r *PostgresRepository) ListAlerts(ctx context.Context, filter AlertFilter) ([]*models.Alert, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Symbol != "" {
+		where("s.symbol = $%d", filter.Symbol)
+	}
+	if filter.State != "" {
+		where(effectiveAlertState+" = $%d", filter.State)
+	}
+	if filter.AlertType != "" {
+		where("a.alert_type = $%d", filter.AlertType)
+	}
+	if !filter.From.IsZero() {
+		where("a.triggered_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("a.triggered_at < $%d", filter.To)
+	}
+
+	query := `SELECT ` + alertColumns + ` FROM alerts a JOIN stocks s ON s.id = a.stock_id`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY a.triggered_at DESC LIMIT $%d", len(args))
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", pgError(err))
+	}
+	defer rows.Close()
+
+	var alerts []*models.Alert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", pgError(err))
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+func (r *PostgresRepository) UpdateAlertState(ctx context.Context, change *models.AlertStateChange) (*models.Alert, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", pgError(err))
+	}
+	defer tx.Rollback(ctx)
+
+	var current string
+	err = tx.QueryRow(ctx, `SELECT `+effectiveAlertState+` FROM alerts a WHERE a.id = $1 FOR UPDATE`, change.AlertID).
+		Scan(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock alert: %w", pgError(err))
+	}
+	if current != change.FromState {
+		return nil, fmt.Errorf("alert %d is %s, not %s: %w", change.AlertID, current, change.FromState, ErrAlertStateChanged)
+	}
+
+	query := `
+		UPDATE alerts
+		SET state = $1, state_changed_at = NOW(), state_changed_by = $2, snoozed_until = $3
+		WHERE id = $4
+	`
+	if _, err := tx.Exec(ctx, query, change.ToState, change.ChangedBy, change.SnoozedUntil, change.AlertID); err != nil {
+		return nil, fmt.Errorf("failed to update alert state: %w", pgError(err))
+	}
+
+	query = `
+		INSERT INTO alert_state_changes (alert_id, from_state, to_state, changed_by, note, snoozed_until, changed_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NOW())
+		RETURNING id, changed_at
+	`
+	err = tx.QueryRow(ctx, query,
+		change.AlertID, change.FromState, change.ToState, change.ChangedBy, change.Note, change.SnoozedUntil,
+	).Scan(&change.ID, &change.ChangedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record alert state change: %w", pgError(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit alert state change: %w", pgError(err))
+	}
+
+	return r.GetAlert(ctx, change.AlertID)
+}
+
+func (r *PostgresRepository) GetAlertStateChanges(ctx context.Context, alertID int) ([]*models.AlertStateChange, error) {
+	query := `
+		SELECT id, alert_id, from_state, to_state, changed_by, COALESCE(note, ''), snoozed_until, changed_at
+		FROM alert_state_changes
+		WHERE alert_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.pool.Query(ctx, query, alertID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert state changes: %w", pgError(err))
+	}
+	defer rows.Close()
+
+	var changes []*models.AlertStateChange
+	for rows.Next() {
+		change := &models.AlertStateChange{}
+		err := rows.Scan(
+			&change.ID, &change.AlertID, &change.FromState, &change.ToState,
+			&change.ChangedBy, &change.Note, &change.SnoozedUntil, &change.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert state change: %w", pgError(err))
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func (r *PostgresRepository) CreateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	query := `
+		INSERT INTO alert_rules (stock_id, rule_type, threshold, window_seconds, cooldown_seconds, hysteresis,
+		                         params, expression, enabled, created_at, updated_at)
+		SELECT s.id, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, NOW(), NOW()
+		FROM stocks s
+		WHERE s.symbol = $1
+		RETURNING id, stock_id, created_at, updated_at
+	`
+
+	err := r.pool.QueryRow(ctx, query,
+		rule.Symbol, rule.RuleType, rule.Threshold, rule.WindowSeconds, rule.CooldownSeconds,
+		rule.Hysteresis, rule.Params, rule.Expression, rule.Enabled,
+	).Scan(&rule.ID, &rule.StockID, &rule.CreatedAt, &rule.UpdatedAt)
+
 	if err != nil {
-		return fmt.Errorf("failed to save alert: %w", err)
+		return fmt.Errorf("failed to create alert rule: %w", pgError(err))
 	}
 
+	rule.State = models.AlertRuleState{Armed: true}
+
 	return nil
 }
 
-func (r *PostgresRepository) GetAlerts(ctx context.Context, symbol string, limit int) ([]*models.Alert, error) {
+func (r *PostgresRepository) GetAlertRule(ctx context.Context, id int) (*models.AlertRule, error) {
+	query := `
+		SELECT ar.id, ar.stock_id, s.symbol, ar.rule_type, ar.threshold, ar.window_seconds,
+		       ar.cooldown_seconds, ar.hysteresis, ar.params, COALESCE(ar.expression, ''), ar.enabled,
+		       COALESCE(st.armed, TRUE), COALESCE(st.episode, 0), st.last_fired_at,
+		       ar.created_at, ar.updated_at
+		FROM alert_rules ar
+		JOIN stocks s ON s.id = ar.stock_id
+		LEFT JOIN alert_rule_state st ON st.rule_id = ar.id
+		WHERE ar.id = $1
+	`
+
+	rule := &models.AlertRule{}
+	err := r.pool.QueryRow(ctx, query, id).Scan(
+		&rule.ID, &rule.StockID, &rule.Symbol, &rule.RuleType, &rule.Threshold,
+		&rule.WindowSeconds, &rule.CooldownSeconds, &rule.Hysteresis, &rule.Params, &rule.Expression, &rule.Enabled,
+		&rule.State.Armed, &rule.State.Episode, &rule.State.LastFiredAt,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rule: %w", pgError(err))
+	}
+
+	return rule, nil
+}
+
+func (r *PostgresRepository) GetAlertRules(ctx context.Context, symbol string) ([]*models.AlertRule, error) {
 	query := `
-		SELECT a.id, a.stock_id, s.symbol, a.alert_type, a.threshold, a.message, a.triggered_at
-		FROM alerts a
-		JOIN stocks s ON s.id = a.stock_id
+		SELECT ar.id, ar.stock_id, s.symbol, ar.rule_type, ar.threshold, ar.window_seconds,
+		       ar.cooldown_seconds, ar.hysteresis, ar.params, COALESCE(ar.expression, ''), ar.enabled,
+		       COALESCE(st.armed, TRUE), COALESCE(st.episode, 0), st.last_fired_at,
+		       ar.created_at, ar.updated_at
+		FROM alert_rules ar
+		JOIN stocks s ON s.id = ar.stock_id
+		LEFT JOIN alert_rule_state st ON st.rule_id = ar.id
 		WHERE s.symbol = $1
-		ORDER BY a.triggered_at DESC
-		LIMIT $2
+		ORDER BY ar.id
 	`
 
-	rows, err := r.pool.Query(ctx, query, symbol, limit)
+	rows, err := r.pool.Query(ctx, query, symbol)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get alerts: %w", err)
+		return nil, fmt.Errorf("failed to get alert rules: %w", pgError(err))
 	}
 	defer rows.Close()
 
-	var alerts []*models.Alert
+	var rules []*models.AlertRule
 	for rows.Next() {
-		alert := &models.Alert{}
+		rule := &models.AlertRule{}
 		err := rows.Scan(
-			&alert.ID, &alert.StockID, &alert.Symbol,
-			&alert.AlertType, &alert.Threshold, &alert.Message,
-			&alert.TriggeredAt,
+			&rule.ID, &rule.StockID, &rule.Symbol, &rule.RuleType, &rule.Threshold,
+			&rule.WindowSeconds, &rule.CooldownSeconds, &rule.Hysteresis, &rule.Params, &rule.Expression, &rule.Enabled,
+			&rule.State.Armed, &rule.State.Episode, &rule.State.LastFiredAt,
+			&rule.CreatedAt, &rule.UpdatedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan alert: %w", err)
+			return nil, fmt.Errorf("failed to scan alert rule: %w", pgError(err))
 		}
-		alerts = append(alerts, alert)
+		rules = append(rules, rule)
 	}
 
-	return alerts, nil
+	if len(rules) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get alert rules: %w", err)
+		}
+	}
+
+	return rules, nil
+}
+
+func (r *PostgresRepository) UpdateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	query := `
+		UPDATE alert_rules
+		SET rule_type = $1, threshold = $2, window_seconds = $3, cooldown_seconds = $4,
+		    hysteresis = $5, params = $6, expression = NULLIF($7, ''), enabled = $8, updated_at = NOW()
+		WHERE id = $9
+		RETURNING updated_at
+	`
+
+	err := r.pool.QueryRow(ctx, query,
+		rule.RuleType, rule.Threshold, rule.WindowSeconds, rule.CooldownSeconds,
+		rule.Hysteresis, rule.Params, rule.Expression, rule.Enabled, rule.ID,
+	).Scan(&rule.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to update alert rule: %w", pgError(err))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) DeleteAlertRule(ctx context.Context, id int) error {
+	query := `DELETE FROM alert_rules WHERE id = $1`
+
+	_, err := r.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", pgError(err))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) SaveAlertRuleState(ctx context.Context, ruleID int, state *models.AlertRuleState) error {
+	query := `
+		INSERT INTO alert_rule_state (rule_id, armed, episode, last_fired_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (rule_id) DO UPDATE
+		SET armed = EXCLUDED.armed, episode = EXCLUDED.episode,
+		    last_fired_at = EXCLUDED.last_fired_at, updated_at = NOW()
+	`
+
+	_, err := r.pool.Exec(ctx, query, ruleID, state.Armed, state.Episode, state.LastFiredAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule state: %w", pgError(err))
+	}
+
+	return nil
+}
+
+const channelColumns = `nc.id, nc.name, nc.channel_type, nc.config, nc.is_default, nc.enabled, nc.created_at, nc.updated_at`
+
+func (r *PostgresRepository) CreateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error {
+	query := `
+		INSERT INTO notification_channels (name, channel_type, config, is_default, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.pool.QueryRow(ctx, query,
+		channel.Name, channel.Type, channel.Config, channel.Default, channel.Enabled,
+	).Scan(&channel.ID, &channel.CreatedAt, &channel.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create notification channel: %w", pgError(err))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetNotificationChannel(ctx context.Context, id int) (*models.NotificationChannel, error) {
+	query := `SELECT ` + channelColumns + ` FROM notification_channels nc WHERE nc.id = $1`
+
+	channel := &models.NotificationChannel{}
+	err := r.pool.QueryRow(ctx, query, id).Scan(
+		&channel.ID, &channel.Name, &channel.Type, &channel.Config,
+		&channel.Default, &channel.Enabled, &channel.CreatedAt, &channel.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification channel: %w", pgError(err))
+	}
+
+	return channel, nil
+}
+
+func (r *PostgresRepository) GetNotificationChannels(ctx context.Context) ([]*models.NotificationChannel, error) {
+	query := `SELECT ` + channelColumns + ` FROM notification_channels nc ORDER BY nc.id`
+
+	return r.queryChannels(ctx, query)
+}
+
+func (r *PostgresRepository) UpdateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error {
+	query := `
+		UPDATE notification_channels
+		SET name = $1, config = $2, is_default = $3, enabled = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	err := r.pool.QueryRow(ctx, query,
+		channel.Name, channel.Config, channel.Default, channel.Enabled, channel.ID,
+	).Scan(&channel.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to update notification channel: %w", pgError(err))
+	}
+
+	return nil
 }
 
-func (r *PostgresRepository) GetRecentAlerts(ctx context.Context, limit int) ([]*models.Alert, error) {
+func (r *PostgresRepository) DeleteNotificationChannel(ctx context.Context, id int) error {
+	query := `DELETE FROM notification_channels WHERE id = $1`
+
+	_, err := r.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification channel: %w", pgError(err))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) SetRuleChannels(ctx context.Context, ruleID int, channelIDs []int) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", pgError(err))
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM alert_rule_channels WHERE rule_id = $1`, ruleID); err != nil {
+		return fmt.Errorf("failed to clear rule channels: %w", pgError(err))
+	}
+
+	query := `
+		INSERT INTO alert_rule_channels (rule_id, channel_id)
+		SELECT $1, unnest($2::int[])
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, query, ruleID, channelIDs); err != nil {
+		return fmt.Errorf("failed to set rule channels: %w", pgError(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit rule channels: %w", pgError(err))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetRuleChannels(ctx context.Context, ruleID int) ([]*models.NotificationChannel, error) {
 	query := `
-		SELECT a.id, a.stock_id, s.symbol, a.alert_type, a.threshold, a.message, a.triggered_at
-		FROM alerts a
-		JOIN stocks s ON s.id = a.stock_id
-		ORDER BY a.triggered_at DESC
-		LIMIT $1
+		SELECT ` + channelColumns + `
+		FROM notification_channels nc
+		JOIN alert_rule_channels arc ON arc.channel_id = nc.id
+		WHERE arc.rule_id = $1
+		ORDER BY nc.id
 	`
 
-	rows, err := r.pool.Query(ctx, query, limit)
+	return r.queryChannels(ctx, query, ruleID)
+}
+
+func (r *PostgresRepository) GetDefaultChannels(ctx context.Context) ([]*models.NotificationChannel, error) {
+	query := `SELECT ` + channelColumns + ` FROM notification_channels nc WHERE nc.is_default ORDER BY nc.id`
+
+	return r.queryChannels(ctx, query)
+}
+
+func (r *PostgresRepository) queryChannels(ctx context.Context, query string, args ...interface{}) ([]*models.NotificationChannel, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get recent alerts: %w", err)
+		return nil, fmt.Errorf("failed to get notification channels: %w", pgError(err))
 	}
 	defer rows.Close()
 
-	var alerts []*models.Alert
+	var channels []*models.NotificationChannel
 	for rows.Next() {
-		alert := &models.Alert{}
+		channel := &models.NotificationChannel{}
 		err := rows.Scan(
-			&alert.ID, &alert.StockID, &alert.Symbol,
-			&alert.AlertType, &alert.Threshold, &alert.Message,
-			&alert.TriggeredAt,
+			&channel.ID, &channel.Name, &channel.Type, &channel.Config,
+			&channel.Default, &channel.Enabled, &channel.CreatedAt, &channel.UpdatedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan alert: %w", err)
+			return nil, fmt.Errorf("failed to scan notification channel: %w", pgError(err))
 		}
-		alerts = append(alerts, alert)
+		channels = append(channels, channel)
 	}
 
-	return alerts, nil
+	return channels, nil
+}
+
+func (r *PostgresRepository) SaveDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	query := `
+		INSERT INTO notification_deliveries (alert_id, channel_id, attempt, status, error, created_at)
+		VALUES (NULLIF($1, 0), $2, $3, $4, NULLIF($5, ''), NOW())
+		RETURNING id, created_at
+	`
+
+	err := r.pool.QueryRow(ctx, query,
+		delivery.AlertID, delivery.ChannelID, delivery.Attempt, delivery.Status, delivery.Error,
+	).Scan(&delivery.ID, &delivery.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to save notification delivery: %w", pgError(err))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetDeliveries(ctx context.Context, alertID int) ([]*models.NotificationDelivery, error) {
+	query := `
+		SELECT id, alert_id, COALESCE(channel_id, 0), attempt, status, COALESCE(error, ''), created_at
+		FROM notification_deliveries
+		WHERE alert_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.pool.Query(ctx, query, alertID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification deliveries: %w", pgError(err))
+	}
+	defer rows.Close()
+
+	var deliveries []*models.NotificationDelivery
+	for rows.Next() {
+		delivery := &models.NotificationDelivery{}
+		err := rows.Scan(
+			&delivery.ID, &delivery.AlertID, &delivery.ChannelID, &delivery.Attempt,
+			&delivery.Status, &delivery.Error, &delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", pgError(err))
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
 }
@@ -0,0 +1,52 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"stock-tracker/internal/repository"
+	"stock-tracker/internal/repository/repotest"
+)
+
+// TestPostgresRepository runs against the database at TEST_DATABASE_URL,
+// which it migrates and empties, so never point it at one with real data.
+func TestPostgresRepository(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	if err := repository.Migrate(ctx, databaseURL); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	repotest.Run(t, func(t *testing.T) repository.StockRepository {
+		conn, err := pgx.Connect(ctx, databaseURL)
+		if err != nil {
+			t.Fatalf("failed to connect to database: %v", err)
+		}
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, `
+			TRUNCATE stocks, stock_prices, price_rollups, price_bars, backfill_progress,
+			         alert_rules, alert_rule_state, alerts, alert_state_changes,
+			         notification_channels, alert_rule_channels, notification_deliveries,
+			         provider_quotas
+			RESTART IDENTITY CASCADE
+		`)
+		if err != nil {
+			t.Fatalf("failed to empty database: %v", err)
+		}
+
+		repo, err := repository.NewPostgresRepository(databaseURL)
+		if err != nil {
+			t.Fatalf("NewPostgresRepository: %v", err)
+		}
+		t.Cleanup(repo.Close)
+		return repo
+	})
+}
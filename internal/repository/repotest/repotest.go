@@ -0,0 +1,1092 @@
+// Package repotest is a conformance suite for repository.StockRepository
+// implementations. Every implementation must pass it, so components can be
+// tested against the in-memory repository and run against a database:
+//
+//	func TestMemoryRepository(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repository.StockRepository {
+//			return repository.NewMemoryRepository()
+//		})
+//	}
+//
+// newRepo must return an empty repository each time it is called; for a
+// database that means truncating every table.
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+// base is a Monday long enough ago to be in the past for every test, and
+// recent enough for the time types of every backend.
+var base = time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+
+// Run runs the suite against the repositories newRepo returns.
+func Run(t *testing.T, newRepo func(t *testing.T) repository.StockRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.StockRepository)
+	}{
+		{"Stocks", testStocks},
+		{"CreateStockUpserts", testCreateStockUpserts},
+		{"DeleteStockCascades", testDeleteStockCascades},
+		{"PriceHistory", testPriceHistory},
+		{"SavePrices", testSavePrices},
+		{"AverageDailyVolume", testAverageDailyVolume},
+		{"Candles", testCandles},
+		{"CompactPrices", testCompactPrices},
+		{"Bars", testBars},
+		{"BackfilledHistory", testBackfilledHistory},
+		{"BackfillProgress", testBackfillProgress},
+		{"ProviderQuotas", testProviderQuotas},
+		{"Alerts", testAlerts},
+		{"AlertState", testAlertState},
+		{"AlertRules", testAlertRules},
+		{"NotificationChannels", testNotificationChannels},
+		{"Concurrency", testConcurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func createStock(t *testing.T, repo repository.StockRepository, symbol string) *models.Stock {
+	t.Helper()
+	stock := models.NewStock(symbol)
+	stock.Name = symbol + " Inc."
+	if err := repo.CreateStock(context.Background(), stock); err != nil {
+		t.Fatalf("CreateStock(%s): %v", symbol, err)
+	}
+	return stock
+}
+
+func savePrice(t *testing.T, repo repository.StockRepository, stock *models.Stock, at time.Time, price float64, volume int64) *models.StockPrice {
+	t.Helper()
+	p := &models.StockPrice{StockID: stock.ID, Price: price, ChangePercent: 1.5, Volume: volume, Provider: "test", Timestamp: at}
+	if err := repo.SavePrice(context.Background(), p); err != nil {
+		t.Fatalf("SavePrice(%s, %s): %v", stock.Symbol, at, err)
+	}
+	return p
+}
+
+// isValidation reports whether err wraps a *repository.ValidationError.
+func isValidation(err error) bool {
+	var validation *repository.ValidationError
+	return errors.As(err, &validation)
+}
+
+func timestamps(prices []*models.StockPrice) []time.Time {
+	ts := make([]time.Time, len(prices))
+	for i, p := range prices {
+		ts[i] = p.Timestamp
+	}
+	return ts
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func testStocks(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+
+	msft := createStock(t, repo, "MSFT")
+	aapl := createStock(t, repo, "AAPL")
+	if msft.ID == 0 || aapl.ID == 0 || msft.ID == aapl.ID {
+		t.Fatalf("CreateStock assigned IDs %d and %d", msft.ID, aapl.ID)
+	}
+	if msft.CreatedAt.IsZero() || msft.UpdatedAt.IsZero() {
+		t.Errorf("CreateStock left timestamps unset: %+v", msft)
+	}
+
+	got, err := repo.GetStock(ctx, "MSFT")
+	if err != nil {
+		t.Fatalf("GetStock: %v", err)
+	}
+	if got.ID != msft.ID || got.Name != "MSFT Inc." || !got.Active {
+		t.Errorf("GetStock = %+v, want %+v", got, msft)
+	}
+	if _, err := repo.GetStock(ctx, "NOPE"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetStock of an unknown symbol = %v, want ErrNotFound", err)
+	}
+
+	savePrice(t, repo, msft, base, 400, 1000)
+	savePrice(t, repo, msft, base.Add(time.Minute), 401, 2000)
+
+	stocks, err := repo.GetAllStocks(ctx)
+	if err != nil {
+		t.Fatalf("GetAllStocks: %v", err)
+	}
+	if len(stocks) != 2 || stocks[0].Symbol != "AAPL" || stocks[1].Symbol != "MSFT" {
+		t.Fatalf("GetAllStocks = %v, want AAPL and MSFT in order", stocks)
+	}
+	if stocks[0].CurrentPrice != 0 || !stocks[0].LastUpdated.IsZero() {
+		t.Errorf("AAPL without prices has price %v at %v", stocks[0].CurrentPrice, stocks[0].LastUpdated)
+	}
+	if s := stocks[1]; s.CurrentPrice != 401 || s.Volume != 2000 || s.Provider != "test" || !s.LastUpdated.Equal(base.Add(time.Minute)) {
+		t.Errorf("MSFT has latest price %v, volume %d from %q at %v; want 401, 2000 from test at %v",
+			s.CurrentPrice, s.Volume, s.Provider, s.LastUpdated, base.Add(time.Minute))
+	}
+
+	msft.Name, msft.Active = "Microsoft", false
+	if err := repo.UpdateStock(ctx, msft); err != nil {
+		t.Fatalf("UpdateStock: %v", err)
+	}
+	got, _ = repo.GetStock(ctx, "MSFT")
+	if got.Name != "Microsoft" || got.Active {
+		t.Errorf("after UpdateStock, GetStock = %+v", got)
+	}
+	if err := repo.UpdateStock(ctx, models.NewStock("NOPE")); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateStock of an unknown symbol = %v, want ErrNotFound", err)
+	}
+
+	if err := repo.SavePrice(ctx, &models.StockPrice{StockID: msft.ID + aapl.ID + 100, Price: 1, Timestamp: base}); !isValidation(err) {
+		t.Errorf("SavePrice for an unknown stock = %v, want a ValidationError", err)
+	}
+}
+
+func testCreateStockUpserts(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+
+	first := createStock(t, repo, "AAPL")
+	first.Active = false
+	if err := repo.UpdateStock(ctx, first); err != nil {
+		t.Fatalf("UpdateStock: %v", err)
+	}
+
+	// Creating an existing stock returns it as stored, keeping its name
+	// and active flag.
+	again := models.NewStock("AAPL")
+	again.Name = "Other"
+	if err := repo.CreateStock(ctx, again); err != nil {
+		t.Fatalf("CreateStock of an existing symbol: %v", err)
+	}
+	if again.ID != first.ID || again.Active || !again.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("CreateStock of an existing symbol = %+v, want ID %d, inactive, created at %v",
+			again, first.ID, first.CreatedAt)
+	}
+
+	got, _ := repo.GetStock(ctx, "AAPL")
+	if got.Name != "AAPL Inc." {
+		t.Errorf("CreateStock of an existing symbol renamed it to %q", got.Name)
+	}
+	if stocks, _ := repo.GetAllStocks(ctx); len(stocks) != 1 {
+		t.Errorf("GetAllStocks returned %d stocks, want 1", len(stocks))
+	}
+}
+
+func testDeleteStockCascades(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+
+	aapl := createStock(t, repo, "AAPL")
+	msft := createStock(t, repo, "MSFT")
+	for _, stock := range []*models.Stock{aapl, msft} {
+		savePrice(t, repo, stock, base, 100, 10)
+	}
+
+	rule := &models.AlertRule{Symbol: "AAPL", RuleType: models.RulePriceAbove, Threshold: 200, Enabled: true}
+	if err := repo.CreateAlertRule(ctx, rule); err != nil {
+		t.Fatalf("CreateAlertRule: %v", err)
+	}
+	alert := &models.Alert{StockID: aapl.ID, RuleID: &rule.ID, AlertType: rule.RuleType, DedupKey: "cascade", TriggeredAt: base, State: models.AlertOpen}
+	if err := repo.SaveAlert(ctx, alert); err != nil {
+		t.Fatalf("SaveAlert: %v", err)
+	}
+	bar := &models.PriceBar{Symbol: "AAPL", Interval: models.BarDaily, Timestamp: base, Open: 1, High: 1, Low: 1, Close: 1}
+	if err := repo.SaveBars(ctx, []*models.PriceBar{bar}); err != nil {
+		t.Fatalf("SaveBars: %v", err)
+	}
+
+	if err := repo.DeleteStock(ctx, "AAPL"); err != nil {
+		t.Fatalf("DeleteStock: %v", err)
+	}
+	if err := repo.DeleteStock(ctx, "NOPE"); err != nil {
+		t.Errorf("DeleteStock of an unknown symbol: %v", err)
+	}
+
+	if _, err := repo.GetStock(ctx, "AAPL"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetStock of the deleted stock = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetLatestPrice(ctx, "AAPL"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetLatestPrice of the deleted stock = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetAlert(ctx, alert.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetAlert of an alert of the deleted stock = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetAlertRule(ctx, rule.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetAlertRule of a rule of the deleted stock = %v, want ErrNotFound", err)
+	}
+	if bar, _ := repo.GetLatestBar(ctx, "AAPL", models.BarDaily); bar != nil {
+		t.Error("GetLatestBar found a bar of the deleted stock")
+	}
+
+	// Recreating the symbol starts from scratch.
+	createStock(t, repo, "AAPL")
+	if history, _ := repo.GetPriceHistory(ctx, "AAPL", time.Time{}, base.Add(time.Hour), 10); len(history) != 0 {
+		t.Errorf("recreated stock has %d prices", len(history))
+	}
+	if _, err := repo.GetLatestPrice(ctx, "MSFT"); err != nil {
+		t.Errorf("DeleteStock removed another stock's prices: %v", err)
+	}
+}
+
+func testPriceHistory(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+
+	stock := createStock(t, repo, "AAPL")
+	other := createStock(t, repo, "MSFT")
+	savePrice(t, repo, other, base.Add(90*time.Second), 999, 1)
+
+	// Saved out of order on purpose.
+	var want []time.Time
+	for _, minute := range []int{2, 0, 4, 1, 3} {
+		at := base.Add(time.Duration(minute) * time.Minute)
+		p := savePrice(t, repo, stock, at, 100+float64(minute), int64(minute))
+		if p.ID == 0 {
+			t.Fatal("SavePrice did not assign an ID")
+		}
+	}
+	for minute := 4; minute >= 0; minute-- {
+		want = append(want, base.Add(time.Duration(minute)*time.Minute))
+	}
+
+	history, err := repo.GetPriceHistory(ctx, "AAPL", base, base.Add(4*time.Minute), 100)
+	if err != nil {
+		t.Fatalf("GetPriceHistory: %v", err)
+	}
+	if got := timestamps(history); !equalTimes(got, want) {
+		t.Errorf("GetPriceHistory = %v, want %v, newest first and both bounds included", got, want)
+	}
+	if p := history[0]; p.Symbol != "AAPL" || p.StockID != stock.ID || p.Price != 104 || p.Volume != 4 || p.Provider != "test" || p.Tier != "" {
+		t.Errorf("GetPriceHistory returned %+v", p)
+	}
+
+	history, _ = repo.GetPriceHistory(ctx, "AAPL", base.Add(time.Minute), base.Add(3*time.Minute), 2)
+	if got := timestamps(history); !equalTimes(got, want[1:3]) {
+		t.Errorf("GetPriceHistory with a range and limit = %v, want %v", got, want[1:3])
+	}
+	if _, err := repo.GetPriceHistory(ctx, "NOPE", base, base.Add(time.Hour), 10); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetPriceHistory of an unknown symbol = %v, want ErrNotFound", err)
+	}
+	if history, err := repo.GetPriceHistory(ctx, "AAPL", base.Add(time.Hour), base.Add(2*time.Hour), 10); err != nil || len(history) != 0 {
+		t.Errorf("GetPriceHistory of a range without prices = %d prices, %v; want none", len(history), err)
+	}
+	if _, err := repo.GetPriceHistory(ctx, "AAPL", base, base.Add(time.Hour), -1); !isValidation(err) {
+		t.Errorf("GetPriceHistory with a negative limit = %v, want a ValidationError", err)
+	}
+
+	latest, err := repo.GetLatestPrice(ctx, "AAPL")
+	if err != nil || latest.Price != 104 {
+		t.Errorf("GetLatestPrice = %+v, %v; want the price of 104", latest, err)
+	}
+	if _, err := repo.GetLatestPrice(ctx, "NOPE"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetLatestPrice of an unknown symbol = %v, want ErrNotFound", err)
+	}
+
+	at, err := repo.GetPriceAt(ctx, "AAPL", base.Add(150*time.Second))
+	if err != nil || !at.Timestamp.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("GetPriceAt(2m30s) = %+v, %v; want the price at 2m", at, err)
+	}
+	at, err = repo.GetPriceAt(ctx, "AAPL", base.Add(3*time.Minute))
+	if err != nil || !at.Timestamp.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("GetPriceAt(3m) = %+v, %v; want the price at 3m itself", at, err)
+	}
+	if _, err := repo.GetPriceAt(ctx, "AAPL", base.Add(-time.Second)); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetPriceAt before the first price = %v, want ErrNotFound", err)
+	}
+}
+
+func testSavePrices(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+
+	aapl := createStock(t, repo, "AAPL")
+	createStock(t, repo, "MSFT")
+
+	// By ID and by symbol alone, with an empty provider stored as none.
+	prices := []*models.StockPrice{
+		{StockID: aapl.ID, Symbol: "AAPL", Price: 101, ChangePercent: 1.5, Volume: 10, Provider: "test", Session: "regular", Timestamp: base},
+		{Symbol: "MSFT", Price: 201, ChangePercent: -0.5, Volume: 20, Timestamp: base},
+		{Symbol: "AAPL", Price: 102, Volume: 30, Provider: "test", Timestamp: base.Add(time.Minute)},
+	}
+	if err := repo.SavePrices(ctx, prices); err != nil {
+		t.Fatalf("SavePrices: %v", err)
+	}
+	if err := repo.SavePrices(ctx, nil); err != nil {
+		t.Errorf("SavePrices of no prices = %v, want nil", err)
+	}
+
+	history, err := repo.GetPriceHistory(ctx, "AAPL", base, base.Add(time.Hour), 10)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("GetPriceHistory after SavePrices = %d prices, %v; want 2", len(history), err)
+	}
+	if p := history[1]; p.Price != 101 || p.ChangePercent != 1.5 || p.Volume != 10 || p.Provider != "test" || p.Session != "regular" || !p.Timestamp.Equal(base) {
+		t.Errorf("SavePrices stored %+v", p)
+	}
+	msft, err := repo.GetLatestPrice(ctx, "MSFT")
+	if err != nil || msft.Price != 201 || msft.Provider != "" {
+		t.Errorf("GetLatestPrice(MSFT) = %+v, %v; want the price of 201 without a provider", msft, err)
+	}
+
+	// One unknown symbol fails the whole batch.
+	bad := []*models.StockPrice{
+		{Symbol: "AAPL", Price: 103, Timestamp: base.Add(2 * time.Minute)},
+		{Symbol: "NOPE", Price: 1, Timestamp: base.Add(2 * time.Minute)},
+	}
+	if err := repo.SavePrices(ctx, bad); !isValidation(err) {
+		t.Errorf("SavePrices with an unknown symbol = %v, want a ValidationError", err)
+	}
+	if latest, _ := repo.GetLatestPrice(ctx, "AAPL"); latest == nil || latest.Price != 102 {
+		t.Errorf("GetLatestPrice after a failed batch = %+v, want the price of 102", latest)
+	}
+
+	// A symbol removed and added again gets a new ID.
+	if err := repo.DeleteStock(ctx, "MSFT"); err != nil {
+		t.Fatalf("DeleteStock: %v", err)
+	}
+	createStock(t, repo, "MSFT")
+	if err := repo.SavePrices(ctx, []*models.StockPrice{{Symbol: "MSFT", Price: 202, Timestamp: base}}); err != nil {
+		t.Errorf("SavePrices for a re-added stock: %v", err)
+	}
+}
+
+func testAverageDailyVolume(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	stock := createStock(t, repo, "AAPL")
+
+	// Cumulative volumes over three days; the last tick of each day is its
+	// volume.
+	day := 24 * time.Hour
+	for i, volumes := range [][]int64{{100, 300}, {50, 500}, {0, 900}} {
+		for j, volume := range volumes {
+			savePrice(t, repo, stock, base.Add(time.Duration(i)*day+time.Duration(j)*time.Hour), 100, volume)
+		}
+	}
+
+	avg, days, err := repo.GetAverageDailyVolume(ctx, "AAPL", 2, base.Add(3*day))
+	if err != nil {
+		t.Fatalf("GetAverageDailyVolume: %v", err)
+	}
+	if days != 2 || avg != 700 {
+		t.Errorf("GetAverageDailyVolume of the last 2 days = %v over %d days, want 700 over 2", avg, days)
+	}
+
+	avg, days, _ = repo.GetAverageDailyVolume(ctx, "AAPL", 20, base.Add(2*day))
+	if days != 2 || avg != 400 {
+		t.Errorf("GetAverageDailyVolume before day 3 = %v over %d days, want 400 over 2", avg, days)
+	}
+
+	avg, days, err = repo.GetAverageDailyVolume(ctx, "NOPE", 20, base.Add(3*day))
+	if err != nil || days != 0 || avg != 0 {
+		t.Errorf("GetAverageDailyVolume of an unknown symbol = %v, %d, %v", avg, days, err)
+	}
+}
+
+func testCandles(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	stock := createStock(t, repo, "AAPL")
+
+	// A tick before the range only provides the volume baseline.
+	savePrice(t, repo, stock, base.Add(-time.Minute), 99, 1000)
+	ticks := []struct {
+		offset time.Duration
+		price  float64
+		volume int64
+	}{
+		{0, 100, 1100},
+		{2 * time.Minute, 103, 1150},
+		{4 * time.Minute, 98, 1300},
+		// 14:05-14:10 has no ticks
+		{11 * time.Minute, 101, 1600},
+	}
+	for _, tick := range ticks {
+		savePrice(t, repo, stock, base.Add(tick.offset), tick.price, tick.volume)
+	}
+
+	candles, err := repo.GetCandles(ctx, "AAPL", 5*time.Minute, base, base.Add(15*time.Minute))
+	if err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	want := []models.Candle{
+		{Timestamp: base, Open: 100, High: 103, Low: 98, Close: 98, Volume: 300, Count: 3},
+		{Timestamp: base.Add(10 * time.Minute), Open: 101, High: 101, Low: 101, Close: 101, Volume: 300, Count: 1},
+	}
+	if len(candles) != len(want) {
+		t.Fatalf("GetCandles returned %d candles, want %d: %v", len(candles), len(want), candles)
+	}
+	for i, c := range candles {
+		if !c.Timestamp.Equal(want[i].Timestamp) || c.Open != want[i].Open || c.High != want[i].High ||
+			c.Low != want[i].Low || c.Close != want[i].Close || c.Volume != want[i].Volume || c.Count != want[i].Count {
+			t.Errorf("candle %d = %+v, want %+v", i, *c, want[i])
+		}
+	}
+
+	// The end is exclusive.
+	candles, _ = repo.GetCandles(ctx, "AAPL", time.Hour, base, base.Add(11*time.Minute))
+	if len(candles) != 1 || candles[0].Count != 3 {
+		t.Errorf("GetCandles up to 14:11 = %v, want one candle of 3 ticks", candles)
+	}
+
+	if _, err := repo.GetCandles(ctx, "NOPE", time.Hour, base, base.Add(time.Hour)); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetCandles of an unknown symbol = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetCandles(ctx, "AAPL", 0, base, base.Add(time.Hour)); !isValidation(err) {
+		t.Errorf("GetCandles with a zero interval = %v, want a ValidationError", err)
+	}
+}
+
+func testCompactPrices(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	stock := createStock(t, repo, "AAPL")
+
+	// Two 5-minute buckets of raw ticks and one tick that stays raw.
+	for i, price := range []float64{10, 12, 9, 11, 20, 21} {
+		savePrice(t, repo, stock, base.Add(time.Duration(i)*2*time.Minute), price, int64(100*(i+1)))
+	}
+
+	compacted, deleted, err := repo.CompactPrices(ctx, models.TierRaw, models.Bar5Min, base.Add(10*time.Minute))
+	if err != nil {
+		t.Fatalf("CompactPrices: %v", err)
+	}
+	if compacted != 2 || deleted != 5 {
+		t.Errorf("CompactPrices = %d compacted, %d deleted; want 2, 5", compacted, deleted)
+	}
+
+	history, _ := repo.GetPriceHistory(ctx, "AAPL", base, base.Add(time.Hour), 10)
+	want := []struct {
+		at    time.Time
+		price float64
+		tier  string
+	}{
+		{base.Add(10 * time.Minute), 21, ""},
+		{base.Add(5 * time.Minute), 20, models.Bar5Min},
+		{base, 9, models.Bar5Min},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("GetPriceHistory after compaction returned %d prices, want %d", len(history), len(want))
+	}
+	for i, p := range history {
+		if !p.Timestamp.Equal(want[i].at) || p.Price != want[i].price || p.Tier != want[i].tier {
+			t.Errorf("price %d = %v %v %q, want %v %v %q", i, p.Timestamp, p.Price, p.Tier, want[i].at, want[i].price, want[i].tier)
+		}
+	}
+
+	candles, _ := repo.GetCandles(ctx, "AAPL", time.Hour, base, base.Add(time.Hour))
+	if len(candles) != 1 {
+		t.Fatalf("GetCandles after compaction returned %v", candles)
+	}
+	if c := candles[0]; c.Open != 10 || c.High != 21 || c.Low != 9 || c.Close != 21 || c.Count != 6 || c.Volume != 600 {
+		t.Errorf("GetCandles after compaction = %+v", *c)
+	}
+
+	// Rollups move on to the next tier, and the last tier is deleted.
+	compacted, deleted, err = repo.CompactPrices(ctx, models.Bar5Min, models.BarDaily, base.Add(24*time.Hour))
+	if err != nil || compacted != 1 || deleted != 2 {
+		t.Errorf("CompactPrices into 1d = %d, %d, %v; want 1, 2", compacted, deleted, err)
+	}
+	if _, deleted, err := repo.CompactPrices(ctx, models.BarDaily, "", base.Add(24*time.Hour)); err != nil || deleted != 1 {
+		t.Errorf("CompactPrices of the last tier deleted %d, %v; want 1", deleted, err)
+	}
+	if history, _ := repo.GetPriceHistory(ctx, "AAPL", time.Time{}, base.Add(time.Hour), 10); len(history) != 1 {
+		t.Errorf("%d prices left, want the raw one", len(history))
+	}
+
+	if _, _, err := repo.CompactPrices(ctx, models.TierRaw, "7m", base.Add(time.Hour)); !isValidation(err) {
+		t.Errorf("CompactPrices into an unknown interval = %v, want a ValidationError", err)
+	}
+	if _, err := repo.GetLatestPrice(ctx, "AAPL"); err != nil {
+		t.Errorf("a failed CompactPrices deleted prices: %v", err)
+	}
+}
+
+func testBars(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	createStock(t, repo, "AAPL")
+
+	if bar, err := repo.GetLatestBar(ctx, "AAPL", models.Bar5Min); bar != nil || err != nil {
+		t.Errorf("GetLatestBar without bars = %v, %v; want nil, nil", bar, err)
+	}
+
+	var bars []*models.PriceBar
+	for i := range 4 {
+		bars = append(bars, &models.PriceBar{
+			Symbol: "AAPL", Interval: models.Bar5Min, Timestamp: base.Add(time.Duration(i) * 5 * time.Minute),
+			Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: int64(i), Provider: "test",
+		})
+	}
+	bars = append(bars,
+		&models.PriceBar{Symbol: "AAPL", Interval: models.BarDaily, Timestamp: base, Open: 1, High: 1, Low: 1, Close: 1},
+		&models.PriceBar{Symbol: "NOPE", Interval: models.Bar5Min, Timestamp: base, Open: 1, High: 1, Low: 1, Close: 1},
+	)
+	if err := repo.SaveBars(ctx, bars); err != nil {
+		t.Fatalf("SaveBars: %v", err)
+	}
+
+	// Saving a bar again replaces it.
+	replaced := *bars[3]
+	replaced.Close, replaced.Volume = 1.75, 99
+	if err := repo.SaveBars(ctx, []*models.PriceBar{&replaced}); err != nil {
+		t.Fatalf("SaveBars again: %v", err)
+	}
+
+	got, err := repo.GetBars(ctx, "AAPL", models.Bar5Min, base.Add(5*time.Minute), base.Add(15*time.Minute), 10)
+	if err != nil {
+		t.Fatalf("GetBars: %v", err)
+	}
+	if len(got) != 3 || !got[0].Timestamp.Equal(base.Add(15*time.Minute)) || !got[2].Timestamp.Equal(base.Add(5*time.Minute)) {
+		t.Fatalf("GetBars = %v, want the bars from 14:05 to 14:15, newest first", got)
+	}
+	if b := got[0]; b.Close != 1.75 || b.Volume != 99 || b.Symbol != "AAPL" || b.Provider != "test" {
+		t.Errorf("replaced bar = %+v", *b)
+	}
+	if got, _ := repo.GetBars(ctx, "AAPL", models.Bar5Min, base, base.Add(time.Hour), 2); len(got) != 2 {
+		t.Errorf("GetBars with limit 2 returned %d bars", len(got))
+	}
+	if _, err := repo.GetBars(ctx, "NOPE", models.Bar5Min, base, base.Add(time.Hour), 10); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetBars of an unknown symbol = %v, want ErrNotFound", err)
+	}
+
+	latest, err := repo.GetLatestBar(ctx, "AAPL", models.BarDaily)
+	if err != nil || latest == nil || !latest.Timestamp.Equal(base) {
+		t.Errorf("GetLatestBar(1d) = %v, %v", latest, err)
+	}
+	if bar, _ := repo.GetLatestBar(ctx, "NOPE", models.Bar5Min); bar != nil {
+		t.Error("SaveBars stored a bar for an unknown symbol")
+	}
+}
+
+func testBackfilledHistory(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	createStock(t, repo, "AAPL")
+	msft := createStock(t, repo, "MSFT")
+	day := base.Truncate(24 * time.Hour)
+
+	// Daily bars for three days before base, and for base's own day, which
+	// has a tick and so must not show up twice.
+	var bars []*models.PriceBar
+	for i := range 4 {
+		bars = append(bars, &models.PriceBar{
+			Symbol: "AAPL", Interval: models.BarDaily, Timestamp: day.AddDate(0, 0, i-3),
+			Open: 49 + float64(i), High: 60, Low: 40, Close: 50 + float64(i), Volume: 1000 * int64(i+1), Provider: "backfill",
+		})
+	}
+	bars = append(bars,
+		&models.PriceBar{Symbol: "AAPL", Interval: models.Bar1Hour, Timestamp: day.Add(-9 * time.Hour), Open: 1, High: 1, Low: 1, Close: 1},
+		&models.PriceBar{Symbol: "MSFT", Interval: models.BarDaily, Timestamp: day.AddDate(0, 0, -1), Open: 300, High: 310, Low: 290, Close: 305},
+	)
+	if err := repo.SaveBars(ctx, bars); err != nil {
+		t.Fatalf("SaveBars: %v", err)
+	}
+	aapl, _ := repo.GetStock(ctx, "AAPL")
+	savePrice(t, repo, aapl, base, 100, 10)
+
+	history, err := repo.GetPriceHistory(ctx, "AAPL", day.AddDate(0, 0, -3), base, 10)
+	if err != nil {
+		t.Fatalf("GetPriceHistory: %v", err)
+	}
+	want := []struct {
+		at    time.Time
+		price float64
+		tier  string
+	}{
+		{base, 100, ""},
+		{day.AddDate(0, 0, -1), 52, models.BarDaily},
+		{day.AddDate(0, 0, -2), 51, models.BarDaily},
+		{day.AddDate(0, 0, -3), 50, models.BarDaily},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("GetPriceHistory returned %v, want the tick and three daily closes", timestamps(history))
+	}
+	for i, p := range history {
+		if !p.Timestamp.Equal(want[i].at) || p.Price != want[i].price || p.Tier != want[i].tier {
+			t.Errorf("price %d = %v %v %q, want %v %v %q", i, p.Timestamp, p.Price, p.Tier, want[i].at, want[i].price, want[i].tier)
+		}
+	}
+	if p := history[1]; p.Symbol != "AAPL" || p.Volume != 3000 || p.Provider != "backfill" {
+		t.Errorf("backfilled price = %+v", p)
+	}
+
+	at, err := repo.GetPriceAt(ctx, "AAPL", day.Add(-time.Hour))
+	if err != nil || at.Price != 52 {
+		t.Errorf("GetPriceAt the day before = %+v, %v; want the close of 52", at, err)
+	}
+	avg, days, err := repo.GetAverageDailyVolume(ctx, "AAPL", 10, day)
+	if err != nil || days != 3 || avg != 2000 {
+		t.Errorf("GetAverageDailyVolume = %v over %d days, %v; want 2000 over 3", avg, days, err)
+	}
+	candles, err := repo.GetCandles(ctx, "AAPL", 24*time.Hour, day.AddDate(0, 0, -3), day)
+	if err != nil || len(candles) != 3 {
+		t.Fatalf("GetCandles = %v, %v; want a candle per backfilled day", candles, err)
+	}
+	if c := candles[0]; c.Open != 49 || c.High != 60 || c.Low != 40 || c.Close != 50 || c.Volume != 1000 {
+		t.Errorf("backfilled candle = %+v", *c)
+	}
+
+	stocks, err := repo.GetAllStocks(ctx)
+	if err != nil || len(stocks) != 2 {
+		t.Fatalf("GetAllStocks = %v, %v", stocks, err)
+	}
+	if stocks[0].CurrentPrice != 100 || stocks[1].ID != msft.ID || stocks[1].CurrentPrice != 305 {
+		t.Errorf("GetAllStocks prices = %v and %v, want the tick of 100 and the close of 305", stocks[0].CurrentPrice, stocks[1].CurrentPrice)
+	}
+}
+
+func testBackfillProgress(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	createStock(t, repo, "AAPL")
+
+	if progress, err := repo.GetBackfillProgress(ctx, "AAPL", models.BarDaily); progress != nil || err != nil {
+		t.Errorf("GetBackfillProgress before any = %v, %v; want nil, nil", progress, err)
+	}
+
+	from, to := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+	for _, end := range []time.Time{to.AddDate(0, 0, -10), to} {
+		progress := &models.BackfillProgress{Symbol: "AAPL", Interval: models.BarDaily, From: from, To: end}
+		if err := repo.SaveBackfillProgress(ctx, progress); err != nil {
+			t.Fatalf("SaveBackfillProgress: %v", err)
+		}
+		if progress.UpdatedAt.IsZero() {
+			t.Error("SaveBackfillProgress did not set UpdatedAt")
+		}
+	}
+
+	progress, err := repo.GetBackfillProgress(ctx, "AAPL", models.BarDaily)
+	if err != nil || progress == nil {
+		t.Fatalf("GetBackfillProgress = %v, %v", progress, err)
+	}
+	if progress.Symbol != "AAPL" || !progress.From.Equal(from) || !progress.To.Equal(to) {
+		t.Errorf("GetBackfillProgress = %+v, want %v to %v", *progress, from, to)
+	}
+
+	err = repo.SaveBackfillProgress(ctx, &models.BackfillProgress{Symbol: "NOPE", Interval: models.BarDaily, From: from, To: to})
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("SaveBackfillProgress for an unknown symbol = %v, want ErrNotFound", err)
+	}
+}
+
+func testProviderQuotas(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+
+	if quota, err := repo.GetProviderQuota(ctx, "test"); quota != nil || err != nil {
+		t.Errorf("GetProviderQuota before any = %v, %v; want nil, nil", quota, err)
+	}
+
+	day := time.Date(base.Year(), base.Month(), base.Day(), 0, 0, 0, 0, time.UTC)
+	quota := &models.ProviderQuota{Provider: "test", Tokens: 4.5, RefilledAt: base, Day: day, UsedToday: 1}
+	if err := repo.SaveProviderQuota(ctx, quota); err != nil {
+		t.Fatalf("SaveProviderQuota: %v", err)
+	}
+	if quota.Version != 1 {
+		t.Errorf("Version after first save = %d, want 1", quota.Version)
+	}
+
+	// A second limiter that also found no quota loses the race
+	err := repo.SaveProviderQuota(ctx, &models.ProviderQuota{Provider: "test", Tokens: 4, RefilledAt: base, Day: day, UsedToday: 1})
+	if !errors.Is(err, repository.ErrProviderQuotaChanged) || !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("SaveProviderQuota of an unread quota = %v, want ErrProviderQuotaChanged", err)
+	}
+
+	stale := *quota
+	quota.Tokens, quota.UsedToday, quota.RefilledAt = 3.5, 2, base.Add(time.Second)
+	if err := repo.SaveProviderQuota(ctx, quota); err != nil {
+		t.Fatalf("SaveProviderQuota: %v", err)
+	}
+	if err := repo.SaveProviderQuota(ctx, &stale); !errors.Is(err, repository.ErrProviderQuotaChanged) {
+		t.Errorf("SaveProviderQuota of a stale quota = %v, want ErrProviderQuotaChanged", err)
+	}
+
+	got, err := repo.GetProviderQuota(ctx, "test")
+	if err != nil || got == nil {
+		t.Fatalf("GetProviderQuota = %v, %v", got, err)
+	}
+	if got.Provider != "test" || got.Tokens != 3.5 || got.UsedToday != 2 || got.Version != 2 ||
+		!got.RefilledAt.Equal(base.Add(time.Second)) || !got.Day.Equal(day) {
+		t.Errorf("GetProviderQuota = %+v, want %+v", *got, *quota)
+	}
+
+	if other, err := repo.GetProviderQuota(ctx, "other"); other != nil || err != nil {
+		t.Errorf("GetProviderQuota of another provider = %v, %v; want nil, nil", other, err)
+	}
+}
+
+func saveAlert(t *testing.T, repo repository.StockRepository, stock *models.Stock, alertType, key string, at time.Time) *models.Alert {
+	t.Helper()
+	alert := &models.Alert{
+		StockID: stock.ID, AlertType: alertType, Threshold: 5, Message: key,
+		DedupKey: key, TriggeredAt: at, State: models.AlertOpen,
+	}
+	if err := repo.SaveAlert(context.Background(), alert); err != nil {
+		t.Fatalf("SaveAlert(%s): %v", key, err)
+	}
+	return alert
+}
+
+func testAlerts(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	aapl := createStock(t, repo, "AAPL")
+	msft := createStock(t, repo, "MSFT")
+
+	first := saveAlert(t, repo, aapl, "price_increase", "a1", base)
+	saveAlert(t, repo, aapl, "price_decrease", "a2", base.Add(time.Minute))
+	saveAlert(t, repo, msft, "price_increase", "m1", base.Add(2*time.Minute))
+
+	dup := &models.Alert{StockID: aapl.ID, AlertType: "price_increase", DedupKey: "a1", TriggeredAt: base, State: models.AlertOpen}
+	if err := repo.SaveAlert(ctx, dup); !errors.Is(err, repository.ErrDuplicateAlert) {
+		t.Errorf("SaveAlert with a taken dedup key = %v, want ErrDuplicateAlert", err)
+	}
+
+	// Alerts without a dedup key never conflict.
+	for range 2 {
+		saveAlert(t, repo, msft, "price_increase", "", base.Add(-time.Hour))
+	}
+
+	got, err := repo.GetAlert(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetAlert: %v", err)
+	}
+	if got.Symbol != "AAPL" || got.DedupKey != "a1" || got.State != models.AlertOpen || !got.TriggeredAt.Equal(base) {
+		t.Errorf("GetAlert = %+v", got)
+	}
+	if _, err := repo.GetAlert(ctx, first.ID+1000); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetAlert of an unknown ID = %v, want ErrNotFound", err)
+	}
+
+	for _, tt := range []struct {
+		filter repository.AlertFilter
+		want   []string
+	}{
+		{repository.AlertFilter{Limit: 10}, []string{"m1", "a2", "a1", "", ""}},
+		{repository.AlertFilter{Limit: 2}, []string{"m1", "a2"}},
+		{repository.AlertFilter{Symbol: "AAPL", Limit: 10}, []string{"a2", "a1"}},
+		{repository.AlertFilter{AlertType: "price_increase", Symbol: "AAPL", Limit: 10}, []string{"a1"}},
+		{repository.AlertFilter{From: base, To: base.Add(2 * time.Minute), Limit: 10}, []string{"a2", "a1"}},
+		{repository.AlertFilter{State: models.AlertResolved, Limit: 10}, nil},
+	} {
+		alerts, err := repo.ListAlerts(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("ListAlerts(%+v): %v", tt.filter, err)
+		}
+		var keys []string
+		for _, alert := range alerts {
+			keys = append(keys, alert.DedupKey)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(tt.want) {
+			t.Errorf("ListAlerts(%+v) = %v, want %v", tt.filter, keys, tt.want)
+		}
+	}
+}
+
+func testAlertState(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	stock := createStock(t, repo, "AAPL")
+	alert := saveAlert(t, repo, stock, "price_increase", "a1", base)
+
+	ack := &models.AlertStateChange{AlertID: alert.ID, FromState: models.AlertOpen, ToState: models.AlertAcknowledged, ChangedBy: "alice", Note: "looking"}
+	updated, err := repo.UpdateAlertState(ctx, ack)
+	if err != nil {
+		t.Fatalf("UpdateAlertState: %v", err)
+	}
+	if updated.State != models.AlertAcknowledged || updated.StateChangedBy != "alice" || updated.StateChangedAt == nil {
+		t.Errorf("UpdateAlertState returned %+v", updated)
+	}
+	if ack.ID == 0 || ack.ChangedAt.IsZero() {
+		t.Errorf("UpdateAlertState did not record the change: %+v", ack)
+	}
+
+	stale := &models.AlertStateChange{AlertID: alert.ID, FromState: models.AlertOpen, ToState: models.AlertResolved, ChangedBy: "bob"}
+	if _, err := repo.UpdateAlertState(ctx, stale); !errors.Is(err, repository.ErrAlertStateChanged) {
+		t.Errorf("UpdateAlertState from a stale state = %v, want ErrAlertStateChanged", err)
+	}
+
+	// A snooze that has run out reads as open.
+	until := time.Now().Add(-time.Minute)
+	snooze := &models.AlertStateChange{AlertID: alert.ID, FromState: models.AlertAcknowledged, ToState: models.AlertSnoozed, ChangedBy: "alice", SnoozedUntil: &until}
+	if _, err := repo.UpdateAlertState(ctx, snooze); err != nil {
+		t.Fatalf("UpdateAlertState to snoozed: %v", err)
+	}
+	got, _ := repo.GetAlert(ctx, alert.ID)
+	if got.State != models.AlertOpen || got.SnoozedUntil == nil {
+		t.Errorf("alert with an expired snooze is %s until %v, want open", got.State, got.SnoozedUntil)
+	}
+	if open, _ := repo.ListAlerts(ctx, repository.AlertFilter{State: models.AlertOpen, Limit: 10}); len(open) != 1 {
+		t.Errorf("ListAlerts(open) returned %d alerts, want the expired snooze", len(open))
+	}
+	reopen := &models.AlertStateChange{AlertID: alert.ID, FromState: models.AlertOpen, ToState: models.AlertResolved, ChangedBy: "alice"}
+	if _, err := repo.UpdateAlertState(ctx, reopen); err != nil {
+		t.Errorf("UpdateAlertState from an expired snooze: %v", err)
+	}
+
+	changes, err := repo.GetAlertStateChanges(ctx, alert.ID)
+	if err != nil {
+		t.Fatalf("GetAlertStateChanges: %v", err)
+	}
+	var path []string
+	for _, change := range changes {
+		path = append(path, change.FromState+">"+change.ToState)
+	}
+	if want := "[open>acknowledged acknowledged>snoozed open>resolved]"; fmt.Sprint(path) != want {
+		t.Errorf("GetAlertStateChanges = %v, want %s", path, want)
+	}
+	if changes[0].Note != "looking" || changes[1].SnoozedUntil == nil {
+		t.Errorf("state changes lost their note or snooze: %+v, %+v", changes[0], changes[1])
+	}
+
+	missing := &models.AlertStateChange{AlertID: alert.ID + 1000, FromState: models.AlertOpen, ToState: models.AlertResolved, ChangedBy: "bob"}
+	if _, err := repo.UpdateAlertState(ctx, missing); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateAlertState of an unknown alert = %v, want ErrNotFound", err)
+	}
+}
+
+func testAlertRules(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	stock := createStock(t, repo, "AAPL")
+	createStock(t, repo, "MSFT")
+
+	rule := &models.AlertRule{
+		Symbol: "AAPL", RuleType: models.RuleSMACross, CooldownSeconds: 60, Enabled: true,
+		Params: models.RuleParams{Fast: 5, Slow: 20, Direction: models.DirectionAbove},
+	}
+	if err := repo.CreateAlertRule(ctx, rule); err != nil {
+		t.Fatalf("CreateAlertRule: %v", err)
+	}
+	if rule.ID == 0 || rule.StockID != stock.ID || !rule.State.Armed {
+		t.Errorf("CreateAlertRule left %+v", rule)
+	}
+	second := &models.AlertRule{Symbol: "AAPL", RuleType: models.RuleExpression, Expression: "price > 1", Enabled: true}
+	if err := repo.CreateAlertRule(ctx, second); err != nil {
+		t.Fatalf("CreateAlertRule: %v", err)
+	}
+	if err := repo.CreateAlertRule(ctx, &models.AlertRule{Symbol: "NOPE", RuleType: models.RulePriceAbove, Threshold: 1}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("CreateAlertRule for an unknown symbol = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetAlertRules(ctx, "NOPE"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetAlertRules of an unknown symbol = %v, want ErrNotFound", err)
+	}
+
+	rules, err := repo.GetAlertRules(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("GetAlertRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].ID != rule.ID || rules[1].Expression != "price > 1" {
+		t.Fatalf("GetAlertRules = %v", rules)
+	}
+	if got := rules[0]; got.Params != rule.Params || got.Symbol != "AAPL" || got.CooldownSeconds != 60 || !got.State.Armed {
+		t.Errorf("GetAlertRules returned %+v", got)
+	}
+	if rules, _ := repo.GetAlertRules(ctx, "MSFT"); len(rules) != 0 {
+		t.Errorf("GetAlertRules(MSFT) = %v", rules)
+	}
+
+	rule.Enabled, rule.CooldownSeconds = false, 120
+	if err := repo.UpdateAlertRule(ctx, rule); err != nil {
+		t.Fatalf("UpdateAlertRule: %v", err)
+	}
+	if err := repo.UpdateAlertRule(ctx, &models.AlertRule{ID: second.ID + 1000}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateAlertRule of an unknown rule = %v, want ErrNotFound", err)
+	}
+
+	fired := base
+	if err := repo.SaveAlertRuleState(ctx, rule.ID, &models.AlertRuleState{Armed: false, Episode: 3, LastFiredAt: &fired}); err != nil {
+		t.Fatalf("SaveAlertRuleState: %v", err)
+	}
+	got, err := repo.GetAlertRule(ctx, rule.ID)
+	if err != nil {
+		t.Fatalf("GetAlertRule: %v", err)
+	}
+	if got.Enabled || got.CooldownSeconds != 120 || got.State.Armed || got.State.Episode != 3 ||
+		got.State.LastFiredAt == nil || !got.State.LastFiredAt.Equal(fired) {
+		t.Errorf("GetAlertRule after update = %+v", got)
+	}
+
+	// Deleting a rule keeps the alerts it fired.
+	alert := &models.Alert{StockID: stock.ID, RuleID: &rule.ID, AlertType: rule.RuleType, DedupKey: "r1", TriggeredAt: base, State: models.AlertOpen}
+	if err := repo.SaveAlert(ctx, alert); err != nil {
+		t.Fatalf("SaveAlert: %v", err)
+	}
+	if err := repo.DeleteAlertRule(ctx, rule.ID); err != nil {
+		t.Fatalf("DeleteAlertRule: %v", err)
+	}
+	if _, err := repo.GetAlertRule(ctx, rule.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetAlertRule of the deleted rule = %v, want ErrNotFound", err)
+	}
+	kept, err := repo.GetAlert(ctx, alert.ID)
+	if err != nil || kept.RuleID != nil {
+		t.Errorf("alert of the deleted rule = %+v, %v; want it kept without a rule", kept, err)
+	}
+}
+
+func testNotificationChannels(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	stock := createStock(t, repo, "AAPL")
+
+	newChannel := func(name string, isDefault bool) *models.NotificationChannel {
+		t.Helper()
+		channel := &models.NotificationChannel{
+			Name: name, Type: models.ChannelWebhook, Default: isDefault, Enabled: true,
+			Config: json.RawMessage(`{"url": "https://example.com/` + name + `"}`),
+		}
+		if err := repo.CreateNotificationChannel(ctx, channel); err != nil {
+			t.Fatalf("CreateNotificationChannel(%s): %v", name, err)
+		}
+		return channel
+	}
+	ops := newChannel("ops", true)
+	dev := newChannel("dev", false)
+	audit := newChannel("audit", true)
+
+	if err := repo.CreateNotificationChannel(ctx, &models.NotificationChannel{Name: "ops", Type: models.ChannelWebhook, Config: json.RawMessage(`{}`)}); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("CreateNotificationChannel with a taken name = %v, want ErrConflict", err)
+	}
+
+	got, err := repo.GetNotificationChannel(ctx, ops.ID)
+	if err != nil {
+		t.Fatalf("GetNotificationChannel: %v", err)
+	}
+	var config struct{ URL string }
+	if err := json.Unmarshal(got.Config, &config); err != nil || config.URL != "https://example.com/ops" {
+		t.Errorf("GetNotificationChannel config = %s, %v", got.Config, err)
+	}
+
+	names := func(channels []*models.NotificationChannel, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("listing channels: %v", err)
+		}
+		var names []string
+		for _, c := range channels {
+			names = append(names, c.Name)
+		}
+		return fmt.Sprint(names)
+	}
+	if got := names(repo.GetNotificationChannels(ctx)); got != "[ops dev audit]" {
+		t.Errorf("GetNotificationChannels = %s, want them by ID", got)
+	}
+	if got := names(repo.GetDefaultChannels(ctx)); got != "[ops audit]" {
+		t.Errorf("GetDefaultChannels = %s", got)
+	}
+
+	dev.Name, dev.Default = "developers", true
+	if err := repo.UpdateNotificationChannel(ctx, dev); err != nil {
+		t.Fatalf("UpdateNotificationChannel: %v", err)
+	}
+	dev.Name = "ops"
+	if err := repo.UpdateNotificationChannel(ctx, dev); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("UpdateNotificationChannel to a taken name = %v, want ErrConflict", err)
+	}
+	if got := names(repo.GetDefaultChannels(ctx)); got != "[ops developers audit]" {
+		t.Errorf("GetDefaultChannels after update = %s", got)
+	}
+
+	rule := &models.AlertRule{Symbol: "AAPL", RuleType: models.RulePriceAbove, Threshold: 100, Enabled: true}
+	if err := repo.CreateAlertRule(ctx, rule); err != nil {
+		t.Fatalf("CreateAlertRule: %v", err)
+	}
+	if err := repo.SetRuleChannels(ctx, rule.ID, []int{audit.ID, ops.ID, ops.ID}); err != nil {
+		t.Fatalf("SetRuleChannels: %v", err)
+	}
+	if got := names(repo.GetRuleChannels(ctx, rule.ID)); got != "[ops audit]" {
+		t.Errorf("GetRuleChannels = %s", got)
+	}
+	if err := repo.SetRuleChannels(ctx, rule.ID, []int{dev.ID}); err != nil {
+		t.Fatalf("SetRuleChannels: %v", err)
+	}
+	if got := names(repo.GetRuleChannels(ctx, rule.ID)); got != "[developers]" {
+		t.Errorf("GetRuleChannels after replacing = %s", got)
+	}
+	if err := repo.SetRuleChannels(ctx, rule.ID, []int{audit.ID + 1000}); !isValidation(err) {
+		t.Errorf("SetRuleChannels with an unknown channel = %v, want a ValidationError", err)
+	}
+	if got := names(repo.GetRuleChannels(ctx, rule.ID)); got != "[developers]" {
+		t.Errorf("a failed SetRuleChannels changed the channels to %s", got)
+	}
+
+	alert := saveAlert(t, repo, stock, rule.RuleType, "n1", base)
+	for attempt, status := range []string{models.DeliveryFailed, models.DeliveryDelivered} {
+		delivery := &models.NotificationDelivery{AlertID: alert.ID, ChannelID: dev.ID, Attempt: attempt + 1, Status: status}
+		if err := repo.SaveDelivery(ctx, delivery); err != nil {
+			t.Fatalf("SaveDelivery: %v", err)
+		}
+		if delivery.ID == 0 || delivery.CreatedAt.IsZero() {
+			t.Errorf("SaveDelivery left %+v", delivery)
+		}
+	}
+
+	// Deleting a channel unroutes it but keeps its delivery history.
+	if err := repo.DeleteNotificationChannel(ctx, dev.ID); err != nil {
+		t.Fatalf("DeleteNotificationChannel: %v", err)
+	}
+	if got := names(repo.GetRuleChannels(ctx, rule.ID)); got != "[]" {
+		t.Errorf("GetRuleChannels after deleting the channel = %s", got)
+	}
+	deliveries, err := repo.GetDeliveries(ctx, alert.ID)
+	if err != nil {
+		t.Fatalf("GetDeliveries: %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].Attempt != 1 || deliveries[1].Status != models.DeliveryDelivered || deliveries[0].ChannelID != 0 {
+		t.Errorf("GetDeliveries = %+v, %+v", deliveries[0], deliveries[1])
+	}
+}
+
+func testConcurrency(t *testing.T, repo repository.StockRepository) {
+	ctx := context.Background()
+	stock := createStock(t, repo, "AAPL")
+
+	const workers, perWorker = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				at := base.Add(time.Duration(w*perWorker+i) * time.Second)
+				if err := repo.SavePrice(ctx, &models.StockPrice{StockID: stock.ID, Price: 1, Timestamp: at}); err != nil {
+					errs <- err
+				}
+				if _, err := repo.GetLatestPrice(ctx, "AAPL"); err != nil {
+					errs <- err
+				}
+				if err := repo.CreateStock(ctx, models.NewStock("AAPL")); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	history, err := repo.GetPriceHistory(ctx, "AAPL", base, base.Add(time.Hour), 1000)
+	if err != nil {
+		t.Fatalf("GetPriceHistory: %v", err)
+	}
+	ids := make(map[int64]bool)
+	for _, p := range history {
+		ids[p.ID] = true
+	}
+	if len(history) != workers*perWorker || len(ids) != len(history) {
+		t.Errorf("%d concurrent saves stored %d prices with %d distinct IDs", workers*perWorker, len(history), len(ids))
+	}
+	if stocks, _ := repo.GetAllStocks(ctx); len(stocks) != 1 {
+		t.Errorf("concurrent CreateStock calls made %d stocks", len(stocks))
+	}
+}
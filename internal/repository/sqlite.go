@@ -0,0 +1,1460 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"stock-tracker/internal/migrate"
+	"stock-tracker/internal/models"
+	"stock-tracker/migrations"
+	"stock-tracker/pkg/logger"
+)
+
+// SQLiteRepository is a StockRepository in a SQLite file, for deployments
+// too small to warrant PostgreSQL. It follows PostgresRepository's
+// semantics and has its own migrations, in migrations/sqlite. Processes
+// sharing the file take turns writing.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// sqliteDefaults are the connection settings NewSQLiteRepository adds
+// unless the path sets them: foreign keys for the cascades, WAL so readers
+// don't block the writer, and IMMEDIATE transactions so two writers wait
+// for each other instead of failing.
+var sqliteDefaults = [][2]string{
+	{"_foreign_keys", "on"},
+	{"_busy_timeout", "5000"},
+	{"_journal_mode", "WAL"},
+	{"_txlock", "immediate"},
+}
+
+// NewSQLiteRepository opens the database file at path, which may carry
+// go-sqlite3 connection parameters, e.g. "tracker.db?_busy_timeout=10000".
+func NewSQLiteRepository(path string) (*SQLiteRepository, error) {
+	db, file, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like PostgreSQL, the file is migrated by "tracker migrate up" or
+	// AUTO_MIGRATE, not here
+	ms, err := migrate.Load(migrations.SQLite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrate.New(db, migrate.SQLite, ms).Check(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logger.Info().Str("path", file).Msg("Successfully opened SQLite database")
+
+	return &SQLiteRepository{db: db}, nil
+}
+
+// openSQLite opens path with sqliteDefaults and returns the file name.
+func openSQLite(path string) (*sql.DB, string, error) {
+	file, query, _ := strings.Cut(path, "?")
+	params := strings.Split(query, "&")
+	if query == "" {
+		params = nil
+	}
+	for _, param := range sqliteDefaults {
+		if !strings.Contains(query, param[0]+"=") {
+			params = append(params, param[0]+"="+param[1])
+		}
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+file+"?"+strings.Join(params, "&"))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open database: %w", sqliteError(err))
+	}
+	return db, file, nil
+}
+
+func (r *SQLiteRepository) Close() {
+	r.db.Close()
+}
+
+// sqliteNow is NOW() for the microsecond timestamp columns.
+const sqliteNow = `CAST(unixepoch('subsec') * 1000000 AS INTEGER)`
+
+// microsPerDay turns a timestamp column into a day number, like ::date.
+const microsPerDay = 86400000000
+
+// sqlitePriceSeries is priceSeries for microsecond timestamps.
+var sqlitePriceSeries = buildPriceSeries(fmt.Sprintf("pb.timestamp + %d", microsPerDay))
+
+// sqliteStockID looks up the stock of the symbol bound to ?1. Comparing
+// stock_id with it, rather than joining stocks, lets SQLite push the
+// condition into every part of priceSeries and use their indexes.
+const sqliteStockID = `(SELECT id FROM stocks WHERE symbol = ?1)`
+
+// sqliteBin is date_bin(stride, column, TIMESTAMP '2000-01-01') for
+// microsecond columns.
+func sqliteBin(column, stride string) string {
+	offset := fmt.Sprintf("((%s - %d) %% %s + %s) %% %s", column, bucketOrigin.UnixMicro(), stride, stride, stride)
+	return "(" + column + " - " + offset + ")"
+}
+
+// toMicros stores t the way PostgresRepository stores a TIMESTAMP: the
+// wall clock without its time zone, to the microsecond.
+func toMicros(t time.Time) int64 {
+	return dbTime(t).UnixMicro()
+}
+
+func toNullMicros(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return toMicros(*t)
+}
+
+// sqliteTime scans a timestamp column.
+type sqliteTime struct{ t *time.Time }
+
+func (m sqliteTime) Scan(src interface{}) error {
+	us, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("timestamp column holds %T, not microseconds", src)
+	}
+	*m.t = time.UnixMicro(us).UTC()
+	return nil
+}
+
+// sqliteNullTime scans a nullable timestamp column.
+type sqliteNullTime struct{ t **time.Time }
+
+func (m sqliteNullTime) Scan(src interface{}) error {
+	if src == nil {
+		*m.t = nil
+		return nil
+	}
+	t := new(time.Time)
+	if err := (sqliteTime{t}).Scan(src); err != nil {
+		return err
+	}
+	*m.t = t
+	return nil
+}
+
+const dateLayout = "2006-01-02"
+
+// sqliteDate scans a date column.
+type sqliteDate struct{ t *time.Time }
+
+func (d sqliteDate) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("date column holds %T, not text", src)
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return err
+	}
+	*d.t = t
+	return nil
+}
+
+// sqliteError wraps err in the repository error it amounts to, keeping it
+// in the chain, or returns it as is.
+func sqliteError(err error) error {
+	if translated(err) {
+		return err
+	}
+
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code {
+		case sqlite3.ErrConstraint:
+			if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+				return fmt.Errorf("%w: %w", ErrConflict, err)
+			}
+			return &ValidationError{Message: sqliteErr.Error(), Err: err}
+		// Still locked after the busy timeout, or the file is unusable
+		case sqlite3.ErrBusy, sqlite3.ErrLocked, sqlite3.ErrCantOpen, sqlite3.ErrIoErr, sqlite3.ErrFull:
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+	case errors.Is(err, sql.ErrConnDone):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// requireStock is PostgresRepository.requireStock.
+func (r *SQLiteRepository) requireStock(ctx context.Context, symbol string) error {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM stocks WHERE symbol = ?1)`, symbol).Scan(&exists)
+	if err != nil {
+		return sqliteError(err)
+	}
+	if !exists {
+		return fmt.Errorf("stock %s: %w", symbol, ErrNotFound)
+	}
+	return nil
+}
+
+// sqliteLimit rejects the negative limits that SQLite, unlike PostgreSQL,
+// would take as no limit.
+func sqliteLimit(limit int) error {
+	if limit < 0 {
+		return &ValidationError{Message: "LIMIT must not be negative"}
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) CreateStock(ctx context.Context, stock *models.Stock) error {
+	query := `
+		INSERT INTO stocks (symbol, name, active, created_at, updated_at)
+		VALUES (?1, ?2, ?3, ` + sqliteNow + `, ` + sqliteNow + `)
+		ON CONFLICT (symbol) DO UPDATE SET updated_at = ` + sqliteNow + `
+		RETURNING id, active, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, stock.Symbol, stock.Name, stock.Active).
+		Scan(&stock.ID, &stock.Active, sqliteTime{&stock.CreatedAt}, sqliteTime{&stock.UpdatedAt})
+
+	if err != nil {
+		return fmt.Errorf("failed to create stock: %w", sqliteError(err))
+	}
+
+	logger.Debug().
+		Str("symbol", stock.Symbol).
+		Int("id", stock.ID).
+		Msg("Stock created in database")
+
+	return nil
+}
+
+func (r *SQLiteRepository) GetStock(ctx context.Context, symbol string) (*models.Stock, error) {
+	query := `
+		SELECT id, symbol, COALESCE(name, ''), active, created_at, updated_at
+		FROM stocks
+		WHERE symbol = ?1
+	`
+
+	stock := &models.Stock{}
+	err := r.db.QueryRowContext(ctx, query, symbol).Scan(
+		&stock.ID, &stock.Symbol, &stock.Name, &stock.Active,
+		sqliteTime{&stock.CreatedAt}, sqliteTime{&stock.UpdatedAt},
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock: %w", sqliteError(err))
+	}
+
+	return stock, nil
+}
+
+func (r *SQLiteRepository) GetAllStocks(ctx context.Context) ([]*models.Stock, error) {
+	// SQLite has no LATERAL, so each tier's latest row is looked up per
+	// stock through its index, and the newest wins. A daily bar starts its
+	// day, so any price of the same day is at least as new and wins a tie.
+	query := `
+		WITH latest AS (
+			SELECT stock_id, price, change_percent, volume, provider, timestamp,
+			       ROW_NUMBER() OVER (PARTITION BY stock_id ORDER BY timestamp DESC, bar) AS rn
+			FROM (
+				SELECT p.stock_id, p.price, p.change_percent, p.volume, p.provider, p.timestamp, 0 AS bar
+				FROM stocks s
+				JOIN stock_prices p ON p.id = (
+					SELECT id FROM stock_prices WHERE stock_id = s.id ORDER BY timestamp DESC LIMIT 1
+				)
+				UNION ALL
+				SELECT pr.stock_id, pr.close, 0, pr.volume, NULL, pr.timestamp, 0
+				FROM stocks s
+				JOIN price_rollups pr ON pr.rowid = (
+					SELECT rowid FROM price_rollups WHERE stock_id = s.id ORDER BY timestamp DESC LIMIT 1
+				)
+				UNION ALL
+				SELECT pb.stock_id, pb.close, 0, pb.volume, pb.provider, pb.timestamp, 1
+				FROM stocks s
+				JOIN price_bars pb ON pb.rowid = (
+					SELECT rowid FROM price_bars WHERE stock_id = s.id AND interval = '` + models.BarDaily + `'
+					ORDER BY timestamp DESC LIMIT 1
+				)
+			)
+		)
+		SELECT s.id, s.symbol, COALESCE(s.name, ''), s.active, s.created_at, s.updated_at,
+		       sp.price, sp.change_percent, sp.volume, sp.provider, sp.timestamp
+		FROM stocks s
+		LEFT JOIN latest sp ON sp.stock_id = s.id AND sp.rn = 1
+		ORDER BY s.symbol
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all stocks: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var stocks []*models.Stock
+	for rows.Next() {
+		stock := &models.Stock{}
+		var price, changePercent *float64
+		var volume *int64
+		var provider *string
+		var timestamp *time.Time
+
+		err := rows.Scan(
+			&stock.ID, &stock.Symbol, &stock.Name, &stock.Active,
+			sqliteTime{&stock.CreatedAt}, sqliteTime{&stock.UpdatedAt},
+			&price, &changePercent, &volume, &provider, sqliteNullTime{&timestamp},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %w", sqliteError(err))
+		}
+
+		if price != nil {
+			stock.CurrentPrice = *price
+		}
+		if changePercent != nil {
+			stock.ChangePercent = *changePercent
+		}
+		if volume != nil {
+			stock.Volume = *volume
+		}
+		if provider != nil {
+			stock.Provider = *provider
+		}
+		if timestamp != nil {
+			stock.LastUpdated = *timestamp
+		}
+
+		stocks = append(stocks, stock)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get all stocks: %w", sqliteError(err))
+	}
+
+	return stocks, nil
+}
+
+func (r *SQLiteRepository) UpdateStock(ctx context.Context, stock *models.Stock) error {
+	query := `
+		UPDATE stocks
+		SET name = ?1, active = ?2, updated_at = ` + sqliteNow + `
+		WHERE symbol = ?3
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, stock.Name, stock.Active, stock.Symbol).
+		Scan(&stock.ID, sqliteTime{&stock.CreatedAt}, sqliteTime{&stock.UpdatedAt})
+
+	if err != nil {
+		return fmt.Errorf("failed to update stock: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) DeleteStock(ctx context.Context, symbol string) error {
+	query := `DELETE FROM stocks WHERE symbol = ?1`
+
+	_, err := r.db.ExecContext(ctx, query, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to delete stock: %w", sqliteError(err))
+	}
+
+	logger.Info().Str("symbol", symbol).Msg("Stock deleted from database")
+	return nil
+}
+
+func (r *SQLiteRepository) SavePrice(ctx context.Context, price *models.StockPrice) error {
+	query := `
+		INSERT INTO stock_prices (stock_id, price, change_percent, volume, provider, session, timestamp)
+		VALUES (?1, ?2, ?3, ?4, NULLIF(?5, ''), NULLIF(?6, ''), ?7)
+		RETURNING id
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		price.StockID, price.Price, price.ChangePercent,
+		price.Volume, price.Provider, price.Session, toMicros(price.Timestamp),
+	).Scan(&price.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to save price: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) SavePrices(ctx context.Context, prices []*models.StockPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	// An unknown symbol leaves stock_id NULL, which the NOT NULL
+	// constraint rejects
+	query := `
+		INSERT INTO stock_prices (stock_id, price, change_percent, volume, provider, session, timestamp)
+		VALUES (COALESCE(NULLIF(?1, 0), (SELECT id FROM stocks WHERE symbol = ?8)),
+		        ?2, ?3, ?4, NULLIF(?5, ''), NULLIF(?6, ''), ?7)
+	`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to save prices: %w", sqliteError(err))
+	}
+	defer stmt.Close()
+
+	for _, price := range prices {
+		_, err := stmt.ExecContext(ctx,
+			price.StockID, price.Price, price.ChangePercent,
+			price.Volume, price.Provider, price.Session, toMicros(price.Timestamp), price.Symbol,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save prices: %w", sqliteError(err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit prices: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+const sqlitePriceColumns = `
+	sp.id, sp.stock_id, s.symbol, sp.price, sp.change_percent, sp.volume,
+	COALESCE(sp.provider, ''), COALESCE(sp.session, ''), sp.tier, sp.timestamp`
+
+func scanSQLitePrice(row interface{ Scan(...interface{}) error }) (*models.StockPrice, error) {
+	price := &models.StockPrice{}
+	err := row.Scan(
+		&price.ID, &price.StockID, &price.Symbol,
+		&price.Price, &price.ChangePercent, &price.Volume,
+		&price.Provider, &price.Session, &price.Tier, sqliteTime{&price.Timestamp},
+	)
+	return price, err
+}
+
+func (r *SQLiteRepository) GetPriceHistory(ctx context.Context, symbol string, from, to time.Time, limit int) ([]*models.StockPrice, error) {
+	if err := sqliteLimit(limit); err != nil {
+		return nil, fmt.Errorf("failed to get price history: %w", sqliteError(err))
+	}
+
+	query := `
+		SELECT ` + sqlitePriceColumns + `
+		FROM ` + sqlitePriceSeries + ` sp
+		JOIN stocks s ON s.id = sp.stock_id
+		WHERE sp.stock_id = ` + sqliteStockID + ` AND sp.timestamp BETWEEN ?2 AND ?3
+		ORDER BY sp.timestamp DESC
+		LIMIT ?4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, symbol, toMicros(from), toMicros(to), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price history: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var prices []*models.StockPrice
+	for rows.Next() {
+		price, err := scanSQLitePrice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price: %w", sqliteError(err))
+		}
+		prices = append(prices, price)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get price history: %w", sqliteError(err))
+	}
+
+	if len(prices) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get price history: %w", sqliteError(err))
+		}
+	}
+
+	return prices, nil
+}
+
+func (r *SQLiteRepository) GetLatestPrice(ctx context.Context, symbol string) (*models.StockPrice, error) {
+	query := `
+		SELECT ` + sqlitePriceColumns + `
+		FROM ` + sqlitePriceSeries + ` sp
+		JOIN stocks s ON s.id = sp.stock_id
+		WHERE sp.stock_id = ` + sqliteStockID + `
+		ORDER BY sp.timestamp DESC
+		LIMIT 1
+	`
+
+	price, err := scanSQLitePrice(r.db.QueryRowContext(ctx, query, symbol))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest price: %w", sqliteError(err))
+	}
+
+	return price, nil
+}
+
+func (r *SQLiteRepository) GetPriceAt(ctx context.Context, symbol string, at time.Time) (*models.StockPrice, error) {
+	query := `
+		SELECT ` + sqlitePriceColumns + `
+		FROM ` + sqlitePriceSeries + ` sp
+		JOIN stocks s ON s.id = sp.stock_id
+		WHERE sp.stock_id = ` + sqliteStockID + ` AND sp.timestamp <= ?2
+		ORDER BY sp.timestamp DESC
+		LIMIT 1
+	`
+
+	price, err := scanSQLitePrice(r.db.QueryRowContext(ctx, query, symbol, toMicros(at)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price at %s: %w", at.Format(time.RFC3339), sqliteError(err))
+	}
+
+	return price, nil
+}
+
+func (r *SQLiteRepository) GetAverageDailyVolume(ctx context.Context, symbol string, days int, before time.Time) (float64, int, error) {
+	// A quote's volume is cumulative for its day, so the day's largest
+	// value is its final volume.
+	query := fmt.Sprintf(`
+		SELECT COALESCE(AVG(day_volume), 0), COUNT(*)
+		FROM (
+			SELECT MAX(sp.volume) AS day_volume
+			FROM %s sp
+			WHERE sp.stock_id = %s AND sp.timestamp < ?2 AND sp.volume > 0
+			GROUP BY sp.timestamp / %d
+			ORDER BY sp.timestamp / %d DESC
+			LIMIT ?3
+		) d
+	`, sqlitePriceSeries, sqliteStockID, microsPerDay, microsPerDay)
+
+	var avg float64
+	var count int
+	err := r.db.QueryRowContext(ctx, query, symbol, toMicros(before), days).Scan(&avg, &count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get average daily volume: %w", sqliteError(err))
+	}
+
+	return avg, count, nil
+}
+
+func (r *SQLiteRepository) GetCandles(ctx context.Context, symbol string, interval time.Duration, from, to time.Time) ([]*models.Candle, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("failed to get candles: %w", &ValidationError{Message: "stride must be greater than zero"})
+	}
+
+	// The same computation as PostgresRepository.GetCandles, with window
+	// functions standing in for the ordered array_agg.
+	query := fmt.Sprintf(`
+		WITH lagged AS (
+			SELECT sp.timestamp AS ts, sp.open, sp.high, sp.low, sp.price AS close,
+			       COALESCE(sp.volume, 0) AS volume, sp.count,
+			       LAG(sp.timestamp) OVER (ORDER BY sp.timestamp) AS prev_ts,
+			       LAG(COALESCE(sp.volume, 0)) OVER (ORDER BY sp.timestamp) AS prev_volume
+			FROM %[1]s sp
+			WHERE sp.stock_id = %[2]s AND sp.timestamp >= ?2 - %[3]d AND sp.timestamp < ?3
+		),
+		deltas AS (
+			SELECT ts, %[4]s AS bucket, open, high, low, close, count,
+			       CASE
+			           WHEN prev_ts IS NULL OR prev_ts / %[3]d <> ts / %[3]d OR volume < prev_volume THEN volume
+			           ELSE volume - prev_volume
+			       END AS volume
+			FROM lagged
+			WHERE ts >= ?2
+		),
+		ends AS (
+			SELECT bucket, high, low, volume, count,
+			       FIRST_VALUE(open) OVER (PARTITION BY bucket ORDER BY ts) AS first_open,
+			       FIRST_VALUE(close) OVER (PARTITION BY bucket ORDER BY ts DESC) AS last_close
+			FROM deltas
+		)
+		SELECT bucket, MAX(first_open), MAX(high), MIN(low), MAX(last_close), SUM(volume), SUM(count)
+		FROM ends
+		GROUP BY bucket
+		ORDER BY bucket
+	`, sqlitePriceSeries, sqliteStockID, microsPerDay, sqliteBin("ts", "?4"))
+
+	rows, err := r.db.QueryContext(ctx, query, symbol, toMicros(from), toMicros(to), interval.Microseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var candles []*models.Candle
+	for rows.Next() {
+		candle := &models.Candle{}
+		err := rows.Scan(
+			sqliteTime{&candle.Timestamp}, &candle.Open, &candle.High, &candle.Low, &candle.Close,
+			&candle.Volume, &candle.Count,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan candle: %w", sqliteError(err))
+		}
+		candles = append(candles, candle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get candles: %w", sqliteError(err))
+	}
+
+	if len(candles) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get candles: %w", sqliteError(err))
+		}
+	}
+
+	return candles, nil
+}
+
+func (r *SQLiteRepository) CompactPrices(ctx context.Context, tier, into string, before time.Time) (int64, int64, error) {
+	// SQLite can't read the rows a DELETE returns, so the rollups are
+	// written first and the rows deleted after, in one transaction.
+	source := `
+		SELECT stock_id, timestamp, price AS open, price AS high, price AS low, price AS close,
+		       COALESCE(volume, 0) AS volume, 1 AS count
+		FROM stock_prices WHERE timestamp < ?1
+	`
+	remove := `DELETE FROM stock_prices WHERE timestamp < ?1`
+	if tier != models.TierRaw {
+		source = `
+		SELECT stock_id, timestamp, open, high, low, close, volume, count
+		FROM price_rollups WHERE interval = ?2 AND timestamp < ?1
+	`
+		remove = `DELETE FROM price_rollups WHERE interval = ?2 AND timestamp < ?1`
+	}
+
+	var length time.Duration
+	if into != "" {
+		var ok bool
+		if length, ok = models.BarDuration(into); !ok {
+			return 0, 0, &ValidationError{Message: fmt.Sprintf("unknown rollup interval %q", into)}
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	var compacted int64
+	if into != "" {
+		// volume is cumulative for the day, so a rollup keeps its last one. A
+		// bucket only conflicts when ticks arrive after it was rolled up.
+		query := fmt.Sprintf(`
+			INSERT INTO price_rollups (stock_id, interval, timestamp, open, high, low, close, volume, count)
+			SELECT stock_id, ?3, bucket, MAX(first_open), MAX(high), MIN(low), MAX(last_close), MAX(last_volume), SUM(count)
+			FROM (
+				SELECT stock_id, bucket, high, low, count,
+				       FIRST_VALUE(open) OVER (PARTITION BY stock_id, bucket ORDER BY timestamp) AS first_open,
+				       FIRST_VALUE(close) OVER (PARTITION BY stock_id, bucket ORDER BY timestamp DESC) AS last_close,
+				       FIRST_VALUE(volume) OVER (PARTITION BY stock_id, bucket ORDER BY timestamp DESC) AS last_volume
+				FROM (SELECT *, %s AS bucket FROM (%s))
+			)
+			WHERE true
+			GROUP BY stock_id, bucket
+			ON CONFLICT (stock_id, interval, timestamp) DO UPDATE SET
+				high = MAX(price_rollups.high, excluded.high),
+				low = MIN(price_rollups.low, excluded.low),
+				close = excluded.close,
+				volume = MAX(price_rollups.volume, excluded.volume),
+				count = price_rollups.count + excluded.count
+		`, sqliteBin("timestamp", "?4"), source)
+
+		result, err := tx.ExecContext(ctx, query, toMicros(before), tier, into, length.Microseconds())
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to compact %s prices into %s: %w", tier, into, sqliteError(err))
+		}
+		if compacted, err = result.RowsAffected(); err != nil {
+			return 0, 0, fmt.Errorf("failed to compact %s prices into %s: %w", tier, into, sqliteError(err))
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, remove, toMicros(before), tier)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete %s prices: %w", tier, sqliteError(err))
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete %s prices: %w", tier, sqliteError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit compaction of %s prices: %w", tier, sqliteError(err))
+	}
+
+	return compacted, deleted, nil
+}
+
+func (r *SQLiteRepository) SaveBars(ctx context.Context, bars []*models.PriceBar) error {
+	if len(bars) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO price_bars (stock_id, interval, timestamp, open, high, low, close, volume, provider)
+		SELECT s.id, ?2, ?3, ?4, ?5, ?6, ?7, ?8, NULLIF(?9, '')
+		FROM stocks s
+		WHERE s.symbol = ?1
+		ON CONFLICT (stock_id, interval, timestamp) DO UPDATE
+		SET open = excluded.open, high = excluded.high, low = excluded.low, close = excluded.close,
+		    volume = excluded.volume, provider = excluded.provider
+	`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to save bars: %w", sqliteError(err))
+	}
+	defer stmt.Close()
+
+	for _, bar := range bars {
+		_, err := stmt.ExecContext(ctx,
+			bar.Symbol, bar.Interval, toMicros(bar.Timestamp),
+			bar.Open, bar.High, bar.Low, bar.Close, bar.Volume, bar.Provider,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save bars: %w", sqliteError(err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bars: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func scanSQLiteBar(row interface{ Scan(...interface{}) error }) (*models.PriceBar, error) {
+	bar := &models.PriceBar{}
+	err := row.Scan(
+		&bar.StockID, &bar.Symbol, &bar.Interval, sqliteTime{&bar.Timestamp},
+		&bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume, &bar.Provider,
+	)
+	return bar, err
+}
+
+func (r *SQLiteRepository) GetBars(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]*models.PriceBar, error) {
+	if err := sqliteLimit(limit); err != nil {
+		return nil, fmt.Errorf("failed to get bars: %w", sqliteError(err))
+	}
+
+	query := `
+		SELECT ` + barColumns + `
+		FROM price_bars pb
+		JOIN stocks s ON s.id = pb.stock_id
+		WHERE s.symbol = ?1 AND pb.interval = ?2 AND pb.timestamp BETWEEN ?3 AND ?4
+		ORDER BY pb.timestamp DESC
+		LIMIT ?5
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, symbol, interval, toMicros(from), toMicros(to), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bars: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var bars []*models.PriceBar
+	for rows.Next() {
+		bar, err := scanSQLiteBar(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bar: %w", sqliteError(err))
+		}
+		bars = append(bars, bar)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get bars: %w", sqliteError(err))
+	}
+
+	if len(bars) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get bars: %w", sqliteError(err))
+		}
+	}
+
+	return bars, nil
+}
+
+func (r *SQLiteRepository) GetLatestBar(ctx context.Context, symbol, interval string) (*models.PriceBar, error) {
+	query := `
+		SELECT ` + barColumns + `
+		FROM price_bars pb
+		JOIN stocks s ON s.id = pb.stock_id
+		WHERE s.symbol = ?1 AND pb.interval = ?2
+		ORDER BY pb.timestamp DESC
+		LIMIT 1
+	`
+
+	bar, err := scanSQLiteBar(r.db.QueryRowContext(ctx, query, symbol, interval))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest bar: %w", sqliteError(err))
+	}
+
+	return bar, nil
+}
+
+func (r *SQLiteRepository) GetBackfillProgress(ctx context.Context, symbol, interval string) (*models.BackfillProgress, error) {
+	query := `
+		SELECT s.symbol, bp.interval, bp.from_date, bp.to_date, bp.updated_at
+		FROM backfill_progress bp
+		JOIN stocks s ON s.id = bp.stock_id
+		WHERE s.symbol = ?1 AND bp.interval = ?2
+	`
+
+	progress := &models.BackfillProgress{}
+	err := r.db.QueryRowContext(ctx, query, symbol, interval).Scan(
+		&progress.Symbol, &progress.Interval, sqliteDate{&progress.From}, sqliteDate{&progress.To}, sqliteTime{&progress.UpdatedAt},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backfill progress: %w", sqliteError(err))
+	}
+
+	return progress, nil
+}
+
+func (r *SQLiteRepository) SaveBackfillProgress(ctx context.Context, progress *models.BackfillProgress) error {
+	query := `
+		INSERT INTO backfill_progress (stock_id, interval, from_date, to_date, updated_at)
+		SELECT s.id, ?2, ?3, ?4, ` + sqliteNow + `
+		FROM stocks s
+		WHERE s.symbol = ?1
+		ON CONFLICT (stock_id, interval) DO UPDATE
+		SET from_date = excluded.from_date, to_date = excluded.to_date, updated_at = excluded.updated_at
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		progress.Symbol, progress.Interval, progress.From.Format(dateLayout), progress.To.Format(dateLayout),
+	).Scan(sqliteTime{&progress.UpdatedAt})
+	if err != nil {
+		return fmt.Errorf("failed to save backfill progress: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) GetProviderQuota(ctx context.Context, provider string) (*models.ProviderQuota, error) {
+	query := `
+		SELECT provider, tokens, refilled_at, day, used_today, version
+		FROM provider_quotas
+		WHERE provider = ?1
+	`
+
+	quota := &models.ProviderQuota{}
+	err := r.db.QueryRowContext(ctx, query, provider).Scan(
+		&quota.Provider, &quota.Tokens, sqliteTime{&quota.RefilledAt}, sqliteDate{&quota.Day}, &quota.UsedToday, &quota.Version,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider quota: %w", sqliteError(err))
+	}
+
+	return quota, nil
+}
+
+func (r *SQLiteRepository) SaveProviderQuota(ctx context.Context, quota *models.ProviderQuota) error {
+	query := `
+		INSERT INTO provider_quotas (provider, tokens, refilled_at, day, used_today, version)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6 + 1)
+		ON CONFLICT (provider) DO UPDATE
+		SET tokens = excluded.tokens, refilled_at = excluded.refilled_at, day = excluded.day,
+		    used_today = excluded.used_today, version = excluded.version
+		WHERE provider_quotas.version = ?6
+		RETURNING version
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		quota.Provider, quota.Tokens, toMicros(quota.RefilledAt), quota.Day.Format(dateLayout), quota.UsedToday, quota.Version,
+	).Scan(&quota.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to save %s quota: %w", quota.Provider, ErrProviderQuotaChanged)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save provider quota: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) SaveAlert(ctx context.Context, alert *models.Alert) error {
+	query := `
+		INSERT INTO alerts (stock_id, rule_id, alert_type, threshold, message, dedup_key, triggered_at, state)
+		VALUES (?1, ?2, ?3, ?4, ?5, NULLIF(?6, ''), ?7, ?8)
+		ON CONFLICT (dedup_key) DO NOTHING
+		RETURNING id
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		alert.StockID, alert.RuleID, alert.AlertType, alert.Threshold,
+		alert.Message, alert.DedupKey, toMicros(alert.TriggeredAt), alert.State,
+	).Scan(&alert.ID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to save alert %s: %w", alert.DedupKey, ErrDuplicateAlert)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save alert: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+const sqliteAlertState = `CASE WHEN a.state = 'snoozed' AND a.snoozed_until <= ` + sqliteNow + ` THEN 'open' ELSE a.state END`
+
+// sqliteAlertColumns selects an alert joined with its stock as "s". A
+// snoozed alert whose snooze has run out reads as open.
+const sqliteAlertColumns = `
+		a.id, a.stock_id, s.symbol, a.rule_id, a.alert_type, a.threshold, COALESCE(a.message, ''),
+		COALESCE(a.dedup_key, ''), a.triggered_at,
+		` + sqliteAlertState + `, a.state_changed_at, COALESCE(a.state_changed_by, ''), a.snoozed_until`
+
+func scanSQLiteAlert(row interface{ Scan(...interface{}) error }) (*models.Alert, error) {
+	alert := &models.Alert{}
+	err := row.Scan(
+		&alert.ID, &alert.StockID, &alert.Symbol, &alert.RuleID,
+		&alert.AlertType, &alert.Threshold, &alert.Message,
+		&alert.DedupKey, sqliteTime{&alert.TriggeredAt},
+		&alert.State, sqliteNullTime{&alert.StateChangedAt}, &alert.StateChangedBy, sqliteNullTime{&alert.SnoozedUntil},
+	)
+	return alert, err
+}
+
+func (r *SQLiteRepository) GetAlert(ctx context.Context, id int) (*models.Alert, error) {
+	query := `SELECT ` + sqliteAlertColumns + ` FROM alerts a JOIN stocks s ON s.id = a.stock_id WHERE a.id = ?1`
+
+	alert, err := scanSQLiteAlert(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", sqliteError(err))
+	}
+
+	return alert, nil
+}
+
+func (r *SQLiteRepository) ListAlerts(ctx context.Context, filter AlertFilter) ([]*models.Alert, error) {
+	if err := sqliteLimit(filter.Limit); err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", sqliteError(err))
+	}
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Symbol != "" {
+		where("s.symbol = ?%d", filter.Symbol)
+	}
+	if filter.State != "" {
+		where(sqliteAlertState+" = ?%d", filter.State)
+	}
+	if filter.AlertType != "" {
+		where("a.alert_type = ?%d", filter.AlertType)
+	}
+	if !filter.From.IsZero() {
+		where("a.triggered_at >= ?%d", toMicros(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where("a.triggered_at < ?%d", toMicros(filter.To))
+	}
+
+	query := `SELECT ` + sqliteAlertColumns + ` FROM alerts a JOIN stocks s ON s.id = a.stock_id`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY a.triggered_at DESC LIMIT ?%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var alerts []*models.Alert
+	for rows.Next() {
+		alert, err := scanSQLiteAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", sqliteError(err))
+		}
+		alerts = append(alerts, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", sqliteError(err))
+	}
+
+	return alerts, nil
+}
+
+func (r *SQLiteRepository) UpdateAlertState(ctx context.Context, change *models.AlertStateChange) (*models.Alert, error) {
+	// Transactions start IMMEDIATE, which locks the alert like FOR UPDATE.
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRowContext(ctx, `SELECT `+sqliteAlertState+` FROM alerts a WHERE a.id = ?1`, change.AlertID).
+		Scan(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock alert: %w", sqliteError(err))
+	}
+	if current != change.FromState {
+		return nil, fmt.Errorf("alert %d is %s, not %s: %w", change.AlertID, current, change.FromState, ErrAlertStateChanged)
+	}
+
+	query := `
+		UPDATE alerts
+		SET state = ?1, state_changed_at = ` + sqliteNow + `, state_changed_by = ?2, snoozed_until = ?3
+		WHERE id = ?4
+	`
+	_, err = tx.ExecContext(ctx, query, change.ToState, change.ChangedBy, toNullMicros(change.SnoozedUntil), change.AlertID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update alert state: %w", sqliteError(err))
+	}
+
+	query = `
+		INSERT INTO alert_state_changes (alert_id, from_state, to_state, changed_by, note, snoozed_until, changed_at)
+		VALUES (?1, ?2, ?3, ?4, NULLIF(?5, ''), ?6, ` + sqliteNow + `)
+		RETURNING id, changed_at
+	`
+	err = tx.QueryRowContext(ctx, query,
+		change.AlertID, change.FromState, change.ToState, change.ChangedBy, change.Note, toNullMicros(change.SnoozedUntil),
+	).Scan(&change.ID, sqliteTime{&change.ChangedAt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record alert state change: %w", sqliteError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit alert state change: %w", sqliteError(err))
+	}
+
+	return r.GetAlert(ctx, change.AlertID)
+}
+
+func (r *SQLiteRepository) GetAlertStateChanges(ctx context.Context, alertID int) ([]*models.AlertStateChange, error) {
+	query := `
+		SELECT id, alert_id, from_state, to_state, changed_by, COALESCE(note, ''), snoozed_until, changed_at
+		FROM alert_state_changes
+		WHERE alert_id = ?1
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, alertID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert state changes: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var changes []*models.AlertStateChange
+	for rows.Next() {
+		change := &models.AlertStateChange{}
+		err := rows.Scan(
+			&change.ID, &change.AlertID, &change.FromState, &change.ToState,
+			&change.ChangedBy, &change.Note, sqliteNullTime{&change.SnoozedUntil}, sqliteTime{&change.ChangedAt},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert state change: %w", sqliteError(err))
+		}
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get alert state changes: %w", sqliteError(err))
+	}
+
+	return changes, nil
+}
+
+func (r *SQLiteRepository) CreateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	params, err := json.Marshal(rule.Params)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert rule params: %w", sqliteError(err))
+	}
+
+	query := `
+		INSERT INTO alert_rules (stock_id, rule_type, threshold, window_seconds, cooldown_seconds, hysteresis,
+		                         params, expression, enabled, created_at, updated_at)
+		SELECT s.id, ?2, ?3, ?4, ?5, ?6, ?7, NULLIF(?8, ''), ?9, ` + sqliteNow + `, ` + sqliteNow + `
+		FROM stocks s
+		WHERE s.symbol = ?1
+		RETURNING id, stock_id, created_at, updated_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		rule.Symbol, rule.RuleType, rule.Threshold, rule.WindowSeconds, rule.CooldownSeconds,
+		rule.Hysteresis, string(params), rule.Expression, rule.Enabled,
+	).Scan(&rule.ID, &rule.StockID, sqliteTime{&rule.CreatedAt}, sqliteTime{&rule.UpdatedAt})
+
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", sqliteError(err))
+	}
+
+	rule.State = models.AlertRuleState{Armed: true}
+
+	return nil
+}
+
+const sqliteRuleColumns = `
+		ar.id, ar.stock_id, s.symbol, ar.rule_type, ar.threshold, ar.window_seconds,
+		ar.cooldown_seconds, ar.hysteresis, ar.params, COALESCE(ar.expression, ''), ar.enabled,
+		COALESCE(st.armed, TRUE), COALESCE(st.episode, 0), st.last_fired_at,
+		ar.created_at, ar.updated_at
+	FROM alert_rules ar
+	JOIN stocks s ON s.id = ar.stock_id
+	LEFT JOIN alert_rule_state st ON st.rule_id = ar.id`
+
+func scanSQLiteRule(row interface{ Scan(...interface{}) error }) (*models.AlertRule, error) {
+	rule := &models.AlertRule{}
+	var params []byte
+	err := row.Scan(
+		&rule.ID, &rule.StockID, &rule.Symbol, &rule.RuleType, &rule.Threshold,
+		&rule.WindowSeconds, &rule.CooldownSeconds, &rule.Hysteresis, &params, &rule.Expression, &rule.Enabled,
+		&rule.State.Armed, &rule.State.Episode, sqliteNullTime{&rule.State.LastFiredAt},
+		sqliteTime{&rule.CreatedAt}, sqliteTime{&rule.UpdatedAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &rule.Params); err != nil {
+		return nil, fmt.Errorf("failed to decode params of alert rule %d: %w", rule.ID, sqliteError(err))
+	}
+	return rule, nil
+}
+
+func (r *SQLiteRepository) GetAlertRule(ctx context.Context, id int) (*models.AlertRule, error) {
+	query := `SELECT ` + sqliteRuleColumns + ` WHERE ar.id = ?1`
+
+	rule, err := scanSQLiteRule(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rule: %w", sqliteError(err))
+	}
+
+	return rule, nil
+}
+
+func (r *SQLiteRepository) GetAlertRules(ctx context.Context, symbol string) ([]*models.AlertRule, error) {
+	query := `SELECT ` + sqliteRuleColumns + ` WHERE s.symbol = ?1 ORDER BY ar.id`
+
+	rows, err := r.db.QueryContext(ctx, query, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rules: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var rules []*models.AlertRule
+	for rows.Next() {
+		rule, err := scanSQLiteRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", sqliteError(err))
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get alert rules: %w", sqliteError(err))
+	}
+
+	if len(rules) == 0 {
+		if err := r.requireStock(ctx, symbol); err != nil {
+			return nil, fmt.Errorf("failed to get alert rules: %w", sqliteError(err))
+		}
+	}
+
+	return rules, nil
+}
+
+func (r *SQLiteRepository) UpdateAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	params, err := json.Marshal(rule.Params)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert rule params: %w", sqliteError(err))
+	}
+
+	query := `
+		UPDATE alert_rules
+		SET rule_type = ?1, threshold = ?2, window_seconds = ?3, cooldown_seconds = ?4,
+		    hysteresis = ?5, params = ?6, expression = NULLIF(?7, ''), enabled = ?8, updated_at = ` + sqliteNow + `
+		WHERE id = ?9
+		RETURNING updated_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		rule.RuleType, rule.Threshold, rule.WindowSeconds, rule.CooldownSeconds,
+		rule.Hysteresis, string(params), rule.Expression, rule.Enabled, rule.ID,
+	).Scan(sqliteTime{&rule.UpdatedAt})
+
+	if err != nil {
+		return fmt.Errorf("failed to update alert rule: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) DeleteAlertRule(ctx context.Context, id int) error {
+	query := `DELETE FROM alert_rules WHERE id = ?1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) SaveAlertRuleState(ctx context.Context, ruleID int, state *models.AlertRuleState) error {
+	query := `
+		INSERT INTO alert_rule_state (rule_id, armed, episode, last_fired_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ` + sqliteNow + `)
+		ON CONFLICT (rule_id) DO UPDATE
+		SET armed = excluded.armed, episode = excluded.episode,
+		    last_fired_at = excluded.last_fired_at, updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query, ruleID, state.Armed, state.Episode, toNullMicros(state.LastFiredAt))
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule state: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func scanSQLiteChannel(row interface{ Scan(...interface{}) error }) (*models.NotificationChannel, error) {
+	channel := &models.NotificationChannel{}
+	var config []byte
+	err := row.Scan(
+		&channel.ID, &channel.Name, &channel.Type, &config,
+		&channel.Default, &channel.Enabled, sqliteTime{&channel.CreatedAt}, sqliteTime{&channel.UpdatedAt},
+	)
+	channel.Config = config
+	return channel, err
+}
+
+func (r *SQLiteRepository) CreateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error {
+	query := `
+		INSERT INTO notification_channels (name, channel_type, config, is_default, enabled, created_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ` + sqliteNow + `, ` + sqliteNow + `)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		channel.Name, channel.Type, sqliteJSON(channel.Config), channel.Default, channel.Enabled,
+	).Scan(&channel.ID, sqliteTime{&channel.CreatedAt}, sqliteTime{&channel.UpdatedAt})
+
+	if err != nil {
+		return fmt.Errorf("failed to create notification channel: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+// sqliteJSON stores a JSON document as text, or NULL when there is none,
+// like a nil json.RawMessage in a JSONB column.
+func sqliteJSON(raw json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	return string(raw)
+}
+
+func (r *SQLiteRepository) GetNotificationChannel(ctx context.Context, id int) (*models.NotificationChannel, error) {
+	query := `SELECT ` + channelColumns + ` FROM notification_channels nc WHERE nc.id = ?1`
+
+	channel, err := scanSQLiteChannel(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification channel: %w", sqliteError(err))
+	}
+
+	return channel, nil
+}
+
+func (r *SQLiteRepository) GetNotificationChannels(ctx context.Context) ([]*models.NotificationChannel, error) {
+	query := `SELECT ` + channelColumns + ` FROM notification_channels nc ORDER BY nc.id`
+
+	return r.queryChannels(ctx, query)
+}
+
+func (r *SQLiteRepository) UpdateNotificationChannel(ctx context.Context, channel *models.NotificationChannel) error {
+	query := `
+		UPDATE notification_channels
+		SET name = ?1, config = ?2, is_default = ?3, enabled = ?4, updated_at = ` + sqliteNow + `
+		WHERE id = ?5
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		channel.Name, sqliteJSON(channel.Config), channel.Default, channel.Enabled, channel.ID,
+	).Scan(sqliteTime{&channel.UpdatedAt})
+
+	if err != nil {
+		return fmt.Errorf("failed to update notification channel: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) DeleteNotificationChannel(ctx context.Context, id int) error {
+	query := `DELETE FROM notification_channels WHERE id = ?1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification channel: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) SetRuleChannels(ctx context.Context, ruleID int, channelIDs []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", sqliteError(err))
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM alert_rule_channels WHERE rule_id = ?1`, ruleID); err != nil {
+		return fmt.Errorf("failed to clear rule channels: %w", sqliteError(err))
+	}
+
+	query := `
+		INSERT INTO alert_rule_channels (rule_id, channel_id)
+		VALUES (?1, ?2)
+		ON CONFLICT DO NOTHING
+	`
+	for _, channelID := range channelIDs {
+		if _, err := tx.ExecContext(ctx, query, ruleID, channelID); err != nil {
+			return fmt.Errorf("failed to set rule channels: %w", sqliteError(err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rule channels: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) GetRuleChannels(ctx context.Context, ruleID int) ([]*models.NotificationChannel, error) {
+	query := `
+		SELECT ` + channelColumns + `
+		FROM notification_channels nc
+		JOIN alert_rule_channels arc ON arc.channel_id = nc.id
+		WHERE arc.rule_id = ?1
+		ORDER BY nc.id
+	`
+
+	return r.queryChannels(ctx, query, ruleID)
+}
+
+func (r *SQLiteRepository) GetDefaultChannels(ctx context.Context) ([]*models.NotificationChannel, error) {
+	query := `SELECT ` + channelColumns + ` FROM notification_channels nc WHERE nc.is_default ORDER BY nc.id`
+
+	return r.queryChannels(ctx, query)
+}
+
+func (r *SQLiteRepository) queryChannels(ctx context.Context, query string, args ...interface{}) ([]*models.NotificationChannel, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification channels: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var channels []*models.NotificationChannel
+	for rows.Next() {
+		channel, err := scanSQLiteChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification channel: %w", sqliteError(err))
+		}
+		channels = append(channels, channel)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get notification channels: %w", sqliteError(err))
+	}
+
+	return channels, nil
+}
+
+func (r *SQLiteRepository) SaveDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	query := `
+		INSERT INTO notification_deliveries (alert_id, channel_id, attempt, status, error, created_at)
+		VALUES (NULLIF(?1, 0), ?2, ?3, ?4, NULLIF(?5, ''), ` + sqliteNow + `)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		delivery.AlertID, delivery.ChannelID, delivery.Attempt, delivery.Status, delivery.Error,
+	).Scan(&delivery.ID, sqliteTime{&delivery.CreatedAt})
+
+	if err != nil {
+		return fmt.Errorf("failed to save notification delivery: %w", sqliteError(err))
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) GetDeliveries(ctx context.Context, alertID int) ([]*models.NotificationDelivery, error) {
+	query := `
+		SELECT id, alert_id, COALESCE(channel_id, 0), attempt, status, COALESCE(error, ''), created_at
+		FROM notification_deliveries
+		WHERE alert_id = ?1
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, alertID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification deliveries: %w", sqliteError(err))
+	}
+	defer rows.Close()
+
+	var deliveries []*models.NotificationDelivery
+	for rows.Next() {
+		delivery := &models.NotificationDelivery{}
+		err := rows.Scan(
+			&delivery.ID, &delivery.AlertID, &delivery.ChannelID, &delivery.Attempt,
+			&delivery.Status, &delivery.Error, sqliteTime{&delivery.CreatedAt},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", sqliteError(err))
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get notification deliveries: %w", sqliteError(err))
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,26 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"stock-tracker/internal/repository"
+	"stock-tracker/internal/repository/repotest"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.StockRepository {
+		path := filepath.Join(t.TempDir(), "test.db")
+		if err := repository.Migrate(context.Background(), "sqlite://"+path); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+
+		repo, err := repository.NewSQLiteRepository(path)
+		if err != nil {
+			t.Fatalf("NewSQLiteRepository: %v", err)
+		}
+		t.Cleanup(repo.Close)
+		return repo
+	})
+}
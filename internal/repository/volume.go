@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"stock-tracker/internal/models"
+	"sync"
+	"time"
+)
+
+// VolumeAverages caches the average daily volumes of symbols over the
+// previous trading days, for the tracker and the alert expressions, which
+// may ask for other windows. Past days don't change, so an average is
+// loaded once per UTC day and reused by every update that day.
+type VolumeAverages struct {
+	repo    StockRepository
+	mu      sync.Mutex
+	entries map[volumeKey]volumeAverage
+}
+
+type volumeKey struct {
+	symbol string
+	days   int
+}
+
+type volumeAverage struct {
+	day time.Time
+	avg float64
+}
+
+func NewVolumeAverages(repo StockRepository) *VolumeAverages {
+	return &VolumeAverages{repo: repo, entries: make(map[volumeKey]volumeAverage)}
+}
+
+// Get returns the symbol's average daily volume over the given number of
+// days before today, or zero if there isn't enough history. If loading it
+// fails, the average loaded last is returned with the error.
+func (v *VolumeAverages) Get(ctx context.Context, symbol string, days int) (float64, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	key := volumeKey{symbol, days}
+
+	v.mu.Lock()
+	entry, ok := v.entries[key]
+	v.mu.Unlock()
+	if ok && entry.day.Equal(today) {
+		return entry.avg, nil
+	}
+
+	avg, found, err := v.repo.GetAverageDailyVolume(ctx, symbol, days, today)
+	if err != nil {
+		return entry.avg, fmt.Errorf("failed to load average daily volume: %w", err)
+	}
+	if found < min(models.MinVolumeDays, days) {
+		avg = 0
+	}
+
+	v.mu.Lock()
+	v.entries[key] = volumeAverage{day: today, avg: avg}
+	v.mu.Unlock()
+
+	return avg, nil
+}
+
+// Remove forgets the symbol's averages.
+func (v *VolumeAverages) Remove(symbol string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for key := range v.entries {
+		if key.symbol == symbol {
+			delete(v.entries, key)
+		}
+	}
+}
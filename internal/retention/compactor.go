@@ -0,0 +1,86 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"time"
+)
+
+// Compactor applies a retention policy to the stored prices.
+type Compactor struct {
+	repo    repository.StockRepository
+	policy  Policy
+	metrics *metrics.Metrics
+}
+
+func NewCompactor(repo repository.StockRepository, policy Policy, m *metrics.Metrics) *Compactor {
+	return &Compactor{repo: repo, policy: policy, metrics: m}
+}
+
+// Run compacts right away and then every interval until ctx is cancelled.
+func (c *Compactor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Compact(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Msg("Failed to compact price history")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compact moves the rows of each tier that are older than its retention
+// at now into the next tier, or deletes them from the last one. Rollups
+// are written in the same statement that deletes their rows.
+func (c *Compactor) Compact(ctx context.Context, now time.Time) error {
+	var errs []error
+	for i, tier := range c.policy {
+		if tier.Keep == Forever {
+			continue
+		}
+
+		before := now.Add(-tier.Keep)
+		into := ""
+		if i+1 < len(c.policy) {
+			into = c.policy[i+1].Interval
+			// Only move whole buckets, so no rollup is built from part
+			// of its rows.
+			length, _ := models.BarDuration(into)
+			before = before.Truncate(length)
+		}
+
+		compacted, deleted, err := c.repo.CompactPrices(ctx, tier.Interval, into, before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compact %s tier: %w", tier.Interval, err))
+			continue
+		}
+
+		if into != "" {
+			c.metrics.RetentionCompacted.WithLabelValues(into).Add(float64(compacted))
+		}
+		c.metrics.RetentionDeleted.WithLabelValues(tier.Interval).Add(float64(deleted))
+
+		if deleted > 0 {
+			logger.Info().
+				Str("tier", tier.Interval).
+				Str("into", into).
+				Time("before", before).
+				Int64("compacted", compacted).
+				Int64("deleted", deleted).
+				Msg("Compacted price history")
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+// Package retention bounds the stored price history. Prices move through
+// a list of tiers, raw ticks first: once rows are older than their tier's
+// retention they are rolled up into the next, coarser tier and deleted.
+package retention
+
+import (
+	"fmt"
+	"stock-tracker/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Forever is the retention of a tier whose rows are never deleted.
+const Forever time.Duration = 0
+
+// Tier is one resolution of stored prices.
+type Tier struct {
+	// Interval is models.TierRaw or the bar interval of a rollup.
+	Interval string
+	// Keep is how long rows stay in the tier.
+	Keep time.Duration
+}
+
+// Policy lists the tiers from finest to coarsest.
+type Policy []Tier
+
+// ParsePolicy parses tiers written as interval:retention pairs, finest
+// first, e.g. "raw:7d,5m:90d,1d:forever". A retention is a Go duration,
+// a number of days like "90d", or "forever".
+func ParsePolicy(spec string) (Policy, error) {
+	var policy Policy
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		interval, keep, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("tier %q must be written as interval:retention", item)
+		}
+
+		tier := Tier{Interval: strings.TrimSpace(interval)}
+		var err error
+		if tier.Keep, err = parseKeep(strings.TrimSpace(keep)); err != nil {
+			return nil, fmt.Errorf("tier %q: %w", item, err)
+		}
+		policy = append(policy, tier)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func parseKeep(s string) (time.Duration, error) {
+	if s == "forever" {
+		return Forever, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("retention %q must be a positive number of days", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("retention %q must be a positive duration, a number of days or forever", s)
+	}
+	return d, nil
+}
+
+// Validate checks that the tiers start with raw prices and get coarser,
+// each rollup interval a multiple of the one before, and that each tier
+// keeps its rows longer than the one before. Only the last tier may keep
+// them forever.
+func (p Policy) Validate() error {
+	if len(p) == 0 {
+		return fmt.Errorf("retention policy has no tiers")
+	}
+	if p[0].Interval != models.TierRaw {
+		return fmt.Errorf("retention policy must start with the %s tier", models.TierRaw)
+	}
+
+	var prev time.Duration
+	for i, tier := range p {
+		if i > 0 {
+			length, ok := models.BarDuration(tier.Interval)
+			if !ok {
+				return fmt.Errorf("unknown rollup interval %q", tier.Interval)
+			}
+			if length <= prev || (prev > 0 && length%prev != 0) {
+				return fmt.Errorf("rollup interval %s must be a multiple of %s", tier.Interval, p[i-1].Interval)
+			}
+			prev = length
+
+			if p[i-1].Keep == Forever {
+				return fmt.Errorf("tier %s keeps its rows forever, so tier %s would stay empty", p[i-1].Interval, tier.Interval)
+			}
+			if tier.Keep != Forever && tier.Keep <= p[i-1].Keep {
+				return fmt.Errorf("tier %s must keep its rows longer than tier %s", tier.Interval, p[i-1].Interval)
+			}
+		}
+	}
+	return nil
+}
+
+func (p Policy) String() string {
+	tiers := make([]string, len(p))
+	for i, tier := range p {
+		keep := "forever"
+		if tier.Keep != Forever {
+			keep = tier.Keep.String()
+			if tier.Keep%(24*time.Hour) == 0 {
+				keep = fmt.Sprintf("%dd", tier.Keep/(24*time.Hour))
+			}
+		}
+		tiers[i] = tier.Interval + ":" + keep
+	}
+	return strings.Join(tiers, ",")
+}
@@ -0,0 +1,114 @@
+package tracker
+
+import (
+	"context"
+	"stock-tracker/internal/api"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"sync"
+	"time"
+)
+
+// latestBars remembers the start of each symbol's newest stored bar per
+// interval, so bars are only requested once a new one has begun.
+type latestBars struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func newLatestBars() *latestBars {
+	return &latestBars{entries: make(map[string]time.Time)}
+}
+
+// get returns the start of the newest stored bar, or the zero time if
+// there is none, loading it from the repository on first use.
+func (l *latestBars) get(ctx context.Context, repo repository.StockRepository, symbol, interval string) (time.Time, error) {
+	key := symbol + "|" + interval
+
+	l.mu.Lock()
+	latest, ok := l.entries[key]
+	l.mu.Unlock()
+	if ok {
+		return latest, nil
+	}
+
+	bar, err := repo.GetLatestBar(ctx, symbol, interval)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if bar != nil {
+		latest = bar.Timestamp
+	}
+
+	l.set(symbol, interval, latest)
+	return latest, nil
+}
+
+func (l *latestBars) set(symbol, interval string, latest time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[symbol+"|"+interval] = latest
+}
+
+func (l *latestBars) remove(symbol string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, interval := range models.IntradayIntervals {
+		delete(l.entries, symbol+"|"+interval)
+	}
+}
+
+// intradayProvider returns the provider's intraday bars, or nil if it has
+// none.
+func (st *StockTracker) intradayProvider() api.IntradayProvider {
+	intraday, ok := st.provider.(api.IntradayProvider)
+	if !ok || !st.provider.Capabilities().IntradayBars {
+		return nil
+	}
+	return intraday
+}
+
+// updateBars fetches the symbol's new intraday bars of each configured
+// interval. A bar interval is only requested once a bar has started since
+// the newest stored one; that bar is fetched again, as it was probably
+// still in progress when it was stored.
+func (st *StockTracker) updateBars(ctx context.Context, symbol string) {
+	intraday := st.intradayProvider()
+	if intraday == nil {
+		return
+	}
+
+	for _, interval := range st.opts.BarIntervals {
+		length, _ := models.BarDuration(interval)
+
+		latest, err := st.bars.get(ctx, st.repo, symbol, interval)
+		if err != nil {
+			logger.Error().Err(err).Str("symbol", symbol).Str("interval", interval).Msg("Failed to load latest bar")
+			continue
+		}
+		if !latest.IsZero() && time.Now().Before(latest.Add(length)) {
+			continue
+		}
+
+		bars, err := intraday.GetIntradayBars(ctx, symbol, interval, latest)
+		if err != nil {
+			logger.Warn().Err(err).Str("symbol", symbol).Str("interval", interval).Msg("Failed to fetch intraday bars")
+			return
+		}
+		if len(bars) == 0 {
+			continue
+		}
+
+		if err := st.repo.SaveBars(ctx, bars); err != nil {
+			logger.Error().Err(err).Str("symbol", symbol).Str("interval", interval).Msg("Failed to save intraday bars")
+			continue
+		}
+
+		st.bars.set(symbol, interval, bars[len(bars)-1].Timestamp)
+		st.metrics.BarsSaved.WithLabelValues(interval).Add(float64(len(bars)))
+		logger.Debug().Str("symbol", symbol).Str("interval", interval).Int("bars", len(bars)).Msg("Saved intraday bars")
+	}
+}
@@ -0,0 +1,87 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+	"stock-tracker/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	// maxPriceBatch is how many prices are buffered before they are saved
+	// without waiting for the end of the update cycle.
+	maxPriceBatch = 500
+	// flushTimeout bounds saving a batch, which also runs after the update
+	// cycle's context is cancelled so a shutdown doesn't drop prices.
+	flushTimeout = 30 * time.Second
+)
+
+// priceBuffer collects the prices fetched during an update cycle so they
+// are saved in one batch rather than one round trip per quote.
+type priceBuffer struct {
+	mu     sync.Mutex
+	prices []*models.StockPrice
+}
+
+// add buffers price and returns how many prices are buffered.
+func (b *priceBuffer) add(price *models.StockPrice) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prices = append(b.prices, price)
+	return len(b.prices)
+}
+
+// take empties the buffer and returns what it held.
+func (b *priceBuffer) take() []*models.StockPrice {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prices := b.prices
+	b.prices = nil
+	return prices
+}
+
+// flushPrices saves the buffered prices. Failures are logged rather than
+// returned, like savePrice. If the batch is rejected because of one bad
+// price, the prices are saved one at a time so the others still land.
+func (st *StockTracker) flushPrices(ctx context.Context) {
+	prices := st.prices.take()
+	if len(prices) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := st.repo.SavePrices(ctx, prices)
+	st.metrics.PriceIngestDuration.Observe(time.Since(start).Seconds())
+	st.metrics.PriceBatchSize.Observe(float64(len(prices)))
+
+	if err == nil {
+		st.metrics.PricesSaved.WithLabelValues("saved").Add(float64(len(prices)))
+		logger.Debug().Int("prices", len(prices)).Dur("duration", time.Since(start)).Msg("Saved price batch")
+		return
+	}
+
+	var validation *repository.ValidationError
+	if !errors.As(err, &validation) {
+		st.metrics.PricesSaved.WithLabelValues("failed").Add(float64(len(prices)))
+		logger.Error().Err(err).Int("prices", len(prices)).Msg("Failed to save prices to database")
+		return
+	}
+
+	logger.Warn().Err(err).Int("prices", len(prices)).Msg("Price batch rejected, saving prices one at a time")
+	for _, price := range prices {
+		if err := st.repo.SavePrice(ctx, price); err != nil {
+			st.metrics.PricesSaved.WithLabelValues("failed").Inc()
+			logger.Error().Err(err).Str("symbol", price.Symbol).Msg("Failed to save price to database")
+			continue
+		}
+		st.metrics.PricesSaved.WithLabelValues("saved").Inc()
+	}
+}
@@ -0,0 +1,58 @@
+package tracker
+
+import (
+	"stock-tracker/internal/calendar"
+	"time"
+)
+
+// interval returns how often to poll during a session, and false if the
+// session isn't polled at all. Without a calendar every moment is polled
+// at the regular interval.
+func (st *StockTracker) interval(session calendar.Session) (time.Duration, bool) {
+	switch {
+	case st.opts.Calendar == nil, session == calendar.SessionRegular:
+		return st.opts.Interval, true
+	case session.Extended() && st.opts.ExtendedInterval > 0:
+		return st.opts.ExtendedInterval, true
+	default:
+		return 0, false
+	}
+}
+
+// session returns the market session at t, or "" without a calendar.
+func (st *StockTracker) session(t time.Time) calendar.Session {
+	if st.opts.Calendar == nil {
+		return ""
+	}
+	return st.opts.Calendar.Session(t)
+}
+
+// polling reports whether an update cycle should run at now.
+func (st *StockTracker) polling(now time.Time) bool {
+	_, ok := st.interval(st.session(now))
+	return ok
+}
+
+// nextCycle returns when the update cycle after one at now is due: an
+// interval later while the market is polled, but never past the start of
+// the next session, so a session change takes effect on time.
+func (st *StockTracker) nextCycle(now time.Time) time.Time {
+	cal := st.opts.Calendar
+	if cal == nil {
+		return now.Add(st.opts.Interval)
+	}
+
+	if interval, ok := st.interval(cal.Session(now)); ok {
+		next, transition := now.Add(interval), cal.NextTransition(now)
+		if transition.Before(next) {
+			return transition
+		}
+		return next
+	}
+
+	polled := []calendar.Session{calendar.SessionRegular}
+	if st.opts.ExtendedInterval > 0 {
+		polled = append(polled, calendar.SessionPreMarket, calendar.SessionAfterHours)
+	}
+	return cal.Next(now, polled...)
+}
@@ -2,40 +2,103 @@ package tracker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"stock-tracker/internal/alerts"
 	"stock-tracker/internal/api"
-	"stock-tracker/internal/api/websocket"
+	"stock-tracker/internal/calendar"
+	"stock-tracker/internal/events"
 	"stock-tracker/internal/metrics"
 	"stock-tracker/internal/models"
+	"stock-tracker/internal/notify"
 	"stock-tracker/internal/repository"
 	"stock-tracker/pkg/logger"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Options tunes the update loop.
+type Options struct {
+	Interval       time.Duration
+	AlertThreshold float64
+	// Workers bounds how many symbols are fetched concurrently.
+	Workers int
+	// FetchTimeout bounds the work on a symbol once its quote is in. The
+	// provider bounds its calls itself, from when its rate limiter lets
+	// them through, so a cycle waits as long as the rate limit requires
+	// rather than timing out the symbols queued behind it.
+	FetchTimeout time.Duration
+	// Notify controls retries of alert notifications.
+	Notify notify.RetryPolicy
+	// VolumeAverageDays is how many trading days the average daily volume
+	// covers.
+	VolumeAverageDays int
+	// Calendar, if set, limits updates to the exchange's trading sessions
+	// and tags stored prices with their session.
+	Calendar *calendar.Calendar
+	// ExtendedInterval is the update interval in pre-market and
+	// after-hours trading; zero skips those sessions.
+	ExtendedInterval time.Duration
+	// BarIntervals are the intraday bar intervals to ingest for every
+	// symbol after its quote, if the provider has intraday bars.
+	BarIntervals []string
+}
+
 type StockTracker struct {
 	stocks   map[string]*models.Stock
 	mu       sync.RWMutex
-	client   *api.AlphaVantageClient
+	provider api.QuoteProvider
 	monitor  *alerts.AlertMonitor
 	metrics  *metrics.Metrics
 	repo     repository.StockRepository
-	wsHub    *websocket.Hub
-	interval time.Duration
+	events   events.Publisher
+	opts     Options
+	volumes  *repository.VolumeAverages
+	bars     *latestBars
+	prices   priceBuffer
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	running   atomic.Bool
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// cycleStats counts the outcome of each symbol in one update cycle.
+type cycleStats struct {
+	success atomic.Int64
+	failed  atomic.Int64
+	skipped atomic.Int64
 }
 
-func New(apiKey string, interval time.Duration, alertThreshold float64, m *metrics.Metrics, repo repository.StockRepository, wsHub *websocket.Hub) *StockTracker {
-	client := api.NewClient(apiKey, m, repo)
+func New(provider api.QuoteProvider, opts Options, m *metrics.Metrics, repo repository.StockRepository, publisher events.Publisher) *StockTracker {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.FetchTimeout <= 0 {
+		opts.FetchTimeout = 30 * time.Second
+	}
+	if opts.VolumeAverageDays < 1 {
+		opts.VolumeAverageDays = 20
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	volumes := repository.NewVolumeAverages(repo)
 
 	return &StockTracker{
 		stocks:   make(map[string]*models.Stock),
-		client:   client,
-		monitor:  alerts.NewMonitor(alertThreshold, m, repo, wsHub),
+		provider: provider,
+		monitor:  alerts.NewMonitor(opts.AlertThreshold, m, repo, volumes, publisher, opts.Notify),
 		metrics:  m,
 		repo:     repo,
-		wsHub:    wsHub,
-		interval: interval,
+		events:   publisher,
+		opts:     opts,
+		volumes:  volumes,
+		bars:     newLatestBars(),
+		ctx:      ctx,
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
 	}
 }
 
@@ -58,6 +121,103 @@ func (st *StockTracker) AddStock(symbol string) {
 	}
 }
 
+// LoadWatchlist starts tracking every active stock stored in the
+// repository. The defaults are only added when the repository holds no
+// stocks at all; a watchlist where every stock is paused stays empty.
+func (st *StockTracker) LoadWatchlist(ctx context.Context, defaults []string) error {
+	stored, err := st.repo.GetAllStocks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load watchlist: %w", err)
+	}
+
+	if len(stored) == 0 {
+		logger.Info().Strs("symbols", defaults).Msg("Watchlist is empty, tracking default symbols")
+		for _, symbol := range defaults {
+			st.AddStock(symbol)
+		}
+		return nil
+	}
+
+	st.syncWatchlist(ctx, stored)
+	return nil
+}
+
+// SyncWatchlist reconciles the tracked stocks with the repository so that
+// symbols added, paused, resumed or removed through the API take effect
+// without a restart.
+func (st *StockTracker) SyncWatchlist(ctx context.Context) error {
+	stored, err := st.repo.GetAllStocks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to sync watchlist: %w", err)
+	}
+
+	st.syncWatchlist(ctx, stored)
+	return nil
+}
+
+func (st *StockTracker) syncWatchlist(ctx context.Context, stored []*models.Stock) {
+	active := make(map[string]*models.Stock, len(stored))
+	for _, stock := range stored {
+		if stock.Active {
+			active[stock.Symbol] = stock
+		}
+	}
+
+	st.mu.Lock()
+	var removed []string
+	for symbol, stock := range st.stocks {
+		if dbStock, ok := active[symbol]; ok {
+			// The ID changes if the stock was deleted and added again
+			// between syncs, and prices must be saved under the new one.
+			stock.ID = dbStock.ID
+			stock.Name = dbStock.Name
+			delete(active, symbol)
+		} else {
+			removed = append(removed, symbol)
+		}
+	}
+	st.mu.Unlock()
+
+	for _, symbol := range removed {
+		st.RemoveStock(symbol)
+	}
+
+	for _, stock := range active {
+		seeded := st.seedStock(ctx, stock)
+
+		st.mu.Lock()
+		if _, exists := st.stocks[seeded.Symbol]; !exists {
+			st.stocks[seeded.Symbol] = seeded
+			st.metrics.TrackedStocksCount.Inc()
+			logger.Info().Str("symbol", seeded.Symbol).Msg("Added stock to tracking list")
+		}
+		st.mu.Unlock()
+	}
+}
+
+// seedStock copies a stored stock and primes it with its last known price,
+// so the first update already has a previous price to compare against.
+func (st *StockTracker) seedStock(ctx context.Context, stored *models.Stock) *models.Stock {
+	stock := models.NewStock(stored.Symbol)
+	stock.ID = stored.ID
+	stock.Name = stored.Name
+	stock.CreatedAt = stored.CreatedAt
+	stock.UpdatedAt = stored.UpdatedAt
+
+	latest, err := st.repo.GetLatestPrice(ctx, stored.Symbol)
+	if err != nil {
+		logger.Debug().Err(err).Str("symbol", stored.Symbol).Msg("No stored price to seed stock with")
+		return stock
+	}
+
+	stock.CurrentPrice = latest.Price
+	stock.ChangePercent = latest.ChangePercent
+	stock.Volume = latest.Volume
+	stock.Provider = latest.Provider
+	stock.LastUpdated = latest.Timestamp
+	return stock
+}
+
 func (st *StockTracker) RemoveStock(symbol string) {
 	st.mu.Lock()
 	defer st.mu.Unlock()
@@ -65,17 +225,24 @@ func (st *StockTracker) RemoveStock(symbol string) {
 	if _, exists := st.stocks[symbol]; exists {
 		delete(st.stocks, symbol)
 		st.metrics.TrackedStocksCount.Dec()
+		st.metrics.CurrentStockPrice.DeleteLabelValues(symbol)
+		st.metrics.StockPriceChange.DeleteLabelValues(symbol)
+		st.metrics.StockVolume.DeleteLabelValues(symbol)
+		st.volumes.Remove(symbol)
+		st.bars.remove(symbol)
+		st.monitor.Forget(symbol)
 		logger.Info().Str("symbol", symbol).Msg("Removed stock from tracking list")
 	}
 }
 
-func (st *StockTracker) UpdateStock(symbol string) error {
+// UpdateStock fetches and records the latest quote for symbol. Recording
+// it is bounded by the configured FetchTimeout on top of ctx.
+func (st *StockTracker) UpdateStock(ctx context.Context, symbol string) error {
 	start := time.Now()
-	ctx := context.Background()
 
-	logger.Debug().Str("symbol", symbol).Msg("Starting stock update")
+	logger.Debug().Str("symbol", symbol).Str("provider", st.provider.Name()).Msg("Starting stock update")
 
-	newData, err := st.client.GetQuote(ctx, symbol)
+	newData, err := st.provider.GetQuote(ctx, symbol)
 	duration := time.Since(start).Seconds()
 
 	st.metrics.StockUpdateDuration.WithLabelValues(symbol).Observe(duration)
@@ -86,23 +253,38 @@ func (st *StockTracker) UpdateStock(symbol string) error {
 		return fmt.Errorf("failed to update %s: %w", symbol, err)
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, st.opts.FetchTimeout)
+	defer cancel()
+
+	avgVolume, err := st.volumes.Get(ctx, symbol, st.opts.VolumeAverageDays)
+	if err != nil {
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to load average daily volume")
+	}
+
 	st.mu.Lock()
 	if stock, exists := st.stocks[symbol]; exists {
 		stock.UpdatePrice(newData.CurrentPrice, newData.ChangePercent)
-		stock.ID = newData.ID
+		stock.Volume = newData.Volume
+		stock.AvgVolume = avgVolume
+		stock.Provider = newData.Provider
 
 		st.metrics.CurrentStockPrice.WithLabelValues(symbol).Set(stock.CurrentPrice)
 		st.metrics.StockPriceChange.WithLabelValues(symbol).Set(stock.ChangePercent)
+		st.metrics.StockVolume.WithLabelValues(symbol).Set(float64(stock.Volume))
 
 		st.mu.Unlock()
 
-		// Broadcast update via WebSocket
-		st.wsHub.BroadcastStockUpdate(stock)
+		st.savePrice(ctx, stock)
+
+		// Fan the update out to WebSocket clients
+		if err := st.events.Publish(ctx, events.TypeStockUpdate, stock); err != nil {
+			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to publish stock update")
+		}
 
-		st.monitor.CheckStock(stock)
+		st.monitor.CheckStock(ctx, stock)
 		st.metrics.StockUpdatesTotal.WithLabelValues(symbol, "success").Inc()
 
-		logger.Info().Str("symbol", symbol).Float64("price", stock.CurrentPrice).Float64("change_percent", stock.ChangePercent).Float64("duration_seconds", duration).Msg("Successfully updated stock")
+		logger.Info().Str("symbol", symbol).Str("provider", stock.Provider).Float64("price", stock.CurrentPrice).Float64("change_percent", stock.ChangePercent).Float64("duration_seconds", duration).Msg("Successfully updated stock")
 	} else {
 		st.mu.Unlock()
 	}
@@ -110,8 +292,40 @@ func (st *StockTracker) UpdateStock(symbol string) error {
 	return nil
 }
 
-func (st *StockTracker) UpdateAll() {
-	logger.Info().Msg("Starting update cycle for all stocks")
+// savePrice queues the stock's current price to be appended to its history
+// with the rest of the cycle's prices. Failures are logged rather than
+// returned so a database hiccup doesn't hide a fresh quote.
+func (st *StockTracker) savePrice(ctx context.Context, stock *models.Stock) {
+	if stock.ID == 0 {
+		// AddStock could not create the row earlier; try again now.
+		if err := st.repo.CreateStock(ctx, stock); err != nil {
+			logger.Error().Err(err).Str("symbol", stock.Symbol).Msg("Failed to create stock in database")
+			return
+		}
+	}
+
+	price := &models.StockPrice{
+		StockID:       stock.ID,
+		Symbol:        stock.Symbol,
+		Price:         stock.CurrentPrice,
+		ChangePercent: stock.ChangePercent,
+		Volume:        stock.Volume,
+		Provider:      stock.Provider,
+		Session:       string(st.session(stock.LastUpdated)),
+		Timestamp:     stock.LastUpdated,
+	}
+
+	if st.prices.add(price) >= maxPriceBatch {
+		st.flushPrices(ctx)
+	}
+}
+
+// UpdateAll refreshes every tracked stock using a bounded pool of workers.
+// It returns once every symbol has been fetched, skipped or abandoned
+// because ctx was cancelled.
+func (st *StockTracker) UpdateAll(ctx context.Context) {
+	start := time.Now()
+	logger.Info().Int("workers", st.opts.Workers).Msg("Starting update cycle for all stocks")
 
 	st.mu.RLock()
 	symbols := make([]string, 0, len(st.stocks))
@@ -120,18 +334,84 @@ func (st *StockTracker) UpdateAll() {
 	}
 	st.mu.RUnlock()
 
-	successCount := 0
-	for _, symbol := range symbols {
-		if err := st.UpdateStock(symbol); err != nil {
-			logger.Error().Err(err).Str("symbol", symbol).Msg("Error updating stock in batch")
-		} else {
-			successCount++
+	var stats cycleStats
+	jobs := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(st.opts.Workers, len(symbols)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for symbol := range jobs {
+				st.updateInCycle(ctx, symbol, &stats)
+			}
+		}()
+	}
+
+	for i, symbol := range symbols {
+		if until, exhausted := st.quotaExhaustedUntil(); exhausted {
+			stats.skipped.Add(int64(len(symbols) - i))
+			logger.Warn().Time("until", until).Int("skipped", len(symbols)-i).Msg("Provider quota exhausted, skipping remaining stocks")
+			break
+		}
+
+		select {
+		case jobs <- symbol:
+			continue
+		case <-ctx.Done():
+			stats.skipped.Add(int64(len(symbols) - i))
 		}
-		time.Sleep(12 * time.Second)
+		break
 	}
+	close(jobs)
+	wg.Wait()
+	st.flushPrices(ctx)
+
+	duration := time.Since(start)
+	success, failed, skipped := stats.success.Load(), stats.failed.Load(), stats.skipped.Load()
 
 	st.metrics.UpdateCyclesTotal.Inc()
-	logger.Info().Int("total", len(symbols)).Int("success", successCount).Int("failed", len(symbols)-successCount).Msg("Completed update cycle")
+	st.metrics.UpdateCycleDuration.Observe(duration.Seconds())
+	st.metrics.UpdateCycleStocks.WithLabelValues("success").Add(float64(success))
+	st.metrics.UpdateCycleStocks.WithLabelValues("failed").Add(float64(failed))
+	st.metrics.UpdateCycleStocks.WithLabelValues("skipped").Add(float64(skipped))
+
+	logger.Info().
+		Int("total", len(symbols)).
+		Int64("success", success).
+		Int64("failed", failed).
+		Int64("skipped", skipped).
+		Dur("duration", duration).
+		Msg("Completed update cycle")
+}
+
+// updateInCycle updates one symbol and classifies the outcome. Symbols that
+// could not be fetched because of shutdown or the rate limit count as
+// skipped rather than failed.
+func (st *StockTracker) updateInCycle(ctx context.Context, symbol string, stats *cycleStats) {
+	if ctx.Err() != nil {
+		stats.skipped.Add(1)
+		return
+	}
+
+	err := st.UpdateStock(ctx, symbol)
+	switch {
+	case err == nil:
+		stats.success.Add(1)
+		st.updateBars(ctx, symbol)
+	case ctx.Err() != nil || errors.Is(err, api.ErrThrottled):
+		stats.skipped.Add(1)
+	default:
+		stats.failed.Add(1)
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Error updating stock in batch")
+	}
+}
+
+func (st *StockTracker) quotaExhaustedUntil() (time.Time, bool) {
+	if reporter, ok := st.provider.(api.QuotaReporter); ok {
+		return reporter.QuotaExhaustedUntil()
+	}
+	return time.Time{}, false
 }
 
 func (st *StockTracker) Display() {
@@ -155,25 +435,68 @@ func (st *StockTracker) Display() {
 			stock.ChangePercent, stock.LastUpdated.Format("15:04:05"),
 		)
 	}
-	fmt.Println("===========================================\n")
+	fmt.Println("===========================================")
+	fmt.Println()
 }
 
+// Run performs an update cycle immediately and then every interval until
+// Close is called. With a calendar, cycles only run in polled sessions and
+// the tracker sleeps through the rest.
 func (st *StockTracker) Run() {
+	st.running.Store(true)
+	defer close(st.stopped)
+
 	st.monitor.Start()
-	ticker := time.NewTicker(st.interval)
-	defer ticker.Stop()
 
-	logger.Info().Msg("Performing initial stock update")
-	st.UpdateAll()
-	st.Display()
+	if cal := st.opts.Calendar; cal != nil && !cal.Covers(time.Now()) {
+		logger.Warn().Str("calendar", cal.Name()).Msg("Market calendar has no holidays for this year, treating every weekday as a trading day")
+	}
+
+	if len(st.opts.BarIntervals) > 0 && st.intradayProvider() == nil {
+		logger.Warn().Str("provider", st.provider.Name()).Msg("Quote provider has no intraday bars, not ingesting bars")
+	}
 
-	for range ticker.C {
-		st.UpdateAll()
+	if st.polling(time.Now()) {
+		logger.Info().Msg("Performing initial stock update")
+		st.UpdateAll(st.ctx)
+		st.Display()
+	}
+
+	for {
+		now := time.Now()
+		next := st.nextCycle(now)
+		if !st.polling(now) {
+			logger.Info().Str("session", string(st.session(now))).Time("next_update", next).Msg("Market closed, waiting for next session")
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-st.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !st.polling(time.Now()) {
+			continue
+		}
+		if err := st.SyncWatchlist(st.ctx); err != nil {
+			logger.Error().Err(err).Msg("Keeping current watchlist")
+		}
+		st.UpdateAll(st.ctx)
 		st.Display()
 	}
 }
 
+// Close cancels in-flight fetches, waits for Run to return and stops the
+// alert monitor. It is safe to call more than once.
 func (st *StockTracker) Close() {
-	logger.Info().Msg("Closing stock tracker")
-	st.monitor.Close()
+	st.closeOnce.Do(func() {
+		logger.Info().Msg("Closing stock tracker")
+		st.cancel()
+		if st.running.Load() {
+			<-st.stopped
+		}
+		st.monitor.Close()
+	})
 }
@@ -0,0 +1,61 @@
+package tracker
+
+import (
+	"context"
+	"testing"
+
+	"stock-tracker/internal/events"
+	"stock-tracker/internal/metrics"
+	"stock-tracker/internal/models"
+	"stock-tracker/internal/repository"
+)
+
+// testMetrics is shared because metrics register with the default registry.
+var testMetrics = metrics.New()
+
+func TestSyncWatchlistFollowsReaddedStock(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	if err := repo.CreateStock(ctx, models.NewStock("AAPL")); err != nil {
+		t.Fatalf("CreateStock: %v", err)
+	}
+
+	st := New(nil, Options{}, testMetrics, repo, events.NewMemoryBus())
+	if err := st.LoadWatchlist(ctx, nil); err != nil {
+		t.Fatalf("LoadWatchlist: %v", err)
+	}
+
+	if err := repo.DeleteStock(ctx, "AAPL"); err != nil {
+		t.Fatalf("DeleteStock: %v", err)
+	}
+	readded := models.NewStock("AAPL")
+	if err := repo.CreateStock(ctx, readded); err != nil {
+		t.Fatalf("CreateStock: %v", err)
+	}
+
+	if err := st.SyncWatchlist(ctx); err != nil {
+		t.Fatalf("SyncWatchlist: %v", err)
+	}
+
+	st.mu.RLock()
+	stock := st.stocks["AAPL"]
+	st.mu.RUnlock()
+	if stock == nil {
+		t.Fatal("AAPL is no longer tracked")
+	}
+	if stock.ID != readded.ID {
+		t.Fatalf("stock ID = %d, want the re-added stock's %d", stock.ID, readded.ID)
+	}
+
+	stock.UpdatePrice(150, 1.5)
+	st.savePrice(ctx, stock)
+	st.flushPrices(ctx)
+
+	latest, err := repo.GetLatestPrice(ctx, "AAPL")
+	if err != nil {
+		t.Fatalf("GetLatestPrice: %v", err)
+	}
+	if latest.Price != 150 {
+		t.Fatalf("latest price = %v, want 150", latest.Price)
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS stock_prices;
+DROP TABLE IF EXISTS stocks;
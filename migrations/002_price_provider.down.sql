@@ -0,0 +1 @@
+ALTER TABLE stock_prices DROP COLUMN IF EXISTS provider;
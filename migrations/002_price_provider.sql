@@ -0,0 +1,2 @@
+-- Record which quote provider served each price
+ALTER TABLE stock_prices ADD COLUMN IF NOT EXISTS provider VARCHAR(50);
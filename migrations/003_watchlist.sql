@@ -0,0 +1,2 @@
+-- Paused stocks stay in the watchlist but are not fetched
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE;
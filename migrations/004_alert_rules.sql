@@ -0,0 +1,18 @@
+-- Per-symbol alert rules evaluated by the alert monitor
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    rule_type VARCHAR(50) NOT NULL,
+    threshold DECIMAL(12, 4) NOT NULL,
+    window_seconds INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_stock_id ON alert_rules(stock_id);
+
+-- Alerts fired by a rule reference it
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS rule_id INTEGER REFERENCES alert_rules(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_alerts_rule_id ON alerts(rule_id);
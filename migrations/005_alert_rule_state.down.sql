@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_alerts_dedup_key;
+ALTER TABLE alerts DROP COLUMN IF EXISTS dedup_key;
+DROP TABLE IF EXISTS alert_rule_state;
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS hysteresis;
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS cooldown_seconds;
@@ -0,0 +1,17 @@
+-- Cooldown and re-arm hysteresis per rule
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS cooldown_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS hysteresis DECIMAL(12, 4) NOT NULL DEFAULT 0;
+
+-- Firing state of each rule, kept across tracker restarts
+CREATE TABLE IF NOT EXISTS alert_rule_state (
+    rule_id INTEGER PRIMARY KEY REFERENCES alert_rules(id) ON DELETE CASCADE,
+    armed BOOLEAN NOT NULL DEFAULT TRUE,
+    episode INTEGER NOT NULL DEFAULT 0,
+    last_fired_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Alerts carry a dedup key so the same firing is only stored once
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS dedup_key VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alerts_dedup_key ON alerts(dedup_key);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS notification_deliveries;
+DROP TABLE IF EXISTS alert_rule_channels;
+DROP TABLE IF EXISTS notification_channels;
@@ -0,0 +1 @@
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS params;
@@ -0,0 +1,2 @@
+-- Indicator settings of technical-indicator alert rules
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS params JSONB NOT NULL DEFAULT '{}';
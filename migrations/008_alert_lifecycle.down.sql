@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS alert_state_changes;
+DROP INDEX IF EXISTS idx_alerts_state;
+ALTER TABLE alerts DROP COLUMN IF EXISTS snoozed_until;
+ALTER TABLE alerts DROP COLUMN IF EXISTS state_changed_by;
+ALTER TABLE alerts DROP COLUMN IF EXISTS state_changed_at;
+ALTER TABLE alerts DROP COLUMN IF EXISTS state;
@@ -0,0 +1,21 @@
+-- Alert lifecycle: open, acknowledged, snoozed (until snoozed_until) or resolved
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS state VARCHAR(20) NOT NULL DEFAULT 'open';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS state_changed_at TIMESTAMP;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS state_changed_by VARCHAR(100);
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_alerts_state ON alerts(state);
+
+-- Audit trail of every state change
+CREATE TABLE IF NOT EXISTS alert_state_changes (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id INTEGER NOT NULL REFERENCES alerts(id) ON DELETE CASCADE,
+    from_state VARCHAR(20) NOT NULL,
+    to_state VARCHAR(20) NOT NULL,
+    changed_by VARCHAR(100) NOT NULL,
+    note TEXT,
+    snoozed_until TIMESTAMP,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_state_changes_alert_id ON alert_state_changes(alert_id);
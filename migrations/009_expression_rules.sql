@@ -0,0 +1,2 @@
+-- Conditions of expression alert rules
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS expression TEXT;
@@ -0,0 +1,2 @@
+-- Market session (pre_market, regular, after_hours) each price was recorded in
+ALTER TABLE stock_prices ADD COLUMN IF NOT EXISTS session TEXT;
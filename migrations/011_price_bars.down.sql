@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS backfill_progress;
+DROP TABLE IF EXISTS price_bars;
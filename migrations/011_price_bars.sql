@@ -0,0 +1,23 @@
+-- OHLCV bars from provider history, one row per symbol, interval and bar start
+CREATE TABLE IF NOT EXISTS price_bars (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    interval TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    open DECIMAL(12, 4) NOT NULL,
+    high DECIMAL(12, 4) NOT NULL,
+    low DECIMAL(12, 4) NOT NULL,
+    close DECIMAL(12, 4) NOT NULL,
+    volume BIGINT NOT NULL DEFAULT 0,
+    provider TEXT,
+    PRIMARY KEY (stock_id, interval, timestamp)
+);
+
+-- Range of days already backfilled, so an interrupted backfill resumes
+CREATE TABLE IF NOT EXISTS backfill_progress (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    interval TEXT NOT NULL,
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (stock_id, interval)
+);
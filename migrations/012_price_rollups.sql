@@ -0,0 +1,17 @@
+-- Downsampled prices, built from stock_prices or a finer rollup by the
+-- retention job before those rows are deleted. volume is the day's
+-- cumulative volume at the bucket's last tick, like stock_prices.volume.
+CREATE TABLE IF NOT EXISTS price_rollups (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    interval TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    open DECIMAL(12, 4) NOT NULL,
+    high DECIMAL(12, 4) NOT NULL,
+    low DECIMAL(12, 4) NOT NULL,
+    close DECIMAL(12, 4) NOT NULL,
+    volume BIGINT NOT NULL DEFAULT 0,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (stock_id, interval, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_rollups_interval_timestamp ON price_rollups(interval, timestamp);
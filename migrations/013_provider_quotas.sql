@@ -0,0 +1,10 @@
+-- Rate limiter state per quote provider, shared by the tracker, the API
+-- server and the backfill command so together they stay within the quota.
+CREATE TABLE IF NOT EXISTS provider_quotas (
+    provider TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    refilled_at TIMESTAMP NOT NULL,
+    day DATE NOT NULL,
+    used_today INTEGER NOT NULL DEFAULT 0,
+    version BIGINT NOT NULL
+);
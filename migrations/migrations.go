@@ -0,0 +1,20 @@
+// Package migrations embeds the database schemas so they ship inside the
+// binaries. Each migration is a numbered file, NNN_description.sql, with
+// the statements that undo it in NNN_description.down.sql.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Postgres holds the PostgreSQL migrations.
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+//go:embed sqlite/*.sql
+var sqlite embed.FS
+
+// SQLite holds the migrations of the SQLite backend.
+var SQLite, _ = fs.Sub(sqlite, "sqlite")
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS notification_deliveries;
+DROP TABLE IF EXISTS alert_rule_channels;
+DROP TABLE IF EXISTS notification_channels;
+DROP TABLE IF EXISTS alert_state_changes;
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS alert_rule_state;
+DROP TABLE IF EXISTS alert_rules;
+DROP TABLE IF EXISTS backfill_progress;
+DROP TABLE IF EXISTS price_bars;
+DROP TABLE IF EXISTS price_rollups;
+DROP TABLE IF EXISTS stock_prices;
+DROP TABLE IF EXISTS stocks;
@@ -0,0 +1,151 @@
+-- Schema of the SQLite backend, equivalent to migrations/*.sql up to
+-- 012_price_rollups.sql. Timestamps are microseconds since the Unix epoch
+-- in UTC, dates are 'YYYY-MM-DD' and JSON columns hold text.
+
+CREATE TABLE IF NOT EXISTS stocks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    symbol TEXT NOT NULL UNIQUE,
+    name TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS stock_prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    price REAL NOT NULL,
+    change_percent REAL,
+    volume INTEGER,
+    provider TEXT,
+    session TEXT,
+    timestamp INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_prices_timestamp ON stock_prices(timestamp);
+CREATE INDEX IF NOT EXISTS idx_stock_prices_stock_timestamp ON stock_prices(stock_id, timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS price_rollups (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    interval TEXT NOT NULL,
+    timestamp INTEGER NOT NULL,
+    open REAL NOT NULL,
+    high REAL NOT NULL,
+    low REAL NOT NULL,
+    close REAL NOT NULL,
+    volume INTEGER NOT NULL DEFAULT 0,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (stock_id, interval, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_rollups_interval_timestamp ON price_rollups(interval, timestamp);
+CREATE INDEX IF NOT EXISTS idx_price_rollups_stock_timestamp ON price_rollups(stock_id, timestamp);
+
+CREATE TABLE IF NOT EXISTS price_bars (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    interval TEXT NOT NULL,
+    timestamp INTEGER NOT NULL,
+    open REAL NOT NULL,
+    high REAL NOT NULL,
+    low REAL NOT NULL,
+    close REAL NOT NULL,
+    volume INTEGER NOT NULL DEFAULT 0,
+    provider TEXT,
+    PRIMARY KEY (stock_id, interval, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS backfill_progress (
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    interval TEXT NOT NULL,
+    from_date TEXT NOT NULL,
+    to_date TEXT NOT NULL,
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (stock_id, interval)
+);
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    rule_type TEXT NOT NULL,
+    threshold REAL NOT NULL,
+    window_seconds INTEGER NOT NULL DEFAULT 0,
+    cooldown_seconds INTEGER NOT NULL DEFAULT 0,
+    hysteresis REAL NOT NULL DEFAULT 0,
+    params TEXT NOT NULL DEFAULT '{}',
+    expression TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_stock_id ON alert_rules(stock_id);
+
+CREATE TABLE IF NOT EXISTS alert_rule_state (
+    rule_id INTEGER PRIMARY KEY REFERENCES alert_rules(id) ON DELETE CASCADE,
+    armed BOOLEAN NOT NULL DEFAULT TRUE,
+    episode INTEGER NOT NULL DEFAULT 0,
+    last_fired_at INTEGER,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stock_id INTEGER NOT NULL REFERENCES stocks(id) ON DELETE CASCADE,
+    rule_id INTEGER REFERENCES alert_rules(id) ON DELETE SET NULL,
+    alert_type TEXT NOT NULL,
+    threshold REAL NOT NULL,
+    message TEXT,
+    dedup_key TEXT UNIQUE,
+    triggered_at INTEGER NOT NULL,
+    state TEXT NOT NULL DEFAULT 'open',
+    state_changed_at INTEGER,
+    state_changed_by TEXT,
+    snoozed_until INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_stock_id ON alerts(stock_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_rule_id ON alerts(rule_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_triggered_at ON alerts(triggered_at DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_state ON alerts(state);
+
+CREATE TABLE IF NOT EXISTS alert_state_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    alert_id INTEGER NOT NULL REFERENCES alerts(id) ON DELETE CASCADE,
+    from_state TEXT NOT NULL,
+    to_state TEXT NOT NULL,
+    changed_by TEXT NOT NULL,
+    note TEXT,
+    snoozed_until INTEGER,
+    changed_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_state_changes_alert_id ON alert_state_changes(alert_id);
+
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    channel_type TEXT NOT NULL,
+    config TEXT NOT NULL DEFAULT '{}',
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS alert_rule_channels (
+    rule_id INTEGER NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+    PRIMARY KEY (rule_id, channel_id)
+);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    alert_id INTEGER REFERENCES alerts(id) ON DELETE CASCADE,
+    channel_id INTEGER REFERENCES notification_channels(id) ON DELETE SET NULL,
+    attempt INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_alert_id ON notification_deliveries(alert_id);
@@ -0,0 +1,9 @@
+-- provider_quotas, as in 013_provider_quotas.sql.
+CREATE TABLE IF NOT EXISTS provider_quotas (
+    provider TEXT PRIMARY KEY,
+    tokens REAL NOT NULL,
+    refilled_at INTEGER NOT NULL,
+    day TEXT NOT NULL,
+    used_today INTEGER NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL
+);